@@ -0,0 +1,268 @@
+package powerdns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	acmeChallengeLabel = "_acme-challenge"
+	acmeMaxCNAMEHops   = 10
+)
+
+// ACMEProvider solves ACME DNS-01 challenges by managing TXT records on the PowerDNS server.
+// Its Present, CleanUp and Timeout methods are compatible with common ACME client libraries.
+type ACMEProvider struct {
+	client *Client
+
+	// TTL of the TXT records
+	TTL uint32
+	// PropagationTimeout limits how long Present waits until the record is visible
+	PropagationTimeout time.Duration
+	// PollingInterval defines the delay between two visibility checks
+	PollingInterval time.Duration
+	// IsVisible reports whether a TXT value is visible for an FQDN. By default, AuthoritativeTXTVisible is used.
+	IsVisible func(ctx context.Context, fqdn string, value string) (bool, error)
+	// NameServers overrides the authoritative name servers queried by AuthoritativeTXTVisible, e.g. "192.0.2.53" or "[2001:db8::53]:5300"
+	NameServers []string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewACMEProvider creates a new ACMEProvider with sensible defaults
+func NewACMEProvider(client *Client) *ACMEProvider {
+	provider := &ACMEProvider{
+		client:             client,
+		TTL:                60,
+		PropagationTimeout: 2 * time.Minute,
+		PollingInterval:    2 * time.Second,
+		locks:              make(map[string]*sync.Mutex),
+	}
+	provider.IsVisible = provider.AuthoritativeTXTVisible
+	return provider
+}
+
+// ACMEChallengeRecord returns the FQDN and the TXT value of a DNS-01 challenge
+func ACMEChallengeRecord(domain, keyAuth string) (string, string) {
+	digest := sha256.Sum256([]byte(keyAuth))
+	fqdn := makeDomainCanonical(acmeChallengeLabel + "." + strings.TrimPrefix(trimDomain(domain), "*."))
+	return fqdn, base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// ResolverTXTVisible reports whether a TXT value is visible for an FQDN via the system resolver.
+// Resolvers cache negative answers for the SOA minimum of the zone, which can delay the visibility beyond the propagation timeout.
+func ResolverTXTVisible(ctx context.Context, fqdn string, value string) (bool, error) {
+	values, err := net.DefaultResolver.LookupTXT(ctx, fqdn)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, v := range values {
+		if v == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AuthoritativeTXTVisible reports whether a TXT value is served by all authoritative name servers of the zone holding the record.
+// CNAMEs are followed on the PowerDNS server. The name servers are taken from the NS RRset of the zone, unless NameServers is set.
+func (a *ACMEProvider) AuthoritativeTXTVisible(ctx context.Context, fqdn string, value string) (bool, error) {
+	zone, name, err := a.resolveTarget(ctx, makeDomainCanonical(fqdn))
+	if err != nil {
+		return false, err
+	}
+
+	servers := a.NameServers
+	if len(servers) == 0 {
+		if servers, err = zoneNameServerAddresses(ctx, zone); err != nil {
+			return false, err
+		}
+	}
+
+	for _, server := range servers {
+		response, err := NewDNSClient(server).Query(ctx, name, RRTypeTXT)
+		if err != nil {
+			return false, err
+		}
+		if !containsTXTValue(findRRSet(response.Answer, name, RRTypeTXT), value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// zoneNameServerAddresses returns one address per name server of a zone.
+// Addresses of name servers within the zone are taken from the zone, others are looked up via the system resolver.
+func zoneNameServerAddresses(ctx context.Context, zone *Zone) ([]string, error) {
+	apex := makeDomainCanonical(StringValue(zone.Name))
+	ns := findRRSet(zone.RRsets, apex, RRTypeNS)
+	if ns == nil || len(ns.Records) == 0 {
+		return nil, fmt.Errorf("zone %s has no NS records", apex)
+	}
+
+	servers := make([]string, 0, len(ns.Records))
+	for _, target := range nsContents(ns) {
+		addresses := append(rrsetContents(findRRSet(zone.RRsets, target, RRTypeA)), rrsetContents(findRRSet(zone.RRsets, target, RRTypeAAAA))...)
+		if len(addresses) == 0 {
+			var err error
+			if addresses, err = net.DefaultResolver.LookupHost(ctx, target); err != nil {
+				return nil, err
+			}
+		}
+		if len(addresses) > 0 {
+			servers = append(servers, addresses[0])
+		}
+	}
+	return servers, nil
+}
+
+func containsTXTValue(rrset *RRset, value string) bool {
+	if rrset == nil {
+		return false
+	}
+	for _, record := range rrset.Records {
+		if v, err := DecodeTXT(StringValue(record.Content)); err == nil && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeout returns the propagation timeout and the polling interval
+func (a *ACMEProvider) Timeout() (time.Duration, time.Duration) {
+	return a.PropagationTimeout, a.PollingInterval
+}
+
+// Present creates the TXT record of a DNS-01 challenge and waits until it is visible.
+// Concurrent challenges for the same name are merged into a single TXT RRset.
+func (a *ACMEProvider) Present(domain, token, keyAuth string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.PropagationTimeout)
+	defer cancel()
+
+	fqdn, value := ACMEChallengeRecord(domain, keyAuth)
+	if err := a.updateTXT(ctx, fqdn, value, true); err != nil {
+		return err
+	}
+
+	return a.waitUntilVisible(ctx, fqdn, value)
+}
+
+// CleanUp removes the TXT value of a DNS-01 challenge, keeping the values of concurrent challenges
+func (a *ACMEProvider) CleanUp(domain, token, keyAuth string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.PropagationTimeout)
+	defer cancel()
+
+	fqdn, value := ACMEChallengeRecord(domain, keyAuth)
+	return a.updateTXT(ctx, fqdn, value, false)
+}
+
+func (a *ACMEProvider) lock(fqdn string) func() {
+	a.mu.Lock()
+	if a.locks == nil {
+		a.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := a.locks[fqdn]
+	if !ok {
+		l = &sync.Mutex{}
+		a.locks[fqdn] = l
+	}
+	a.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// resolveTarget follows CNAMEs on the server and returns the zone and the name which holds the TXT record
+func (a *ACMEProvider) resolveTarget(ctx context.Context, fqdn string) (*Zone, string, error) {
+	name := fqdn
+	for hop := 0; hop <= acmeMaxCNAMEHops; hop++ {
+		enclosing, err := a.client.Zones.findEnclosing(ctx, name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		zone, err := a.client.Zones.get(ctx, StringValue(enclosing.Name))
+		if err != nil {
+			return nil, "", err
+		}
+
+		cname := findRRSet(zone.RRsets, name, RRTypeCNAME)
+		if cname == nil || len(cname.Records) == 0 {
+			return zone, name, nil
+		}
+		name = makeDomainCanonical(StringValue(cname.Records[0].Content))
+	}
+
+	return nil, "", fmt.Errorf("too many CNAMEs while resolving %s", fqdn)
+}
+
+func (a *ACMEProvider) updateTXT(ctx context.Context, fqdn string, value string, present bool) error {
+	zone, name, err := a.resolveTarget(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+
+	unlock := a.lock(name)
+	defer unlock()
+
+	// Re-read the zone under the lock, so that concurrent challenges see each other's values
+	zone, err = a.client.Zones.get(ctx, StringValue(zone.Name))
+	if err != nil {
+		return err
+	}
+
+	quoted := EncodeTXT(value)
+	content := make([]string, 0)
+	if existing := findRRSet(zone.RRsets, name, RRTypeTXT); existing != nil {
+		for _, record := range existing.Records {
+			if c := StringValue(record.Content); c != quoted {
+				content = append(content, c)
+			}
+		}
+	}
+
+	if present {
+		content = append(content, quoted)
+	}
+
+	if len(content) == 0 {
+		return a.client.Records.Delete(ctx, StringValue(zone.Name), name, RRTypeTXT)
+	}
+	return a.client.Records.Change(ctx, StringValue(zone.Name), name, RRTypeTXT, a.TTL, content)
+}
+
+func (a *ACMEProvider) waitUntilVisible(ctx context.Context, fqdn string, value string) error {
+	if a.IsVisible == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(a.PollingInterval)
+	defer ticker.Stop()
+
+	for {
+		visible, err := a.IsVisible(ctx, fqdn, value)
+		if err != nil {
+			return err
+		}
+		if visible {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("TXT record %s is not visible: %w", fqdn, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestACMEChallengeRecord(t *testing.T) {
+	fqdn, value := ACMEChallengeRecord("*.example.com", "token.thumbprint")
+	if fqdn != "_acme-challenge.example.com." {
+		t.Errorf("Invalid FQDN: %s", fqdn)
+	}
+	if value != "61rBZ_4knHblO0MNoxFsXZ_eTFUHum0B6IVRbhvUn5I" {
+		t.Errorf("Invalid value: %s", value)
+	}
+}
+
+func TestACMEProvider(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{
+		"example.com.": {
+			{Name: String("_acme-challenge.www.example.com."), Type: RRTypePtr(RRTypeCNAME), TTL: Uint32(300), Records: []Record{{Content: String("www.acme.example.org.")}}},
+		},
+		"acme.example.org.": {},
+	}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	provider := NewACMEProvider(p)
+	provider.PollingInterval = time.Millisecond
+	var checks int32
+	provider.IsVisible = func(ctx context.Context, fqdn string, value string) (bool, error) {
+		return atomic.AddInt32(&checks, 1) > 1, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- provider.Present("www.example.com", "token", fmt.Sprintf("keyAuth%d", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	txt := findRRSet(zones["acme.example.org."], "www.acme.example.org.", RRTypeTXT)
+	if txt == nil || len(txt.Records) != 3 {
+		t.Fatalf("Challenges have not been merged: %+v", zones["acme.example.org."])
+	}
+	if len(patched["example.com."]) != 0 {
+		t.Error("CNAME has not been followed")
+	}
+
+	if err := provider.CleanUp("www.example.com", "token", "keyAuth1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	txt = findRRSet(zones["acme.example.org."], "www.acme.example.org.", RRTypeTXT)
+	if txt == nil || len(txt.Records) != 2 {
+		t.Fatalf("Invalid TXT RRset after clean up: %+v", txt)
+	}
+
+	for _, keyAuth := range []string{"keyAuth0", "keyAuth2"} {
+		if err := provider.CleanUp("www.example.com", "token", keyAuth); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if txt := findRRSet(zones["acme.example.org."], "www.acme.example.org.", RRTypeTXT); txt != nil {
+		t.Errorf("TXT RRset has not been deleted: %+v", txt)
+	}
+}
+
+func TestACMEProviderErrors(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{
+		"example.com.": {
+			{Name: String("_acme-challenge.loop.example.com."), Type: RRTypePtr(RRTypeCNAME), Records: []Record{{Content: String("_acme-challenge.loop.example.com.")}}},
+		},
+	}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	provider := NewACMEProvider(p)
+	provider.PropagationTimeout = 10 * time.Millisecond
+	provider.PollingInterval = time.Millisecond
+	provider.IsVisible = func(ctx context.Context, fqdn string, value string) (bool, error) {
+		return false, nil
+	}
+
+	if err := provider.Present("example.net", "token", "keyAuth"); err == nil {
+		t.Error("Missing zone does not return an error")
+	}
+	if err := provider.Present("loop.example.com", "token", "keyAuth"); err == nil {
+		t.Error("CNAME loop does not return an error")
+	}
+	if err := provider.Present("www.example.com", "token", "keyAuth"); err == nil {
+		t.Error("Invisible record does not return an error")
+	}
+
+	timeout, interval := provider.Timeout()
+	if timeout != 10*time.Millisecond || interval != time.Millisecond {
+		t.Error("Invalid timeout")
+	}
+}
+
+func TestACMEProviderAuthoritativeVisibility(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{
+		"example.com.": {
+			{Name: String("example.com."), Type: RRTypePtr(RRTypeNS), TTL: Uint32(3600), Records: []Record{{Content: String("ns1.example.com.")}}},
+			{Name: String("ns1.example.com."), Type: RRTypePtr(RRTypeA), TTL: Uint32(3600), Records: []Record{{Content: String("192.0.2.53")}}},
+		},
+	}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	fqdn, value := ACMEChallengeRecord("www.example.com", "keyAuth")
+	server := startDNSStubServer(t, "example.com.", append([]RRset{}, zones["example.com."]...))
+
+	p := initialisePowerDNSTestClient()
+	provider := NewACMEProvider(p)
+	provider.PollingInterval = time.Millisecond
+	provider.NameServers = []string{server.address()}
+	ctx := context.Background()
+
+	if visible, err := provider.IsVisible(ctx, fqdn, value); err != nil || visible {
+		t.Errorf("Invalid visibility before publication: %t, %v", visible, err)
+	}
+
+	txt := RRset{Name: String(fqdn), Type: RRTypePtr(RRTypeTXT), TTL: Uint32(60), Records: []Record{{Content: String(EncodeTXT(value))}}}
+	server.setRRsets(append(append([]RRset{}, zones["example.com."]...), txt))
+	if err := provider.Present("www.example.com", "token", "keyAuth"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(patched["example.com."]) != 1 {
+		t.Errorf("Invalid patches: %+v", patched)
+	}
+
+	zone := &Zone{Name: String("example.com."), RRsets: zones["example.com."]}
+	if servers, err := zoneNameServerAddresses(ctx, zone); err != nil || len(servers) != 1 || servers[0] != "192.0.2.53" {
+		t.Errorf("Invalid name servers: %v, %v", servers, err)
+	}
+	if _, err := zoneNameServerAddresses(ctx, &Zone{Name: String("example.org.")}); err == nil {
+		t.Error("Zone without NS records does not return an error")
+	}
+}
@@ -0,0 +1,162 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Batch collects changes of resource record sets across one or multiple zones and sends them as PATCH requests per zone.
+// Changes with the same zone, name and type are merged, the last change wins.
+type Batch struct {
+	client *Client
+
+	// ChunkSize limits the amount of RRsets per PATCH request. If it is zero or negative, all RRsets of a zone are sent in a single request.
+	ChunkSize int
+
+	zones   []string
+	changes map[string][]RRset
+	index   map[string]map[string]int
+	err     error
+}
+
+// BatchResult describes the outcome of a single PATCH request sent by a Batch
+type BatchResult struct {
+	Zone   string
+	RRsets []RRset
+	Err    error
+}
+
+// NewBatch creates an empty Batch with a given chunk size
+func (r *RecordsService) NewBatch(chunkSize int) *Batch {
+	b := &Batch{client: r.client, ChunkSize: chunkSize}
+	b.reset()
+	return b
+}
+
+func (b *Batch) reset() {
+	b.zones = make([]string, 0)
+	b.changes = make(map[string][]RRset)
+	b.index = make(map[string]map[string]int)
+	b.err = nil
+}
+
+// Replace adds a REPLACE change for a resource record set to the batch
+func (b *Batch) Replace(domain string, name string, recordType RRType, ttl uint32, content []string) *Batch {
+	rrset := RRset{
+		Name:       &name,
+		Type:       &recordType,
+		TTL:        &ttl,
+		ChangeType: ChangeTypePtr(ChangeTypeReplace),
+		Records:    make([]Record, 0, len(content)),
+	}
+
+	for _, c := range content {
+		rrset.Records = append(rrset.Records, Record{Content: String(c), Disabled: Bool(false), SetPTR: Bool(false)})
+	}
+
+	return b.Add(domain, rrset)
+}
+
+// Delete adds a DELETE change for a resource record set to the batch
+func (b *Batch) Delete(domain string, name string, recordType RRType) *Batch {
+	rrset := RRset{
+		Name:       &name,
+		Type:       &recordType,
+		ChangeType: ChangeTypePtr(ChangeTypeDelete),
+	}
+
+	return b.Add(domain, rrset)
+}
+
+// Add adds an already prepared resource record set to the batch.
+// Invalid names are reported by Flush, which then sends no changes at all.
+func (b *Batch) Add(domain string, rrset RRset) *Batch {
+	zone := strings.ToLower(makeDomainCanonical(domain))
+	name, err := b.client.Records.qualifyName(domain, StringValue(rrset.Name))
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	rrset.Name = String(name)
+	if rrset.Type == nil {
+		if b.err == nil {
+			b.err = fmt.Errorf("RRset %s has no type", name)
+		}
+		return b
+	}
+	if err := fixRRSetContent(&rrset); err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+
+	if _, ok := b.index[zone]; !ok {
+		b.zones = append(b.zones, zone)
+		b.index[zone] = make(map[string]int)
+	}
+
+	key := strings.ToLower(*rrset.Name) + "/" + string(*rrset.Type)
+	if i, ok := b.index[zone][key]; ok {
+		b.changes[zone][i] = rrset
+		return b
+	}
+
+	b.index[zone][key] = len(b.changes[zone])
+	b.changes[zone] = append(b.changes[zone], rrset)
+	return b
+}
+
+// Len returns the amount of pending RRset changes
+func (b *Batch) Len() int {
+	length := 0
+	for _, rrsets := range b.changes {
+		length += len(rrsets)
+	}
+	return length
+}
+
+// Flush sends all pending changes and empties the batch.
+// All chunks are sent even if some of them fail. The results contain one entry per chunk, and the first error is returned.
+func (b *Batch) Flush(ctx context.Context) ([]BatchResult, error) {
+	if b.err != nil {
+		err := b.err
+		b.reset()
+		return nil, err
+	}
+
+	results := make([]BatchResult, 0)
+	var firstErr error
+
+	for _, zone := range b.zones {
+		for _, chunk := range chunkRRSets(b.changes[zone], b.ChunkSize) {
+			err := b.client.Records.patchRRSet(ctx, zone, &RRsets{Sets: chunk})
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			results = append(results, BatchResult{Zone: zone, RRsets: chunk, Err: err})
+		}
+	}
+
+	b.reset()
+	return results, firstErr
+}
+
+func chunkRRSets(rrsets []RRset, chunkSize int) [][]RRset {
+	if chunkSize <= 0 || len(rrsets) <= chunkSize {
+		return [][]RRset{rrsets}
+	}
+
+	chunks := make([][]RRset, 0, (len(rrsets)+chunkSize-1)/chunkSize)
+	for start := 0; start < len(rrsets); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rrsets) {
+			end = len(rrsets)
+		}
+		chunks = append(chunks, rrsets[start:end])
+	}
+	return chunks
+}
@@ -0,0 +1,114 @@
+package powerdns
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestBatch(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.com.": {}, "example.org.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	b := p.Records.NewBatch(2)
+	b.Replace("example.com", "a.example.com", RRTypeA, 300, []string{"192.0.2.1"}).
+		Replace("example.com", "b.example.com", RRTypeA, 300, []string{"192.0.2.2"}).
+		Replace("example.com", "c.example.com", RRTypeCNAME, 300, []string{"a.example.com"}).
+		Delete("example.com", "A.example.com.", RRTypeA).
+		Replace("example.org.", "www.example.org", RRTypeAAAA, 300, []string{"2001:db8::1"})
+
+	if b.Len() != 4 {
+		t.Fatalf("Invalid batch length: %d", b.Len())
+	}
+
+	results, err := b.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(results) != 3 || results[0].Zone != "example.com." || len(results[0].RRsets) != 2 || len(results[1].RRsets) != 1 || results[2].Zone != "example.org." {
+		t.Fatalf("Invalid results: %+v", results)
+	}
+	if *patched["example.com."][0].ChangeType != ChangeTypeDelete {
+		t.Error("Duplicate change has not been merged")
+	}
+	if *patched["example.com."][2].Records[0].Content != "a.example.com." {
+		t.Error("CNAME content has not been made canonical")
+	}
+	if b.Len() != 0 {
+		t.Error("Batch has not been emptied")
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.org.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+	httpmock.RegisterResponder("PATCH", generateTestAPIVHostURL()+"/zones/example.com",
+		httpmock.NewStringResponder(http.StatusUnprocessableEntity, "Unprocessable Entity"))
+
+	p := initialisePowerDNSTestClient()
+	b := p.Records.NewBatch(0)
+	b.Delete("example.com", "a.example.com", RRTypeA).Delete("example.org", "a.example.org", RRTypeA)
+
+	results, err := b.Flush(context.Background())
+	if err == nil {
+		t.Error("error is nil")
+	}
+	if len(results) != 2 || results[0].Err == nil || results[1].Err != nil {
+		t.Errorf("Invalid results: %+v", results)
+	}
+	if len(patched["example.org."]) != 1 {
+		t.Error("Remaining chunks have not been sent")
+	}
+}
+
+func TestBatchMissingType(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.com.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	b := p.Records.NewBatch(0)
+	b.Delete("example.com", "a.example.com", RRTypeA).Add("example.com", RRset{Name: String("b.example.com"), ChangeType: ChangeTypePtr(ChangeTypeDelete)})
+
+	if _, err := b.Flush(context.Background()); err == nil {
+		t.Error("error is nil")
+	}
+	if len(patched["example.com."]) != 0 {
+		t.Errorf("Changes have been sent: %+v", patched)
+	}
+}
+
+func TestChunkRRSets(t *testing.T) {
+	rrsets := make([]RRset, 5)
+	testCases := []struct {
+		chunkSize  int
+		wantChunks int
+	}{
+		{0, 1},
+		{-1, 1},
+		{2, 3},
+		{5, 1},
+		{10, 1},
+	}
+
+	for _, tc := range testCases {
+		if chunks := chunkRRSets(rrsets, tc.chunkSize); len(chunks) != tc.wantChunks {
+			t.Errorf("Chunk size %d: %d != %d", tc.chunkSize, len(chunks), tc.wantChunks)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Delete-DS signal of RFC 8078, section 4
+const (
+	cdsDeleteContent     = "0 0 0 00"
+	cdnskeyDeleteContent = "0 3 0 AA=="
+)
+
+// CDSPublication defines which CDS and CDNSKEY records are published by PowerDNS (RFC 7344)
+type CDSPublication struct {
+	// DigestTypes of the CDS records, CDS publication is disabled if empty
+	DigestTypes []DSDigestType
+	// CDNSKEY enables the publication of CDNSKEY records
+	CDNSKEY bool
+}
+
+// CDSRecords contains the content of the CDS and CDNSKEY records of a zone
+type CDSRecords struct {
+	CDS     []string
+	CDNSKEY []string
+	// DeleteSignal is set if the zone publishes the delete-DS signal
+	DeleteSignal bool
+}
+
+func (z *ZonesService) metadataValues(ctx context.Context, domain string, kind MetadataKind) ([]string, error) {
+	metadata, err := z.client.Metadata.Get(ctx, domain, kind)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metadata.Metadata, nil
+}
+
+// CDSPublication returns the CDS and CDNSKEY publication settings of a zone
+func (z *ZonesService) CDSPublication(ctx context.Context, domain string) (*CDSPublication, error) {
+	publication := &CDSPublication{DigestTypes: make([]DSDigestType, 0)}
+
+	cds, err := z.metadataValues(ctx, domain, MetadataPublishCDS)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range cds {
+		for _, digestType := range strings.Split(value, ",") {
+			n, err := strconv.ParseUint(strings.TrimSpace(digestType), 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s metadata %q", MetadataPublishCDS, value)
+			}
+			publication.DigestTypes = append(publication.DigestTypes, DSDigestType(n))
+		}
+	}
+
+	cdnskey, err := z.metadataValues(ctx, domain, MetadataPublishCDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	publication.CDNSKEY = len(cdnskey) > 0 && cdnskey[0] == "1"
+
+	return publication, nil
+}
+
+// SetCDSPublication changes the CDS and CDNSKEY publication settings of a zone
+func (z *ZonesService) SetCDSPublication(ctx context.Context, domain string, publication *CDSPublication) error {
+	if len(publication.DigestTypes) == 0 {
+		if err := z.client.Metadata.Delete(ctx, domain, MetadataPublishCDS); err != nil && !isNotFound(err) {
+			return err
+		}
+	} else {
+		digestTypes := make([]string, 0, len(publication.DigestTypes))
+		for _, digestType := range publication.DigestTypes {
+			digestTypes = append(digestTypes, strconv.Itoa(int(digestType)))
+		}
+		if _, err := z.client.Metadata.Set(ctx, domain, MetadataPublishCDS, []string{strings.Join(digestTypes, ",")}); err != nil {
+			return err
+		}
+	}
+
+	if !publication.CDNSKEY {
+		if err := z.client.Metadata.Delete(ctx, domain, MetadataPublishCDNSKEY); err != nil && !isNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	_, err := z.client.Metadata.Set(ctx, domain, MetadataPublishCDNSKEY, []string{"1"})
+	return err
+}
+
+// CDSRecords returns the CDS and CDNSKEY records served by a zone.
+// PowerDNS generates these records on the fly, so they are derived from the publication settings and the active and published KSKs and CSKs.
+// An explicit delete-DS signal takes precedence.
+func (z *ZonesService) CDSRecords(ctx context.Context, domain string) (*CDSRecords, error) {
+	zone, err := z.get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	apex := StringValue(zone.Name)
+
+	if cds := findRRSet(zone.RRsets, apex, RRTypeCDS); cds != nil {
+		records := &CDSRecords{CDS: rrsetContents(cds), CDNSKEY: rrsetContents(findRRSet(zone.RRsets, apex, RRTypeCDNSKEY))}
+		records.DeleteSignal = len(records.CDS) == 1 && records.CDS[0] == cdsDeleteContent
+		return records, nil
+	}
+
+	publication, err := z.CDSPublication(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	records := &CDSRecords{CDS: make([]string, 0), CDNSKEY: make([]string, 0)}
+	if len(publication.DigestTypes) == 0 && !publication.CDNSKEY {
+		return records, nil
+	}
+
+	cryptokeys, err := z.client.Cryptokeys.List(ctx, apex)
+	if err != nil {
+		return nil, err
+	}
+	for _, cryptokey := range cryptokeys {
+		keyType := CryptokeyType(StringValue(cryptokey.KeyType))
+		if keyType != CryptokeyTypeKSK && keyType != CryptokeyTypeCSK || !BoolValue(cryptokey.Active) || cryptokey.Published != nil && !*cryptokey.Published {
+			continue
+		}
+
+		dnskey, err := cryptokey.ParsedDNSKEY()
+		if err != nil {
+			return nil, err
+		}
+		for _, digestType := range publication.DigestTypes {
+			ds, err := dnskey.DS(apex, digestType)
+			if err != nil {
+				return nil, err
+			}
+			records.CDS = append(records.CDS, ds.String())
+		}
+		if publication.CDNSKEY {
+			records.CDNSKEY = append(records.CDNSKEY, dnskey.String())
+		}
+	}
+
+	return records, nil
+}
+
+// PublishDeleteDS replaces the generated CDS and CDNSKEY records with the delete-DS signal (RFC 8078, section 4),
+// which asks the parent to remove all DS records. The zone has to stay signed until the parent has removed them.
+func (z *ZonesService) PublishDeleteDS(ctx context.Context, domain string, ttl uint32) error {
+	if err := z.SetCDSPublication(ctx, domain, &CDSPublication{}); err != nil {
+		return err
+	}
+
+	zone := makeDomainCanonical(domain)
+	records := (*RecordsService)(z)
+	if err := records.Change(ctx, domain, zone, RRTypeCDS, ttl, []string{cdsDeleteContent}); err != nil {
+		return err
+	}
+	return records.Change(ctx, domain, zone, RRTypeCDNSKEY, ttl, []string{cdnskeyDeleteContent})
+}
+
+// WithdrawDeleteDS removes the delete-DS signal from a zone
+func (z *ZonesService) WithdrawDeleteDS(ctx context.Context, domain string) error {
+	zone := makeDomainCanonical(domain)
+	records := (*RecordsService)(z)
+	if err := records.Delete(ctx, domain, zone, RRTypeCDS); err != nil {
+		return err
+	}
+	return records.Delete(ctx, domain, zone, RRTypeCDNSKEY)
+}
@@ -0,0 +1,123 @@
+package powerdns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestCDSPublication(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	metadata := map[MetadataKind][]string{}
+	registerMetadataMockResponder("example.com", metadata)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	publication, err := p.Zones.CDSPublication(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(publication.DigestTypes) != 0 || publication.CDNSKEY {
+		t.Errorf("Invalid publication: %+v", publication)
+	}
+
+	if err := p.Zones.SetCDSPublication(ctx, "example.com", &CDSPublication{DigestTypes: []DSDigestType{DigestSHA256, DigestSHA384}, CDNSKEY: true}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if v := metadata[MetadataPublishCDS]; len(v) != 1 || v[0] != "2,4" {
+		t.Errorf("Invalid %s metadata: %v", MetadataPublishCDS, v)
+	}
+	if v := metadata[MetadataPublishCDNSKEY]; len(v) != 1 || v[0] != "1" {
+		t.Errorf("Invalid %s metadata: %v", MetadataPublishCDNSKEY, v)
+	}
+
+	publication, err = p.Zones.CDSPublication(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(publication.DigestTypes) != 2 || publication.DigestTypes[1] != DigestSHA384 || !publication.CDNSKEY {
+		t.Errorf("Invalid publication: %+v", publication)
+	}
+
+	if err := p.Zones.SetCDSPublication(ctx, "example.com", &CDSPublication{}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(metadata) != 0 {
+		t.Errorf("Metadata has not been removed: %v", metadata)
+	}
+}
+
+func TestCDSRecords(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.net.": {}}
+	registerZonesStateMockResponder(zones, make(map[string][]RRset))
+	registerMetadataMockResponder("example.net", map[MetadataKind][]string{MetadataPublishCDS: {"4"}, MetadataPublishCDNSKEY: {"1"}})
+	registerDelegationMockResponder("example.net", []Cryptokey{
+		{ID: Uint64(1), KeyType: String("ksk"), Active: Bool(true), Published: Bool(true), DNSkey: String(testDNSKEYECDSAP384)},
+		{ID: Uint64(2), KeyType: String("ksk"), Active: Bool(true), Published: Bool(false), DNSkey: String(testDNSKEYRSASHA1)},
+		{ID: Uint64(3), KeyType: String("zsk"), Active: Bool(true), DNSkey: String(testDNSKEYRSASHA1)},
+	})
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	records, err := p.Zones.CDSRecords(ctx, "example.net")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(records.CDS) != 1 || records.CDS[0] != testDSECDSAP384SHA384 || records.DeleteSignal {
+		t.Errorf("Invalid CDS records: %+v", records)
+	}
+	if len(records.CDNSKEY) != 1 || records.CDNSKEY[0] != testDNSKEYECDSAP384 {
+		t.Errorf("Invalid CDNSKEY records: %+v", records)
+	}
+
+	if err := p.Zones.PublishDeleteDS(ctx, "example.net", 3600); err != nil {
+		t.Fatalf("%s", err)
+	}
+	records, err = p.Zones.CDSRecords(ctx, "example.net")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !records.DeleteSignal || records.CDNSKEY[0] != "0 3 0 AA==" {
+		t.Errorf("Invalid delete signal: %+v", records)
+	}
+
+	if err := p.Zones.WithdrawDeleteDS(ctx, "example.net"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	records, err = p.Zones.CDSRecords(ctx, "example.net")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if records.DeleteSignal || len(records.CDS) != 0 || len(records.CDNSKEY) != 0 {
+		t.Errorf("Invalid records after withdrawal: %+v", records)
+	}
+}
+
+func TestCDSPublicationError(t *testing.T) {
+	p := initialisePowerDNSTestClient()
+	p.Port = "x"
+	ctx := context.Background()
+	if _, err := p.Zones.CDSPublication(ctx, "example.com"); err == nil {
+		t.Error("error is nil")
+	}
+	if err := p.Zones.SetCDSPublication(ctx, "example.com", &CDSPublication{CDNSKEY: true}); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.Zones.CDSRecords(ctx, "example.com"); err == nil {
+		t.Error("error is nil")
+	}
+	if err := p.Zones.PublishDeleteDS(ctx, "example.com", 3600); err == nil {
+		t.Error("error is nil")
+	}
+	if err := p.Zones.WithdrawDeleteDS(ctx, "example.com"); err == nil {
+		t.Error("error is nil")
+	}
+}
@@ -2,6 +2,7 @@ package powerdns
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 )
@@ -15,6 +16,7 @@ type Cryptokey struct {
 	ID         *uint64  `json:"id,omitempty"`
 	KeyType    *string  `json:"keytype,omitempty"`
 	Active     *bool    `json:"active,omitempty"`
+	Published  *bool    `json:"published,omitempty"`
 	DNSkey     *string  `json:"dnskey,omitempty"`
 	DS         []string `json:"ds,omitempty"`
 	Privatekey *string  `json:"privatekey,omitempty"`
@@ -22,13 +24,68 @@ type Cryptokey struct {
 	Bits       *uint64  `json:"bits,omitempty"`
 }
 
+// CryptokeyType represents the type of a Cryptokey
+type CryptokeyType string
+
+const (
+	// CryptokeyTypeKSK is a key signing key
+	CryptokeyTypeKSK CryptokeyType = "ksk"
+	// CryptokeyTypeZSK is a zone signing key
+	CryptokeyTypeZSK CryptokeyType = "zsk"
+	// CryptokeyTypeCSK is a combined signing key
+	CryptokeyTypeCSK CryptokeyType = "csk"
+)
+
+// CryptokeySpec describes a Cryptokey to be created.
+// Either Algorithm and optionally Bits are set to let the server generate a key, or PrivateKey is set to import an existing key in ISC format.
+type CryptokeySpec struct {
+	KeyType    CryptokeyType
+	Algorithm  string
+	Bits       uint64
+	PrivateKey string
+	// Active defaults to false on the server
+	Active *bool
+	// Published defaults to true on the server
+	Published *bool
+}
+
+func (s CryptokeySpec) cryptokey() (*Cryptokey, error) {
+	switch s.KeyType {
+	case CryptokeyTypeKSK, CryptokeyTypeZSK, CryptokeyTypeCSK:
+	default:
+		return nil, fmt.Errorf("invalid cryptokey type %q", s.KeyType)
+	}
+	if s.PrivateKey == "" && s.Algorithm == "" {
+		return nil, errors.New("either an algorithm or a private key is required")
+	}
+	if s.PrivateKey != "" && s.Bits != 0 {
+		return nil, errors.New("the key size of an imported private key cannot be changed")
+	}
+
+	cryptokey := &Cryptokey{
+		KeyType:   String(string(s.KeyType)),
+		Active:    s.Active,
+		Published: s.Published,
+	}
+	if s.Algorithm != "" {
+		cryptokey.Algorithm = String(s.Algorithm)
+	}
+	if s.Bits != 0 {
+		cryptokey.Bits = Uint64(s.Bits)
+	}
+	if s.PrivateKey != "" {
+		cryptokey.Privatekey = String(s.PrivateKey)
+	}
+	return cryptokey, nil
+}
+
 func cryptokeyIDToString(id uint64) string {
 	return strconv.FormatUint(id, 10)
 }
 
 // List retrieves a list of Cryptokeys that belong to a Zone
 func (c *CryptokeysService) List(ctx context.Context, domain string) ([]Cryptokey, error) {
-	req, err := c.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s/cryptokeys", c.client.VHost, trimDomain(domain)), nil, nil)
+	req, err := c.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s/cryptokeys", c.client.VHost, escapeZoneID(domain)), nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +97,7 @@ func (c *CryptokeysService) List(ctx context.Context, domain string) ([]Cryptoke
 
 // Get returns a certain Cryptokey instance of a given Zone
 func (c *CryptokeysService) Get(ctx context.Context, domain string, id uint64) (*Cryptokey, error) {
-	req, err := c.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s/cryptokeys/%s", c.client.VHost, trimDomain(domain), cryptokeyIDToString(id)), nil, nil)
+	req, err := c.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s/cryptokeys/%s", c.client.VHost, escapeZoneID(domain), cryptokeyIDToString(id)), nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -50,13 +107,79 @@ func (c *CryptokeysService) Get(ctx context.Context, domain string, id uint64) (
 	return cryptokey, err
 }
 
+// Create generates or imports a Cryptokey for a given Zone
+func (c *CryptokeysService) Create(ctx context.Context, domain string, spec CryptokeySpec) (*Cryptokey, error) {
+	cryptokey, err := spec.cryptokey()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.client.newRequest(ctx, "POST", fmt.Sprintf("servers/%s/zones/%s/cryptokeys", c.client.VHost, escapeZoneID(domain)), nil, cryptokey)
+	if err != nil {
+		return nil, err
+	}
+
+	createdCryptokey := new(Cryptokey)
+	if _, err = c.client.do(req, &createdCryptokey); err != nil {
+		return createdCryptokey, err
+	}
+	return createdCryptokey, c.client.syncDelegation(ctx, domain)
+}
+
+// Activate enables signing with a given Cryptokey
+func (c *CryptokeysService) Activate(ctx context.Context, domain string, id uint64) error {
+	return c.change(ctx, domain, id, func(cryptokey *Cryptokey) { cryptokey.Active = Bool(true) })
+}
+
+// Deactivate disables signing with a given Cryptokey
+func (c *CryptokeysService) Deactivate(ctx context.Context, domain string, id uint64) error {
+	return c.change(ctx, domain, id, func(cryptokey *Cryptokey) { cryptokey.Active = Bool(false) })
+}
+
+// Publish adds the DNSKEY record of a given Cryptokey to the Zone
+func (c *CryptokeysService) Publish(ctx context.Context, domain string, id uint64) error {
+	return c.change(ctx, domain, id, func(cryptokey *Cryptokey) { cryptokey.Published = Bool(true) })
+}
+
+// Unpublish removes the DNSKEY record of a given Cryptokey from the Zone
+func (c *CryptokeysService) Unpublish(ctx context.Context, domain string, id uint64) error {
+	return c.change(ctx, domain, id, func(cryptokey *Cryptokey) { cryptokey.Published = Bool(false) })
+}
+
+// change applies a modification to the current state of a Cryptokey.
+// The server requires the active flag and defaults the published flag to true, so both are always sent.
+func (c *CryptokeysService) change(ctx context.Context, domain string, id uint64, modify func(*Cryptokey)) error {
+	current, err := c.Get(ctx, domain, id)
+	if err != nil {
+		return err
+	}
+
+	cryptokey := &Cryptokey{Active: Bool(BoolValue(current.Active)), Published: Bool(true)}
+	if current.Published != nil {
+		cryptokey.Published = Bool(*current.Published)
+	}
+	modify(cryptokey)
+
+	req, err := c.client.newRequest(ctx, "PUT", fmt.Sprintf("servers/%s/zones/%s/cryptokeys/%s", c.client.VHost, escapeZoneID(domain), cryptokeyIDToString(id)), nil, cryptokey)
+	if err != nil {
+		return err
+	}
+
+	if _, err = c.client.do(req, nil); err != nil {
+		return err
+	}
+	return c.client.syncDelegation(ctx, domain)
+}
+
 // Delete removes a given Cryptokey
 func (c *CryptokeysService) Delete(ctx context.Context, domain string, id uint64) error {
-	req, err := c.client.newRequest(ctx, "DELETE", fmt.Sprintf("servers/%s/zones/%s/cryptokeys/%s", c.client.VHost, trimDomain(domain), cryptokeyIDToString(id)), nil, nil)
+	req, err := c.client.newRequest(ctx, "DELETE", fmt.Sprintf("servers/%s/zones/%s/cryptokeys/%s", c.client.VHost, escapeZoneID(domain), cryptokeyIDToString(id)), nil, nil)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.client.do(req, nil)
-	return err
+	if _, err = c.client.do(req, nil); err != nil {
+		return err
+	}
+	return c.client.syncDelegation(ctx, domain)
 }
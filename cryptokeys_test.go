@@ -2,6 +2,7 @@ package powerdns
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"testing"
@@ -173,3 +174,186 @@ func TestDeleteCryptokeyError(t *testing.T) {
 		t.Error("error is nil")
 	}
 }
+
+func registerCryptokeyCreateMockResponder(testDomain string, created *Cryptokey) {
+	httpmock.RegisterResponder("POST", generateTestAPIVHostURL()+"/zones/"+testDomain+"/cryptokeys",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			if json.NewDecoder(req.Body).Decode(created) != nil || created.KeyType == nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+
+			cryptokeyMock := *created
+			cryptokeyMock.Type = String("Cryptokey")
+			cryptokeyMock.ID = Uint64(12)
+			cryptokeyMock.DNSkey = String("257 3 13 thisIsTheKey")
+			return httpmock.NewJsonResponse(http.StatusCreated, cryptokeyMock)
+		},
+	)
+}
+
+func registerCryptokeyChangeMockResponder(testDomain string, id uint64, current *Cryptokey, changes *[]Cryptokey) {
+	url := fmt.Sprintf("%s/zones/%s/cryptokeys/%s", generateTestAPIVHostURL(), testDomain, cryptokeyIDToString(id))
+	httpmock.RegisterResponder("GET", url,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, current)
+		},
+	)
+
+	httpmock.RegisterResponder("PUT", url,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var cryptokey Cryptokey
+			if json.NewDecoder(req.Body).Decode(&cryptokey) != nil || cryptokey.Active == nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+			*changes = append(*changes, cryptokey)
+			current.Active = cryptokey.Active
+			current.Published = cryptokey.Published
+			if current.Published == nil {
+				current.Published = Bool(true)
+			}
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+}
+
+func TestCreateCryptokey(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var sent Cryptokey
+	registerCryptokeyCreateMockResponder(testDomain, &sent)
+
+	p := initialisePowerDNSTestClient()
+	cryptokey, err := p.Cryptokeys.Create(context.Background(), testDomain, CryptokeySpec{
+		KeyType:   CryptokeyTypeKSK,
+		Algorithm: "ECDSAP256SHA256",
+		Active:    Bool(true),
+		Published: Bool(false),
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if *cryptokey.ID != 12 || *cryptokey.KeyType != "ksk" {
+		t.Errorf("Invalid cryptokey: %+v", cryptokey)
+	}
+	if !*sent.Active || *sent.Published || sent.Bits != nil || sent.Privatekey != nil {
+		t.Errorf("Invalid payload: %+v", sent)
+	}
+}
+
+func TestImportCryptokey(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var sent Cryptokey
+	registerCryptokeyCreateMockResponder(testDomain, &sent)
+
+	p := initialisePowerDNSTestClient()
+	privateKey := "Private-key-format: v1.2\nAlgorithm: 13 (ECDSAP256SHA256)\nPrivateKey: foo\n"
+	if _, err := p.Cryptokeys.Create(context.Background(), testDomain, CryptokeySpec{KeyType: CryptokeyTypeCSK, PrivateKey: privateKey}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if *sent.Privatekey != privateKey || sent.Algorithm != nil || sent.Active != nil || sent.Published != nil {
+		t.Errorf("Invalid payload: %+v", sent)
+	}
+}
+
+func TestCreateCryptokeyInvalidSpec(t *testing.T) {
+	testDomain := generateTestZone(false)
+	p := initialisePowerDNSTestClient()
+
+	specs := []CryptokeySpec{
+		{KeyType: "lsk", Algorithm: "ED25519"},
+		{KeyType: CryptokeyTypeZSK},
+		{KeyType: CryptokeyTypeZSK, PrivateKey: "foo", Bits: 2048},
+	}
+	for _, spec := range specs {
+		if _, err := p.Cryptokeys.Create(context.Background(), testDomain, spec); err == nil {
+			t.Errorf("error is nil for %+v", spec)
+		}
+	}
+}
+
+func TestCreateCryptokeyError(t *testing.T) {
+	testDomain := generateTestZone(false)
+	p := initialisePowerDNSTestClient()
+	p.Port = "x"
+	if _, err := p.Cryptokeys.Create(context.Background(), testDomain, CryptokeySpec{KeyType: CryptokeyTypeZSK, Algorithm: "ED25519"}); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestChangeCryptokeyState(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	current := &Cryptokey{ID: Uint64(11), KeyType: String("zsk"), Active: Bool(false), Published: Bool(true)}
+	changes := make([]Cryptokey, 0)
+	registerCryptokeyChangeMockResponder(testDomain, 11, current, &changes)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+	for _, change := range []func(context.Context, string, uint64) error{p.Cryptokeys.Activate, p.Cryptokeys.Deactivate, p.Cryptokeys.Publish, p.Cryptokeys.Unpublish} {
+		if err := change(ctx, testDomain, 11); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	if len(changes) != 4 {
+		t.Fatalf("Invalid amount of changes: %d", len(changes))
+	}
+	for _, change := range changes {
+		if change.Active == nil || change.Published == nil {
+			t.Fatalf("Active and published must both be sent: %+v", change)
+		}
+	}
+	if !*changes[0].Active || *changes[1].Active || !*changes[0].Published || !*changes[1].Published {
+		t.Errorf("Invalid activation changes: %+v", changes[:2])
+	}
+	if !*changes[2].Published || *changes[3].Published || *changes[2].Active || *changes[3].Active {
+		t.Errorf("Invalid publication changes: %+v", changes[2:])
+	}
+}
+
+func TestChangeCryptokeyStateKeepsOtherFlag(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	current := &Cryptokey{ID: Uint64(11), KeyType: String("ksk"), Active: Bool(true), Published: Bool(false)}
+	changes := make([]Cryptokey, 0)
+	registerCryptokeyChangeMockResponder(testDomain, 11, current, &changes)
+
+	p := initialisePowerDNSTestClient()
+	if err := p.Cryptokeys.Deactivate(context.Background(), testDomain, 11); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *current.Active || *current.Published {
+		t.Errorf("Deactivation must not publish the key: %+v", current)
+	}
+}
+
+func TestChangeCryptokeyStateError(t *testing.T) {
+	testDomain := generateTestZone(false)
+	p := initialisePowerDNSTestClient()
+	p.Port = "x"
+	if err := p.Cryptokeys.Activate(context.Background(), testDomain, 11); err == nil {
+		t.Error("error is nil")
+	}
+}
@@ -0,0 +1,377 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DelegationManager keeps NS and DS records in parent zones in sync with child zones hosted on the same server
+type DelegationManager struct {
+	client *Client
+
+	// DigestTypes defines which DS records are written into the parent zone
+	DigestTypes []DSDigestType
+}
+
+// DelegationStatus describes the differences between a child zone and its delegation in the parent zone
+type DelegationStatus struct {
+	Parent       string
+	Child        string
+	MissingNS    []string
+	UnexpectedNS []string
+	MissingDS    []string
+	UnexpectedDS []string
+}
+
+// InSync reports whether the delegation matches the child zone
+func (s *DelegationStatus) InSync() bool {
+	return len(s.MissingNS) == 0 && len(s.UnexpectedNS) == 0 && len(s.MissingDS) == 0 && len(s.UnexpectedDS) == 0
+}
+
+// EnableDelegationManagement keeps delegations in sync whenever zones are created or deleted and whenever cryptokeys are changed through this client
+func (p *Client) EnableDelegationManagement() *DelegationManager {
+	p.delegationManager = p.NewDelegationManager()
+	return p.delegationManager
+}
+
+// DisableDelegationManagement disables the automatic delegation sync
+func (p *Client) DisableDelegationManagement() {
+	p.delegationManager = nil
+}
+
+// NewDelegationManager creates a DelegationManager which writes SHA-256 DS records
+func (p *Client) NewDelegationManager() *DelegationManager {
+	return &DelegationManager{client: p, DigestTypes: []DSDigestType{DigestSHA256}}
+}
+
+// DelegationSyncError is returned if a zone or cryptokey has been changed, but the delegation in the parent zone could not be updated afterwards
+type DelegationSyncError struct {
+	Zone string
+	Err  error
+}
+
+func (e DelegationSyncError) Error() string {
+	return fmt.Sprintf("delegation of zone %s has not been synced: %v", e.Zone, e.Err)
+}
+
+func (e DelegationSyncError) Unwrap() error {
+	return e.Err
+}
+
+func (p *Client) syncDelegation(ctx context.Context, domain string) error {
+	if p.delegationManager == nil {
+		return nil
+	}
+	if err := p.delegationManager.Sync(ctx, domain); err != nil {
+		return DelegationSyncError{Zone: domain, Err: err}
+	}
+	return nil
+}
+
+func (p *Client) removeDelegation(ctx context.Context, domain string) error {
+	if p.delegationManager == nil {
+		return nil
+	}
+	if err := p.delegationManager.Remove(ctx, domain); err != nil {
+		return DelegationSyncError{Zone: domain, Err: err}
+	}
+	return nil
+}
+
+// parentName returns the name of the parent of a canonical domain name
+func parentName(domain string) string {
+	if i := strings.Index(domain, "."); i >= 0 && i < len(domain)-1 {
+		return domain[i+1:]
+	}
+	return "."
+}
+
+// parentZone returns the name of the zone hosted on the server which encloses the parent of a child zone, or an empty string
+func (d *DelegationManager) parentZone(ctx context.Context, child string) (string, error) {
+	if child == "." {
+		return "", nil
+	}
+
+	parent, err := d.client.Zones.findEnclosing(ctx, parentName(child))
+	if isNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(makeDomainCanonical(StringValue(parent.Name))), nil
+}
+
+// Sync writes the NS, DS and glue records of a child zone into its parent zone hosted on the server.
+// If the parent zone is not hosted on the server, nothing is changed.
+// Glue records are copies of records in the child zone, so PTR management does not apply to them.
+func (d *DelegationManager) Sync(ctx context.Context, domain string) error {
+	child := strings.ToLower(makeDomainCanonical(idnToASCIIOrRaw(domain)))
+	parent, err := d.parentZone(ctx, child)
+	if err != nil || parent == "" {
+		return err
+	}
+
+	childZone, err := d.client.Zones.get(ctx, child)
+	if err != nil {
+		return err
+	}
+	parentZone, err := d.client.Zones.get(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	ns, ds, err := d.expected(ctx, child, childZone)
+	if err != nil {
+		return err
+	}
+
+	rrsets := []RRset{
+		delegationRRSet(child, RRTypeNS, Uint32Value(ns.TTL), nsContents(ns)),
+		delegationRRSet(child, RRTypeDS, Uint32Value(ns.TTL), ds),
+	}
+
+	// Glue is copied for name servers within the child zone, stale glue is removed
+	targets := nsContents(ns)
+	for _, target := range glueNames(child, findRRSet(parentZone.RRsets, child, RRTypeNS)) {
+		if !containsFold(targets, target) {
+			rrsets = append(rrsets, delegationRRSet(target, RRTypeA, 0, nil), delegationRRSet(target, RRTypeAAAA, 0, nil))
+		}
+	}
+	for _, target := range targets {
+		if !isSubdomain(target, child) {
+			continue
+		}
+		for _, recordType := range []RRType{RRTypeA, RRTypeAAAA} {
+			glue := findRRSet(childZone.RRsets, target, recordType)
+			if glue == nil {
+				rrsets = append(rrsets, delegationRRSet(target, recordType, 0, nil))
+				continue
+			}
+			content := make([]string, 0, len(glue.Records))
+			for _, record := range glue.Records {
+				content = append(content, StringValue(record.Content))
+			}
+			rrsets = append(rrsets, delegationRRSet(target, recordType, Uint32Value(glue.TTL), content))
+		}
+	}
+
+	return d.client.Records.sendRRSets(ctx, parent, &RRsets{Sets: rrsets})
+}
+
+// Remove deletes the NS, DS and glue records of a child zone from its parent zone hosted on the server
+func (d *DelegationManager) Remove(ctx context.Context, domain string) error {
+	child := strings.ToLower(makeDomainCanonical(idnToASCIIOrRaw(domain)))
+	parent, err := d.parentZone(ctx, child)
+	if err != nil || parent == "" {
+		return err
+	}
+
+	parentZone, err := d.client.Zones.get(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	rrsets := []RRset{
+		delegationRRSet(child, RRTypeNS, 0, nil),
+		delegationRRSet(child, RRTypeDS, 0, nil),
+	}
+	for _, target := range glueNames(child, findRRSet(parentZone.RRsets, child, RRTypeNS)) {
+		rrsets = append(rrsets, delegationRRSet(target, RRTypeA, 0, nil), delegationRRSet(target, RRTypeAAAA, 0, nil))
+	}
+
+	return d.client.Records.sendRRSets(ctx, parent, &RRsets{Sets: rrsets})
+}
+
+// OutOfSync compares all child zones with their parent zones hosted on the server and returns the delegations which differ
+func (d *DelegationManager) OutOfSync(ctx context.Context) ([]DelegationStatus, error) {
+	zones, err := d.client.Zones.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := make(map[string]*Zone)
+	statuses := make([]DelegationStatus, 0)
+	for i := range zones {
+		child := strings.ToLower(makeDomainCanonical(StringValue(zones[i].Name)))
+		if child == "." {
+			continue
+		}
+		enclosing := findEnclosingZone(zones, parentName(child))
+		if enclosing == nil {
+			continue
+		}
+		parent := strings.ToLower(makeDomainCanonical(StringValue(enclosing.Name)))
+
+		parentZone, ok := parents[parent]
+		if !ok {
+			if parentZone, err = d.client.Zones.get(ctx, parent); err != nil {
+				return nil, err
+			}
+			parents[parent] = parentZone
+		}
+
+		childZone, err := d.client.Zones.get(ctx, child)
+		if err != nil {
+			return nil, err
+		}
+		ns, ds, err := d.expected(ctx, child, childZone)
+		if err != nil {
+			return nil, err
+		}
+
+		status := DelegationStatus{Parent: parent, Child: child}
+		status.MissingNS, status.UnexpectedNS = diffContents(nsContents(ns), nsContents(findRRSet(parentZone.RRsets, child, RRTypeNS)), normalizeNS)
+		status.MissingDS, status.UnexpectedDS = diffContents(ds, rrsetContents(findRRSet(parentZone.RRsets, child, RRTypeDS)), normalizeDS)
+		if !status.InSync() {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses, nil
+}
+
+// expected returns the apex NS RRset and the DS contents of a child zone.
+// DS records are generated for all published KSKs and CSKs as long as at least one of them is active.
+// Unpublished keys are missing from the DNSKEY RRset of the child, so they are skipped.
+func (d *DelegationManager) expected(ctx context.Context, child string, childZone *Zone) (*RRset, []string, error) {
+	ns := findRRSet(childZone.RRsets, child, RRTypeNS)
+	if ns == nil || len(ns.Records) == 0 {
+		return nil, nil, fmt.Errorf("zone %s has no NS records", child)
+	}
+
+	cryptokeys, err := d.client.Cryptokeys.List(ctx, child)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	active := false
+	ds := make([]string, 0)
+	for _, cryptokey := range cryptokeys {
+		keyType := CryptokeyType(StringValue(cryptokey.KeyType))
+		if keyType != CryptokeyTypeKSK && keyType != CryptokeyTypeCSK {
+			continue
+		}
+		if cryptokey.Published != nil && !*cryptokey.Published {
+			continue
+		}
+		active = active || BoolValue(cryptokey.Active)
+
+		dnskey, err := cryptokey.ParsedDNSKEY()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, digestType := range d.DigestTypes {
+			record, err := dnskey.DS(child, digestType)
+			if err != nil {
+				return nil, nil, err
+			}
+			ds = append(ds, record.String())
+		}
+	}
+	if !active {
+		ds = ds[:0]
+	}
+
+	return ns, ds, nil
+}
+
+func delegationRRSet(name string, recordType RRType, ttl uint32, content []string) RRset {
+	rrset := RRset{
+		Name: String(name),
+		Type: RRTypePtr(recordType),
+	}
+	if len(content) == 0 {
+		rrset.ChangeType = ChangeTypePtr(ChangeTypeDelete)
+		return rrset
+	}
+
+	rrset.ChangeType = ChangeTypePtr(ChangeTypeReplace)
+	rrset.TTL = Uint32(ttl)
+	rrset.Records = make([]Record, 0, len(content))
+	for _, c := range content {
+		rrset.Records = append(rrset.Records, Record{Content: String(c), Disabled: Bool(false), SetPTR: Bool(false)})
+	}
+	return rrset
+}
+
+func rrsetContents(rrset *RRset) []string {
+	if rrset == nil {
+		return nil
+	}
+	content := make([]string, 0, len(rrset.Records))
+	for _, record := range rrset.Records {
+		content = append(content, StringValue(record.Content))
+	}
+	return content
+}
+
+func nsContents(rrset *RRset) []string {
+	content := rrsetContents(rrset)
+	for i := range content {
+		content[i] = normalizeNS(content[i])
+	}
+	return content
+}
+
+// glueNames returns the name servers of an NS RRset which are located within the child zone
+func glueNames(child string, ns *RRset) []string {
+	names := make([]string, 0)
+	for _, target := range nsContents(ns) {
+		if isSubdomain(target, child) {
+			names = append(names, target)
+		}
+	}
+	return names
+}
+
+func normalizeNS(content string) string {
+	return strings.ToLower(makeDomainCanonical(content))
+}
+
+func normalizeDS(content string) string {
+	if ds, err := ParseDS(content); err == nil {
+		return ds.String()
+	}
+	return strings.ToLower(content)
+}
+
+// diffContents returns the expected contents which are missing and the actual contents which are not expected
+func diffContents(expected []string, actual []string, normalize func(string) string) ([]string, []string) {
+	expectedSet := make(map[string]bool, len(expected))
+	for _, c := range expected {
+		expectedSet[normalize(c)] = true
+	}
+	actualSet := make(map[string]bool, len(actual))
+	for _, c := range actual {
+		actualSet[normalize(c)] = true
+	}
+
+	missing := make([]string, 0)
+	for c := range expectedSet {
+		if !actualSet[c] {
+			missing = append(missing, c)
+		}
+	}
+	unexpected := make([]string, 0)
+	for c := range actualSet {
+		if !expectedSet[c] {
+			unexpected = append(unexpected, c)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
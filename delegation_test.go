@@ -0,0 +1,235 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func registerDelegationMockResponder(child string, cryptokeys []Cryptokey) {
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones/"+escapeZoneID(child)+"/cryptokeys",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, cryptokeys)
+		},
+	)
+}
+
+func newDelegationTestZones() map[string][]RRset {
+	return map[string][]RRset{
+		"example.com.": {
+			{Name: String("example.com."), Type: RRTypePtr(RRTypeNS), TTL: Uint32(3600), Records: []Record{{Content: String("ns.example.com.")}}},
+		},
+		"child.example.com.": {
+			{Name: String("child.example.com."), Type: RRTypePtr(RRTypeNS), TTL: Uint32(1800), Records: []Record{{Content: String("ns1.child.example.com.")}, {Content: String("NS.example.net")}}},
+			{Name: String("ns1.child.example.com."), Type: RRTypePtr(RRTypeA), TTL: Uint32(600), Records: []Record{{Content: String("192.0.2.53")}}},
+		},
+	}
+}
+
+func TestDelegationSync(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := newDelegationTestZones()
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+	registerDelegationMockResponder("child.example.com", []Cryptokey{
+		{ID: Uint64(1), KeyType: String("ksk"), Active: Bool(true), DNSkey: String(testDNSKEYECDSAP384)},
+		{ID: Uint64(2), KeyType: String("zsk"), Active: Bool(true), DNSkey: String(testDNSKEYRSASHA1)},
+		{ID: Uint64(3), KeyType: String("ksk"), Active: Bool(false), Published: Bool(false), DNSkey: String(testDNSKEYED25519)},
+	})
+
+	p := initialisePowerDNSTestClient()
+	manager := p.NewDelegationManager()
+	manager.DigestTypes = []DSDigestType{DigestSHA384}
+	ctx := context.Background()
+
+	statuses, err := manager.OutOfSync(ctx)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(statuses) != 1 || statuses[0].Child != "child.example.com." || statuses[0].Parent != "example.com." || len(statuses[0].MissingNS) != 2 || len(statuses[0].MissingDS) != 1 {
+		t.Fatalf("Invalid statuses: %+v", statuses)
+	}
+
+	if err := manager.Sync(ctx, "child.example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ns := findRRSet(zones["example.com."], "child.example.com.", RRTypeNS)
+	if ns == nil || len(ns.Records) != 2 || *ns.TTL != 1800 || *ns.Records[1].Content != "ns.example.net." {
+		t.Errorf("Invalid NS delegation: %+v", ns)
+	}
+	ds := findRRSet(zones["example.com."], "child.example.com.", RRTypeDS)
+	if ds == nil || len(ds.Records) != 1 {
+		t.Fatalf("Invalid DS delegation: %+v", ds)
+	}
+	key, _ := ParseDNSKEY(testDNSKEYECDSAP384)
+	expected, _ := key.DS("child.example.com.", DigestSHA384)
+	if *ds.Records[0].Content != expected.String() {
+		t.Errorf("Invalid DS %s, want %s", *ds.Records[0].Content, expected)
+	}
+	if glue := findRRSet(zones["example.com."], "ns1.child.example.com.", RRTypeA); glue == nil || *glue.Records[0].Content != "192.0.2.53" {
+		t.Errorf("Invalid glue: %+v", glue)
+	}
+
+	if statuses, err = manager.OutOfSync(ctx); err != nil || len(statuses) != 0 {
+		t.Errorf("Delegation is still out of sync: %+v, %v", statuses, err)
+	}
+
+	if err := manager.Remove(ctx, "child.example.com."); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(zones["example.com."]) != 1 {
+		t.Errorf("Delegation has not been removed: %+v", zones["example.com."])
+	}
+}
+
+func TestDelegationSyncWithPTRManagement(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := newDelegationTestZones()
+	zones["2.0.192.in-addr.arpa."] = []RRset{
+		{Name: String("53.2.0.192.in-addr.arpa."), Type: RRTypePtr(RRTypePTR), TTL: Uint32(600), Records: []Record{{Content: String("ns1.child.example.com.")}}},
+	}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+	registerDelegationMockResponder("child.example.com", []Cryptokey{})
+
+	p := initialisePowerDNSTestClient()
+	p.EnablePTRManagement(PTRConflictFail)
+	manager := p.NewDelegationManager()
+	ctx := context.Background()
+
+	if err := manager.Sync(ctx, "child.example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := manager.Remove(ctx, "child.example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched["example.com."]) == 0 {
+		t.Fatal("Delegation has not been written")
+	}
+	if reverse := patched["2.0.192.in-addr.arpa."]; len(reverse) != 0 {
+		t.Errorf("Glue records changed PTR records: %+v", reverse)
+	}
+}
+
+func TestDelegationSyncUnsigned(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := newDelegationTestZones()
+	zones["example.com."] = append(zones["example.com."], RRset{Name: String("child.example.com."), Type: RRTypePtr(RRTypeDS), TTL: Uint32(3600), Records: []Record{{Content: String("1 13 2 abcdef")}}})
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+	registerDelegationMockResponder("child.example.com", []Cryptokey{
+		{ID: Uint64(1), KeyType: String("ksk"), Active: Bool(false), DNSkey: String(testDNSKEYECDSAP384)},
+	})
+
+	p := initialisePowerDNSTestClient()
+	manager := p.NewDelegationManager()
+	ctx := context.Background()
+
+	statuses, err := manager.OutOfSync(ctx)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(statuses) != 1 || len(statuses[0].UnexpectedDS) != 1 || statuses[0].InSync() {
+		t.Fatalf("Invalid statuses: %+v", statuses)
+	}
+
+	if err := manager.Sync(ctx, "child.example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if ds := findRRSet(zones["example.com."], "child.example.com.", RRTypeDS); ds != nil {
+		t.Errorf("DS of an unsigned zone has not been removed: %+v", ds)
+	}
+}
+
+func TestDelegationWithoutParent(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.org.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	manager := p.NewDelegationManager()
+	if err := manager.Sync(context.Background(), "example.org"); err != nil {
+		t.Errorf("%s", err)
+	}
+	if err := manager.Remove(context.Background(), "example.org"); err != nil {
+		t.Errorf("%s", err)
+	}
+	if len(patched) != 0 {
+		t.Errorf("Unexpected changes: %+v", patched)
+	}
+}
+
+func TestDelegationManagement(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := newDelegationTestZones()
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+	registerDelegationMockResponder("child.example.com", []Cryptokey{})
+	httpmock.RegisterResponder("POST", generateTestAPIVHostURL()+"/zones",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(http.StatusCreated, Zone{Name: String("child.example.com.")})
+		},
+	)
+	httpmock.RegisterResponder("DELETE", generateTestAPIVHostURL()+"/zones/child.example.com",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+
+	p := initialisePowerDNSTestClient()
+	p.EnableDelegationManagement()
+	ctx := context.Background()
+
+	if _, err := p.Zones.AddNative(ctx, "child.example.com", false, "", false, "", "", false, []string{"ns1.child.example.com."}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if ns := findRRSet(zones["example.com."], "child.example.com.", RRTypeNS); ns == nil {
+		t.Error("Delegation has not been created")
+	}
+
+	if err := p.Zones.Delete(ctx, "child.example.com"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if ns := findRRSet(zones["example.com."], "child.example.com.", RRTypeNS); ns != nil {
+		t.Error("Delegation has not been removed")
+	}
+
+	// The zone is created although the child has no NS records to delegate to
+	zones["child.example.com."] = nil
+	zone, err := p.Zones.AddNative(ctx, "child.example.com", false, "", false, "", "", false, nil)
+	var syncErr DelegationSyncError
+	if !errors.As(err, &syncErr) || syncErr.Zone != "child.example.com." {
+		t.Fatalf("Expected DelegationSyncError, got %v", err)
+	}
+	if zone == nil || StringValue(zone.Name) != "child.example.com." {
+		t.Errorf("Created zone has not been returned: %+v", zone)
+	}
+
+	p.DisableDelegationManagement()
+	patched["example.com."] = nil
+	if _, err := p.Zones.AddNative(ctx, "child.example.com", false, "", false, "", "", false, []string{"ns1.child.example.com."}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(patched["example.com."]) != 0 {
+		t.Error("Delegation has been changed although the management is disabled")
+	}
+}
@@ -0,0 +1,401 @@
+package powerdns
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNS message constants (RFC 1035, section 4.1.1 and RFC 6891, section 6.1)
+const (
+	dnsHeaderLength    = 12
+	dnsFlagQR          = 0x8000
+	dnsFlagAA          = 0x0400
+	dnsFlagTC          = 0x0200
+	dnsFlagAD          = 0x0020
+	dnsTypeOPT         = 41
+	ednsFlagDO         = 0x8000
+	defaultEDNSUDPSize = 1232
+	defaultDNSTimeout  = 5 * time.Second
+)
+
+// DNS response codes (RFC 1035, section 4.1.1)
+const (
+	DNSRcodeSuccess  = 0
+	DNSRcodeServFail = 2
+	DNSRcodeNXDomain = 3
+	DNSRcodeRefused  = 5
+)
+
+// DNSClient sends queries to a name server, e.g. to verify the data served after changes made through the API.
+// Queries are sent via UDP with EDNS0 and repeated via TCP if the response is truncated.
+type DNSClient struct {
+	// Server is the address of the name server. The port defaults to 53.
+	Server string
+	// Timeout limits the duration of a query, if the context has no earlier deadline
+	Timeout time.Duration
+	// DNSSEC sets the DO bit, so that the server includes RRSIG records
+	DNSSEC bool
+	// UDPSize is the EDNS0 payload size announced to the server
+	UDPSize uint16
+}
+
+// DNSResponse contains the RRsets of a DNS response
+type DNSResponse struct {
+	Rcode             int
+	Authoritative     bool
+	Truncated         bool
+	AuthenticatedData bool
+	Answer            []RRset
+	Authority         []RRset
+	Additional        []RRset
+}
+
+// ServedMismatch describes an RRset which is not served as expected
+type ServedMismatch struct {
+	Name     string
+	Type     RRType
+	Expected []string
+	Served   []string
+	Problem  string
+}
+
+// NewDNSClient creates a DNS client for a name server like "192.0.2.53" or "[2001:db8::53]:5300"
+func NewDNSClient(server string) *DNSClient {
+	return &DNSClient{Server: server, Timeout: defaultDNSTimeout, UDPSize: defaultEDNSUDPSize}
+}
+
+func (c *DNSClient) address() string {
+	if _, _, err := net.SplitHostPort(c.Server); err == nil {
+		return c.Server
+	}
+	return net.JoinHostPort(strings.Trim(c.Server, "[]"), "53")
+}
+
+// Query sends a query for an RRset to the name server. Answers are not validated.
+func (c *DNSClient) Query(ctx context.Context, name string, recordType RRType) (*DNSResponse, error) {
+	query, err := c.packQuery(name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.exchange(ctx, "udp", query)
+	if err != nil {
+		return nil, err
+	}
+	if response.Truncated {
+		return c.exchange(ctx, "tcp", query)
+	}
+	return response, nil
+}
+
+// packQuery returns a query without recursion, including an OPT record (RFC 6891, section 6.1.2)
+func (c *DNSClient) packQuery(name string, recordType RRType) ([]byte, error) {
+	wireName, err := PackName(idnToASCIIOrRaw(name))
+	if err != nil {
+		return nil, err
+	}
+	typeCode, err := recordType.Code()
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 2)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	udpSize := c.UDPSize
+	if udpSize < 512 {
+		udpSize = 512
+	}
+	var ednsFlags uint32
+	if c.DNSSEC {
+		ednsFlags = ednsFlagDO
+	}
+
+	query := append(make([]byte, 0, dnsHeaderLength+len(wireName)+15), id...)
+	for _, field := range []uint16{0, 1, 0, 0, 1} {
+		query = appendUint16(query, field)
+	}
+	query = append(query, wireName...)
+	query = appendUint16(query, typeCode)
+	query = appendUint16(query, classIN)
+
+	// The OPT record carries the payload size in its class and the DO bit in its TTL
+	query = append(query, 0)
+	query = appendUint16(query, dnsTypeOPT)
+	query = appendUint16(query, udpSize)
+	query = appendUint32(query, ednsFlags)
+	return appendUint16(query, 0), nil
+}
+
+func (c *DNSClient) exchange(ctx context.Context, network string, query []byte) (*DNSResponse, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultDNSTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, c.address())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if network == "tcp" {
+		// Messages sent via TCP are prefixed with their length (RFC 1035, section 4.2.2)
+		if _, err := conn.Write(append(appendUint16(nil, uint16(len(query))), query...)); err != nil {
+			return nil, err
+		}
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, err
+		}
+		msg := make([]byte, readUint16(length))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return nil, err
+		}
+		return unpackDNSResponse(msg, query)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		// Responses with a different ID are ignored, they might be late answers to previous queries
+		if n < 2 || buffer[0] != query[0] || buffer[1] != query[1] {
+			continue
+		}
+		return unpackDNSResponse(buffer[:n], query)
+	}
+}
+
+// unpackDNSResponse parses a response and checks that it answers the query
+func unpackDNSResponse(msg []byte, query []byte) (*DNSResponse, error) {
+	if len(msg) < dnsHeaderLength {
+		return nil, errors.New("DNS response is too short")
+	}
+	if readUint16(msg) != readUint16(query) {
+		return nil, errors.New("DNS response ID does not match the query")
+	}
+	flags := readUint16(msg[2:])
+	if flags&dnsFlagQR == 0 {
+		return nil, errors.New("DNS message is not a response")
+	}
+
+	response := &DNSResponse{
+		Rcode:             int(flags & 0x000f),
+		Authoritative:     flags&dnsFlagAA != 0,
+		Truncated:         flags&dnsFlagTC != 0,
+		AuthenticatedData: flags&dnsFlagAD != 0,
+	}
+
+	offset := dnsHeaderLength
+	if readUint16(msg[4:]) != 1 {
+		if response.Truncated || response.Rcode != DNSRcodeSuccess {
+			return response, nil
+		}
+		return nil, errors.New("DNS response does not contain the question")
+	}
+	name, next, err := unpackName(msg, offset)
+	if err != nil {
+		return nil, err
+	}
+	if next+4 > len(msg) {
+		return nil, errors.New("truncated DNS question")
+	}
+	queryName, queryNext, err := unpackName(query, offset)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(name, queryName) || readUint16(msg[next:]) != readUint16(query[queryNext:]) {
+		return nil, fmt.Errorf("DNS response answers a different question for %s", name)
+	}
+	offset = next + 4
+
+	opt := RRTypeFromCode(dnsTypeOPT)
+	sections := []*[]RRset{&response.Answer, &response.Authority, &response.Additional}
+	for i, section := range sections {
+		count := int(readUint16(msg[6+2*i:]))
+		rrs := make([]*wireRR, 0, count)
+		for j := 0; j < count; j++ {
+			rr, next, err := unpackRR(msg, offset)
+			if err != nil {
+				if response.Truncated {
+					break
+				}
+				return nil, err
+			}
+			offset = next
+
+			if rr.rrType == opt {
+				// The upper eight bits of the TTL extend the response code (RFC 6891, section 6.1.3)
+				response.Rcode |= int(rr.ttl>>24) << 4
+				continue
+			}
+			if rr.class == classIN {
+				rrs = append(rrs, rr)
+			}
+		}
+		*section = groupRRs(rrs)
+	}
+	return response, nil
+}
+
+// SOASerial returns the serial of the SOA record served for a zone
+func (c *DNSClient) SOASerial(ctx context.Context, zone string) (uint32, error) {
+	zone = makeDomainCanonical(idnToASCIIOrRaw(zone))
+	response, err := c.Query(ctx, zone, RRTypeSOA)
+	if err != nil {
+		return 0, err
+	}
+	if response.Rcode != DNSRcodeSuccess {
+		return 0, fmt.Errorf("server answered the SOA query for %s with rcode %d", zone, response.Rcode)
+	}
+
+	soa := findRRSet(response.Answer, zone, RRTypeSOA)
+	if soa == nil || len(soa.Records) != 1 {
+		return 0, fmt.Errorf("server did not answer with the SOA record of %s", zone)
+	}
+	fields := strings.Fields(StringValue(soa.Records[0].Content))
+	if len(fields) != 7 {
+		return 0, fmt.Errorf("invalid SOA record %q", StringValue(soa.Records[0].Content))
+	}
+	serial, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SOA serial %q", fields[2])
+	}
+	return uint32(serial), nil
+}
+
+// VerifyServed queries every enabled RRset of a zone and reports RRsets which are served differently.
+// Delegation NS RRsets are expected in the authority section, glue and DNSSEC records generated by the server are skipped.
+// If DNSSEC is set, authoritative RRsets have to be served with RRSIG records.
+func (c *DNSClient) VerifyServed(ctx context.Context, zone string, rrsets []RRset) ([]ServedMismatch, error) {
+	signerZone, err := loadSignerZone(zone, rrsets, false)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := make([]ServedMismatch, 0)
+	for _, owner := range signerZone.sortedNames() {
+		typeCodes := make([]int, 0, len(owner.rrsets))
+		for typeCode := range owner.rrsets {
+			typeCodes = append(typeCodes, int(typeCode))
+		}
+		sort.Ints(typeCodes)
+
+		for _, typeCode := range typeCodes {
+			rrset := owner.rrsets[uint16(typeCode)]
+			// ALIAS and LUA records are served as the records they generate
+			if rrset.rrType == RRTypeALIAS || rrset.rrType == RRTypeLUA {
+				continue
+			}
+			if owner.delegation && rrset.rrType != RRTypeNS && rrset.rrType != RRTypeDS {
+				continue
+			}
+
+			mismatch, err := c.verifyRRset(ctx, owner, rrset)
+			if err != nil {
+				return nil, err
+			}
+			if mismatch != nil {
+				mismatches = append(mismatches, *mismatch)
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+func (c *DNSClient) verifyRRset(ctx context.Context, owner *signerName, rrset *signerRRset) (*ServedMismatch, error) {
+	mismatch := &ServedMismatch{Name: rrset.name, Type: rrset.rrType, Expected: rrset.contents, Served: make([]string, 0)}
+
+	response, err := c.Query(ctx, rrset.name, rrset.rrType)
+	if err != nil {
+		return nil, err
+	}
+	if response.Rcode != DNSRcodeSuccess {
+		mismatch.Problem = fmt.Sprintf("server answered with rcode %d", response.Rcode)
+		return mismatch, nil
+	}
+
+	section := response.Answer
+	if owner.delegation && rrset.rrType == RRTypeNS {
+		section = response.Authority
+	}
+	served := findRRSet(section, rrset.name, rrset.rrType)
+	if served == nil {
+		mismatch.Problem = "RRset is not served"
+		return mismatch, nil
+	}
+	mismatch.Served = rrsetContents(served)
+
+	servedRDATA := make([][]byte, 0, len(served.Records))
+	for _, content := range mismatch.Served {
+		rdata, err := PackCanonicalRDATA(rrset.rrType, content)
+		if err != nil {
+			return nil, err
+		}
+		servedRDATA = append(servedRDATA, rdata)
+	}
+	switch {
+	case !equalCanonicalRDATA(rrset.rdata, servedRDATA):
+		mismatch.Problem = "served content differs"
+	case Uint32Value(served.TTL) != rrset.ttl:
+		mismatch.Problem = fmt.Sprintf("served TTL %d differs from %d", Uint32Value(served.TTL), rrset.ttl)
+	case c.DNSSEC && owner.signed(rrset.typeCode) && !coveredBySignature(section, rrset):
+		mismatch.Problem = "RRset is served without RRSIG records"
+	default:
+		return nil, nil
+	}
+	return mismatch, nil
+}
+
+// equalCanonicalRDATA reports whether two RRsets contain the same records, ignoring order and duplicates
+func equalCanonicalRDATA(a [][]byte, b [][]byte) bool {
+	set := func(rdata [][]byte) map[string]bool {
+		s := make(map[string]bool, len(rdata))
+		for _, r := range rdata {
+			s[string(r)] = true
+		}
+		return s
+	}
+	setA, setB := set(a), set(b)
+	if len(setA) != len(setB) {
+		return false
+	}
+	for r := range setA {
+		if !setB[r] {
+			return false
+		}
+	}
+	return true
+}
+
+// coveredBySignature reports whether a section contains an RRSIG record covering an RRset
+func coveredBySignature(section []RRset, rrset *signerRRset) bool {
+	for _, content := range rrsetContents(findRRSet(section, rrset.name, RRTypeRRSIG)) {
+		if fields := strings.Fields(content); len(fields) > 0 && strings.EqualFold(fields[0], string(rrset.rrType)) {
+			return true
+		}
+	}
+	return false
+}
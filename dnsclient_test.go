@@ -0,0 +1,351 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dnsStubServer answers queries via UDP and TCP from a list of RRsets
+type dnsStubServer struct {
+	mu      sync.Mutex
+	zone    string
+	rrsets  []RRset
+	maxUDP  int
+	queries []string
+	udp     net.PacketConn
+	tcp     net.Listener
+}
+
+func startDNSStubServer(t *testing.T, zone string, rrsets []RRset) *dnsStubServer {
+	t.Helper()
+	s := &dnsStubServer{zone: zone, rrsets: rrsets, maxUDP: 512}
+	// UDP and TCP have to share a port, which might already be taken for TCP
+	for attempt := 0; s.tcp == nil; attempt++ {
+		udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+		if err != nil {
+			udp.Close()
+			if attempt == 10 {
+				t.Fatalf("%s", err)
+			}
+			continue
+		}
+		s.udp, s.tcp = udp, tcp
+	}
+	t.Cleanup(func() {
+		s.udp.Close()
+		s.tcp.Close()
+	})
+
+	go s.serveUDP()
+	go s.serveTCP()
+	return s
+}
+
+func (s *dnsStubServer) address() string {
+	return s.udp.LocalAddr().String()
+}
+
+func (s *dnsStubServer) serveUDP() {
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := s.udp.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		if response := s.answer(buffer[:n], "udp"); response != nil {
+			_, _ = s.udp.WriteTo(response, addr)
+		}
+	}
+}
+
+func (s *dnsStubServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			length := make([]byte, 2)
+			if _, err := io.ReadFull(conn, length); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			if response := s.answer(query, "tcp"); response != nil {
+				_, _ = conn.Write(append(appendUint16(nil, uint16(len(response))), response...))
+			}
+		}()
+	}
+}
+
+// answer builds an authoritative response, a referral for names below a delegation, or NXDOMAIN
+func (s *dnsStubServer) answer(query []byte, network string) []byte {
+	name, offset, err := unpackName(query, dnsHeaderLength)
+	if err != nil || offset+4 > len(query) {
+		return nil
+	}
+	qtype := RRTypeFromCode(binary.BigEndian.Uint16(query[offset:]))
+	question := query[dnsHeaderLength : offset+4]
+	dnssec := false
+	if opt, _, err := unpackRR(query, offset+4); err == nil && opt.rrType == RRTypeFromCode(dnsTypeOPT) {
+		dnssec = opt.ttl&ednsFlagDO != 0
+	}
+
+	s.mu.Lock()
+	s.queries = append(s.queries, network+" "+strings.ToLower(name)+" "+string(qtype))
+	rrsets := s.rrsets
+	maxUDP := s.maxUDP
+	s.mu.Unlock()
+
+	flags := uint16(dnsFlagQR | dnsFlagAA)
+	answer, authority := make([]*RRset, 0), make([]*RRset, 0)
+	if ns := findRRSet(rrsets, name, RRTypeNS); ns != nil && !strings.EqualFold(name, s.zone) && qtype != RRTypeDS {
+		flags &^= dnsFlagAA
+		authority = append(authority, ns)
+	} else if rrset := findRRSet(rrsets, name, qtype); rrset != nil {
+		answer = append(answer, rrset)
+		if rrsigs := findRRSet(rrsets, name, RRTypeRRSIG); dnssec && rrsigs != nil {
+			covering := RRset{Name: rrsigs.Name, Type: rrsigs.Type, TTL: rrsigs.TTL}
+			for _, record := range rrsigs.Records {
+				if strings.HasPrefix(StringValue(record.Content), string(qtype)+" ") {
+					covering.Records = append(covering.Records, record)
+				}
+			}
+			answer = append(answer, &covering)
+		}
+	} else if !s.exists(rrsets, name) {
+		flags |= DNSRcodeNXDomain
+	}
+
+	sections := make([]byte, 0)
+	counts := [2]uint16{}
+	for i, section := range [][]*RRset{answer, authority} {
+		for _, rrset := range section {
+			packed, err := PackRRset(rrset)
+			if err != nil {
+				return nil
+			}
+			sections = append(sections, packed...)
+			counts[i] += uint16(len(rrset.Records))
+		}
+	}
+
+	response := append(make([]byte, 0, dnsHeaderLength+len(question)+len(sections)), query[:2]...)
+	if network == "udp" && dnsHeaderLength+len(question)+len(sections) > maxUDP {
+		flags |= dnsFlagTC
+		sections, counts = nil, [2]uint16{}
+	}
+	for _, field := range []uint16{flags, 1, counts[0], counts[1], 0} {
+		response = appendUint16(response, field)
+	}
+	response = append(response, question...)
+	return append(response, sections...)
+}
+
+func (s *dnsStubServer) exists(rrsets []RRset, name string) bool {
+	for _, rrset := range rrsets {
+		if isSubdomain(StringValue(rrset.Name), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *dnsStubServer) setRRsets(rrsets []RRset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rrsets = rrsets
+}
+
+func (s *dnsStubServer) networks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	networks := make([]string, 0, len(s.queries))
+	for _, query := range s.queries {
+		networks = append(networks, strings.Fields(query)[0])
+	}
+	return networks
+}
+
+func TestDNSClientQuery(t *testing.T) {
+	server := startDNSStubServer(t, "example.com.", generateTestSignerRRsets())
+	client := NewDNSClient(server.address())
+	ctx := context.Background()
+
+	response, err := client.Query(ctx, "WWW.example.com", RRTypeA)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if response.Rcode != DNSRcodeSuccess || !response.Authoritative || len(response.Answer) != 1 {
+		t.Fatalf("Invalid response: %+v", response)
+	}
+	if rrset := response.Answer[0]; *rrset.Name != "www.example.com." || *rrset.Type != RRTypeA || *rrset.TTL != 3600 || strings.Join(rrsetContents(&rrset), " ") != "192.0.2.2 192.0.2.3" {
+		t.Errorf("Invalid answer: %+v", rrset)
+	}
+
+	response, err = client.Query(ctx, "secure.example.com", RRTypeA)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if response.Authoritative || len(response.Answer) != 0 || findRRSet(response.Authority, "secure.example.com.", RRTypeNS) == nil {
+		t.Errorf("Expected referral, got %+v", response)
+	}
+
+	response, err = client.Query(ctx, "missing.example.com", RRTypeA)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if response.Rcode != DNSRcodeNXDomain || len(response.Answer) != 0 {
+		t.Errorf("Expected NXDOMAIN, got %+v", response)
+	}
+
+	if _, err := client.Query(ctx, "www.example.com", RRType("BOGUS")); err == nil {
+		t.Error("Expected error for unknown type")
+	}
+}
+
+func TestDNSClientTCPFallback(t *testing.T) {
+	rrsets := generateTestSignerRRsets()
+	large := RRset{Name: String("large.example.com."), Type: RRTypePtr(RRTypeTXT), TTL: Uint32(300)}
+	for i := 0; i < 20; i++ {
+		large.Records = append(large.Records, Record{Content: String(`"` + strings.Repeat("x", 100) + `"`)})
+	}
+	server := startDNSStubServer(t, "example.com.", append(rrsets, large))
+
+	response, err := NewDNSClient(server.address()).Query(context.Background(), "large.example.com", RRTypeTXT)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if response.Truncated || len(response.Answer) != 1 || len(response.Answer[0].Records) != 20 {
+		t.Errorf("Invalid response: %+v", response)
+	}
+	if networks := strings.Join(server.networks(), " "); networks != "udp tcp" {
+		t.Errorf("Expected UDP query followed by TCP query, got %s", networks)
+	}
+}
+
+func TestDNSClientDNSSEC(t *testing.T) {
+	signed, _, _ := signTestZone(t, nil)
+	server := startDNSStubServer(t, "example.com.", signed)
+	client := NewDNSClient(server.address())
+	ctx := context.Background()
+
+	response, err := client.Query(ctx, "www.example.com", RRTypeA)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if findRRSet(response.Answer, "www.example.com.", RRTypeRRSIG) != nil {
+		t.Error("Unexpected RRSIG without DO bit")
+	}
+
+	client.DNSSEC = true
+	response, err = client.Query(ctx, "www.example.com", RRTypeA)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if rrsigs := findRRSet(response.Answer, "www.example.com.", RRTypeRRSIG); rrsigs == nil || len(rrsigs.Records) != 1 || !strings.HasPrefix(*rrsigs.Records[0].Content, "A 15 3 3600 ") {
+		t.Errorf("Expected RRSIG with DO bit, got %+v", response.Answer)
+	}
+}
+
+func TestDNSClientSOASerial(t *testing.T) {
+	server := startDNSStubServer(t, "example.com.", generateTestSignerRRsets())
+	client := NewDNSClient(server.address())
+
+	serial, err := client.SOASerial(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if serial != 1 {
+		t.Errorf("Invalid serial %d", serial)
+	}
+
+	if _, err := client.SOASerial(context.Background(), "example.net"); err == nil {
+		t.Error("Expected error for unknown zone")
+	}
+}
+
+func TestDNSClientVerifyServed(t *testing.T) {
+	signed, _, _ := signTestZone(t, nil)
+	server := startDNSStubServer(t, "example.com.", signed)
+	client := NewDNSClient(server.address())
+	client.DNSSEC = true
+	ctx := context.Background()
+
+	mismatches, err := client.VerifyServed(ctx, "example.com", signed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("Unexpected mismatches: %+v", mismatches)
+	}
+
+	expected := generateTestSignerRRsets()
+	expected = append(expected, RRset{Name: String("new.example.com."), Type: RRTypePtr(RRTypeA), TTL: Uint32(3600), Records: []Record{{Content: String("192.0.2.9")}}})
+	for i := range expected {
+		switch *expected[i].Name + " " + string(*expected[i].Type) {
+		case "www.example.com. A":
+			expected[i].Records = expected[i].Records[:1]
+		case "example.com. MX":
+			expected[i].TTL = Uint32(300)
+		}
+	}
+
+	unsigned := removeTestRRset(signed, "ns1.example.com.", RRTypeRRSIG)
+	server.setRRsets(unsigned)
+
+	mismatches, err = client.VerifyServed(ctx, "example.com", expected)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	problems := make([]string, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		problems = append(problems, mismatch.Name+" "+string(mismatch.Type)+": "+mismatch.Problem)
+	}
+	expectedProblems := []string{
+		"example.com. MX: served TTL 3600 differs from 300",
+		"new.example.com. A: server answered with rcode 3",
+		"ns1.example.com. A: RRset is served without RRSIG records",
+		"www.example.com. A: served content differs",
+	}
+	if strings.Join(problems, "\n") != strings.Join(expectedProblems, "\n") {
+		t.Errorf("Invalid mismatches:\n%s", strings.Join(problems, "\n"))
+	}
+	if mismatches[3].Expected[0] != "192.0.2.2" || len(mismatches[3].Served) != 2 {
+		t.Errorf("Invalid mismatch: %+v", mismatches[3])
+	}
+}
+
+func TestDNSClientTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer conn.Close()
+
+	client := NewDNSClient(conn.LocalAddr().String())
+	client.Timeout = 50 * time.Millisecond
+	if _, err := client.Query(context.Background(), "example.com", RRTypeSOA); err == nil {
+		t.Error("Expected timeout")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.Timeout = time.Minute
+	if _, err := client.Query(ctx, "example.com", RRTypeSOA); err == nil {
+		t.Error("Expected error for canceled context")
+	}
+}
@@ -0,0 +1,315 @@
+package powerdns
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DNSSECAlgorithm represents a DNSSEC algorithm number (RFC 8624)
+type DNSSECAlgorithm uint8
+
+const (
+	// AlgorithmRSAMD5 must not be used
+	AlgorithmRSAMD5 DNSSECAlgorithm = 1
+	// AlgorithmDSA must not be used
+	AlgorithmDSA DNSSECAlgorithm = 3
+	// AlgorithmRSASHA1 is RSA/SHA-1
+	AlgorithmRSASHA1 DNSSECAlgorithm = 5
+	// AlgorithmDSANSEC3SHA1 must not be used
+	AlgorithmDSANSEC3SHA1 DNSSECAlgorithm = 6
+	// AlgorithmRSASHA1NSEC3SHA1 is RSA/SHA-1 for NSEC3 zones
+	AlgorithmRSASHA1NSEC3SHA1 DNSSECAlgorithm = 7
+	// AlgorithmRSASHA256 is RSA/SHA-256
+	AlgorithmRSASHA256 DNSSECAlgorithm = 8
+	// AlgorithmRSASHA512 is RSA/SHA-512
+	AlgorithmRSASHA512 DNSSECAlgorithm = 10
+	// AlgorithmECCGOST must not be used
+	AlgorithmECCGOST DNSSECAlgorithm = 12
+	// AlgorithmECDSAP256SHA256 is ECDSA with curve P-256 and SHA-256
+	AlgorithmECDSAP256SHA256 DNSSECAlgorithm = 13
+	// AlgorithmECDSAP384SHA384 is ECDSA with curve P-384 and SHA-384
+	AlgorithmECDSAP384SHA384 DNSSECAlgorithm = 14
+	// AlgorithmED25519 is Ed25519
+	AlgorithmED25519 DNSSECAlgorithm = 15
+	// AlgorithmED448 is Ed448
+	AlgorithmED448 DNSSECAlgorithm = 16
+)
+
+var dnssecAlgorithmNames = map[DNSSECAlgorithm]string{
+	AlgorithmRSAMD5:           "RSAMD5",
+	AlgorithmDSA:              "DSA",
+	AlgorithmRSASHA1:          "RSASHA1",
+	AlgorithmDSANSEC3SHA1:     "DSA-NSEC3-SHA1",
+	AlgorithmRSASHA1NSEC3SHA1: "RSASHA1-NSEC3-SHA1",
+	AlgorithmRSASHA256:        "RSASHA256",
+	AlgorithmRSASHA512:        "RSASHA512",
+	AlgorithmECCGOST:          "ECC-GOST",
+	AlgorithmECDSAP256SHA256:  "ECDSAP256SHA256",
+	AlgorithmECDSAP384SHA384:  "ECDSAP384SHA384",
+	AlgorithmED25519:          "ED25519",
+	AlgorithmED448:            "ED448",
+}
+
+// String returns the mnemonic of an algorithm, as used by PowerDNS
+func (a DNSSECAlgorithm) String() string {
+	if name, ok := dnssecAlgorithmNames[a]; ok {
+		return name
+	}
+	return strconv.Itoa(int(a))
+}
+
+// ParseDNSSECAlgorithm parses an algorithm mnemonic (case-insensitive) or number
+func ParseDNSSECAlgorithm(s string) (DNSSECAlgorithm, error) {
+	for algorithm, name := range dnssecAlgorithmNames {
+		if strings.EqualFold(name, s) {
+			return algorithm, nil
+		}
+	}
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil {
+		return DNSSECAlgorithm(n), nil
+	}
+	return 0, fmt.Errorf("unknown DNSSEC algorithm %q", s)
+}
+
+// DSDigestType represents a DS digest type number
+type DSDigestType uint8
+
+const (
+	// DigestSHA1 is SHA-1 (RFC 4034)
+	DigestSHA1 DSDigestType = 1
+	// DigestSHA256 is SHA-256 (RFC 4509)
+	DigestSHA256 DSDigestType = 2
+	// DigestGOST is GOST R 34.11-94, which is not supported
+	DigestGOST DSDigestType = 3
+	// DigestSHA384 is SHA-384 (RFC 6605)
+	DigestSHA384 DSDigestType = 4
+)
+
+// DNSKEY flags (RFC 4034, section 2.1.1 and RFC 5011, section 7)
+const (
+	DNSKEYFlagZone   uint16 = 0x0100
+	DNSKEYFlagRevoke uint16 = 0x0080
+	DNSKEYFlagSEP    uint16 = 0x0001
+)
+
+// ErrDSMismatch is returned if a DS record does not match its DNSKEY
+var ErrDSMismatch = errors.New("DS does not match DNSKEY")
+
+// DNSKEY is the parsed content of a DNSKEY record
+type DNSKEY struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm DNSSECAlgorithm
+	PublicKey []byte
+}
+
+// DS is the parsed content of a DS record
+type DS struct {
+	KeyTag     uint16
+	Algorithm  DNSSECAlgorithm
+	DigestType DSDigestType
+	Digest     []byte
+}
+
+// ParseDNSKEY parses the presentation format of DNSKEY content like "257 3 13 base64..."
+func ParseDNSKEY(content string) (*DNSKEY, error) {
+	fields := strings.Fields(content)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid DNSKEY %q", content)
+	}
+
+	flags, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNSKEY flags %q", fields[0])
+	}
+	protocol, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNSKEY protocol %q", fields[1])
+	}
+	algorithm, err := ParseDNSSECAlgorithm(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNSKEY public key: %v", err)
+	}
+
+	return &DNSKEY{Flags: uint16(flags), Protocol: uint8(protocol), Algorithm: algorithm, PublicKey: publicKey}, nil
+}
+
+// String returns the presentation format of a DNSKEY
+func (k *DNSKEY) String() string {
+	return fmt.Sprintf("%d %d %d %s", k.Flags, k.Protocol, k.Algorithm, base64.StdEncoding.EncodeToString(k.PublicKey))
+}
+
+// IsSEP reports whether the secure entry point flag is set, which is the case for KSKs and CSKs
+func (k *DNSKEY) IsSEP() bool {
+	return k.Flags&DNSKEYFlagSEP != 0
+}
+
+// IsRevoked reports whether the revoke flag is set
+func (k *DNSKEY) IsRevoked() bool {
+	return k.Flags&DNSKEYFlagRevoke != 0
+}
+
+// rdata returns the wire format of the DNSKEY content
+func (k *DNSKEY) rdata() []byte {
+	rdata := make([]byte, 4, 4+len(k.PublicKey))
+	binary.BigEndian.PutUint16(rdata, k.Flags)
+	rdata[2] = k.Protocol
+	rdata[3] = uint8(k.Algorithm)
+	return append(rdata, k.PublicKey...)
+}
+
+// KeyTag computes the key tag of a DNSKEY (RFC 4034, appendix B)
+func (k *DNSKEY) KeyTag() uint16 {
+	if k.Algorithm == AlgorithmRSAMD5 {
+		// The key tag of RSA/MD5 keys are the 16 most significant bits of the least significant 24 bits of the modulus
+		if len(k.PublicKey) < 3 {
+			return 0
+		}
+		return binary.BigEndian.Uint16(k.PublicKey[len(k.PublicKey)-3:])
+	}
+
+	var ac uint32
+	for i, b := range k.rdata() {
+		if i&1 == 1 {
+			ac += uint32(b)
+		} else {
+			ac += uint32(b) << 8
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac & 0xffff)
+}
+
+// DS generates the DS record of a DNSKEY owned by a given name
+func (k *DNSKEY) DS(owner string, digestType DSDigestType) (*DS, error) {
+	name, err := PackCanonicalName(makeDomainCanonical(owner))
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(name, k.rdata()...)
+	var digest []byte
+	switch digestType {
+	case DigestSHA1:
+		sum := sha1.Sum(data)
+		digest = sum[:]
+	case DigestSHA256:
+		sum := sha256.Sum256(data)
+		digest = sum[:]
+	case DigestSHA384:
+		sum := sha512.Sum384(data)
+		digest = sum[:]
+	default:
+		return nil, fmt.Errorf("unsupported DS digest type %d", digestType)
+	}
+
+	return &DS{KeyTag: k.KeyTag(), Algorithm: k.Algorithm, DigestType: digestType, Digest: digest}, nil
+}
+
+// ParseDS parses the presentation format of DS content like "60485 5 1 2BB183AF..."
+func ParseDS(content string) (*DS, error) {
+	fields := strings.Fields(content)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid DS %q", content)
+	}
+
+	keyTag, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DS key tag %q", fields[0])
+	}
+	algorithm, err := ParseDNSSECAlgorithm(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	digestType, err := strconv.ParseUint(fields[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DS digest type %q", fields[2])
+	}
+	digest, err := hex.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DS digest: %v", err)
+	}
+
+	return &DS{KeyTag: uint16(keyTag), Algorithm: algorithm, DigestType: DSDigestType(digestType), Digest: digest}, nil
+}
+
+// String returns the presentation format of a DS, using lower-case hex like PowerDNS
+func (d *DS) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.KeyTag, d.Algorithm, d.DigestType, hex.EncodeToString(d.Digest))
+}
+
+// Equal reports whether two DS records are identical
+func (d *DS) Equal(other *DS) bool {
+	return d.KeyTag == other.KeyTag && d.Algorithm == other.Algorithm && d.DigestType == other.DigestType && bytes.Equal(d.Digest, other.Digest)
+}
+
+// Verify checks whether a DS matches a DNSKEY owned by a given name
+func (d *DS) Verify(owner string, key *DNSKEY) error {
+	expected, err := key.DS(owner, d.DigestType)
+	if err != nil {
+		return err
+	}
+	if !d.Equal(expected) {
+		return fmt.Errorf("%w: %s", ErrDSMismatch, d)
+	}
+	return nil
+}
+
+// ParsedDNSKEY returns the parsed DNSKEY of a Cryptokey
+func (c *Cryptokey) ParsedDNSKEY() (*DNSKEY, error) {
+	if c.DNSkey == nil {
+		return nil, errors.New("cryptokey has no DNSKEY")
+	}
+	return ParseDNSKEY(*c.DNSkey)
+}
+
+// ParsedDS returns the parsed DS records of a Cryptokey
+func (c *Cryptokey) ParsedDS() ([]DS, error) {
+	dsRecords := make([]DS, 0, len(c.DS))
+	for _, content := range c.DS {
+		ds, err := ParseDS(content)
+		if err != nil {
+			return nil, err
+		}
+		dsRecords = append(dsRecords, *ds)
+	}
+	return dsRecords, nil
+}
+
+// VerifyDS checks whether the DS records returned by PowerDNS match the DNSKEY of a Cryptokey.
+// DS records with unsupported digest types are skipped.
+func (c *Cryptokey) VerifyDS(domain string) error {
+	key, err := c.ParsedDNSKEY()
+	if err != nil {
+		return err
+	}
+	dsRecords, err := c.ParsedDS()
+	if err != nil {
+		return err
+	}
+
+	for i := range dsRecords {
+		switch dsRecords[i].DigestType {
+		case DigestSHA1, DigestSHA256, DigestSHA384:
+		default:
+			continue
+		}
+		if err := dsRecords[i].Verify(domain, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package powerdns
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test vectors from RFC 4034, section 5.4, RFC 4509, section 2.2.1 and RFC 6605, section 6.2
+const (
+	testDNSKEYRSASHA1     = "256 3 5 AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/2pHm822aJ5iI9BMzNXxeYCmZ DRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9Xzc nOf+EPbtG9DMBmADjFDc2w/rljwvFw=="
+	testDNSKEYECDSAP384   = "257 3 14 xKYaNhWdGOfJ+nPrL8/arkwf2EY3MDJ+SErKivBVSum1w/egsXvSADtNJhyem5RCOpgQ6K8X1DRSEkrbYQ+OB+v8/uX45NBwY8rp65F6Glur8I/mlVNgF6W/qTI37m40"
+	testDSRSASHA1SHA1     = "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118"
+	testDSRSASHA1SHA256   = "60485 5 2 D4B7D520E7BB5F0F67674A0CCEB1E3E0614B93C4F9E99B8383F6A1E4469DA50A"
+	testDSECDSAP384SHA384 = "10771 14 4 72d7b62976ce06438e9c0bf319013cf801f09ecc84b8d7e9495f27e305c6a9b0563a9b5f4d288405c3008a946df983d6"
+)
+
+func TestParseDNSKEY(t *testing.T) {
+	key, err := ParseDNSKEY(testDNSKEYRSASHA1)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if key.Flags != 256 || key.Protocol != 3 || key.Algorithm != AlgorithmRSASHA1 || len(key.PublicKey) != 130 {
+		t.Errorf("Invalid DNSKEY: %+v", key)
+	}
+	if key.IsSEP() || key.IsRevoked() {
+		t.Error("Invalid DNSKEY flags")
+	}
+	if reparsed, err := ParseDNSKEY(key.String()); err != nil || !bytes.Equal(reparsed.PublicKey, key.PublicKey) {
+		t.Errorf("Invalid round trip: %v", err)
+	}
+
+	for _, content := range []string{"256 3 5", "x 3 5 AAAA", "256 x 5 AAAA", "256 3 FOO AAAA", "256 3 5 !!!!"} {
+		if _, err := ParseDNSKEY(content); err == nil {
+			t.Errorf("error is nil for %q", content)
+		}
+	}
+
+	key, err = ParseDNSKEY("257 3 ECDSAP256SHA256 AAAA")
+	if err != nil || key.Algorithm != AlgorithmECDSAP256SHA256 || !key.IsSEP() {
+		t.Errorf("Invalid DNSKEY with algorithm mnemonic: %+v, %v", key, err)
+	}
+}
+
+func TestKeyTag(t *testing.T) {
+	testCases := []struct {
+		content string
+		keyTag  uint16
+	}{
+		{testDNSKEYRSASHA1, 60485},
+		{testDNSKEYECDSAP384, 10771},
+		{"256 3 1 AQPAEjRW", 0x1234},
+	}
+
+	for _, tc := range testCases {
+		key, err := ParseDNSKEY(tc.content)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if keyTag := key.KeyTag(); keyTag != tc.keyTag {
+			t.Errorf("Invalid key tag %d, want %d", keyTag, tc.keyTag)
+		}
+	}
+}
+
+func TestDNSKEYDS(t *testing.T) {
+	testCases := []struct {
+		owner      string
+		content    string
+		digestType DSDigestType
+		ds         string
+	}{
+		{"dskey.example.com", testDNSKEYRSASHA1, DigestSHA1, testDSRSASHA1SHA1},
+		{"DSKEY.example.com.", testDNSKEYRSASHA1, DigestSHA256, testDSRSASHA1SHA256},
+		{"example.net.", testDNSKEYECDSAP384, DigestSHA384, testDSECDSAP384SHA384},
+	}
+
+	for _, tc := range testCases {
+		key, _ := ParseDNSKEY(tc.content)
+		ds, err := key.DS(tc.owner, tc.digestType)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if !strings.EqualFold(ds.String(), tc.ds) {
+			t.Errorf("Invalid DS %q, want %q", ds, tc.ds)
+		}
+	}
+
+	key, _ := ParseDNSKEY(testDNSKEYRSASHA1)
+	if _, err := key.DS("dskey.example.com", DigestGOST); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestParseDS(t *testing.T) {
+	ds, err := ParseDS("60485 5 1 2BB183AF5F22588179A5 3B0A98631FAD1A292118")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if ds.KeyTag != 60485 || ds.Algorithm != AlgorithmRSASHA1 || ds.DigestType != DigestSHA1 || len(ds.Digest) != 20 {
+		t.Errorf("Invalid DS: %+v", ds)
+	}
+
+	for _, content := range []string{"60485 5 1", "x 5 1 AA", "60485 FOO 1 AA", "60485 5 x AA", "60485 5 1 XYZ"} {
+		if _, err := ParseDS(content); err == nil {
+			t.Errorf("error is nil for %q", content)
+		}
+	}
+}
+
+func TestVerifyDS(t *testing.T) {
+	key, _ := ParseDNSKEY(testDNSKEYRSASHA1)
+	ds, _ := ParseDS(testDSRSASHA1SHA256)
+	if err := ds.Verify("dskey.example.com", key); err != nil {
+		t.Errorf("%s", err)
+	}
+	if err := ds.Verify("other.example.com", key); !errors.Is(err, ErrDSMismatch) {
+		t.Errorf("Expected ErrDSMismatch, got %v", err)
+	}
+}
+
+func TestCryptokeyVerifyDS(t *testing.T) {
+	cryptokey := Cryptokey{
+		DNSkey: String(testDNSKEYRSASHA1),
+		DS:     []string{testDSRSASHA1SHA1, testDSRSASHA1SHA256, "60485 5 3 abcdef"},
+	}
+	if err := cryptokey.VerifyDS("dskey.example.com."); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	parsed, err := cryptokey.ParsedDS()
+	if err != nil || len(parsed) != 3 {
+		t.Errorf("Invalid parsed DS: %+v, %v", parsed, err)
+	}
+
+	cryptokey.DS = append(cryptokey.DS, "60485 5 2 00")
+	if err := cryptokey.VerifyDS("dskey.example.com."); !errors.Is(err, ErrDSMismatch) {
+		t.Errorf("Expected ErrDSMismatch, got %v", err)
+	}
+
+	cryptokey.DS = []string{"invalid"}
+	if err := cryptokey.VerifyDS("dskey.example.com."); err == nil {
+		t.Error("error is nil")
+	}
+	if err := (&Cryptokey{}).VerifyDS("dskey.example.com."); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestDNSSECAlgorithm(t *testing.T) {
+	if AlgorithmED25519.String() != "ED25519" || DNSSECAlgorithm(200).String() != "200" {
+		t.Error("Invalid algorithm mnemonic")
+	}
+	if a, err := ParseDNSSECAlgorithm("ecdsap256sha256"); err != nil || a != AlgorithmECDSAP256SHA256 {
+		t.Errorf("Invalid algorithm %d, %v", a, err)
+	}
+	if _, err := ParseDNSSECAlgorithm("FOO"); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestPackCanonicalName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wire    []byte
+		wantErr bool
+	}{
+		{".", []byte{0}, false},
+		{"Example.COM.", []byte("\x07example\x03com\x00"), false},
+		{`a\.b.example.`, []byte("\x03a.b\x07example\x00"), false},
+		{`\065.`, []byte("\x01a\x00"), false},
+		{"example", nil, true},
+		{"a..example.", nil, true},
+		{strings.Repeat("a", 64) + ".", nil, true},
+		{strings.Repeat(strings.Repeat("a", 63)+".", 4), nil, true},
+		{`\999.`, nil, true},
+	}
+
+	for _, tc := range testCases {
+		wire, err := PackCanonicalName(tc.name)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !bytes.Equal(wire, tc.wire) {
+			t.Errorf("%s: got %q, want %q", tc.name, wire, tc.wire)
+		}
+	}
+}
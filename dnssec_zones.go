@@ -0,0 +1,145 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNonCompliantNSEC3Param is returned if NSEC3 parameters deviate from RFC 9276 and this has not been allowed explicitly
+	ErrNonCompliantNSEC3Param = errors.New("NSEC3 parameters do not comply with RFC 9276")
+	// ErrParentDSPresent is returned if DNSSEC should be disabled while the parent zone still has DS records
+	ErrParentDSPresent = errors.New("parent zone has DS records")
+	// ErrParentDSUnknown is returned if DNSSEC should be disabled, but the DS records of the parent zone cannot be checked
+	ErrParentDSUnknown = errors.New("DS records of the parent zone cannot be checked")
+)
+
+// DNSSECOptions configures how DNSSEC is enabled for a zone
+type DNSSECOptions struct {
+	// Keys to create. If empty, the server creates its default keys.
+	Keys []CryptokeySpec
+	// NSEC3Param enables NSEC3, otherwise NSEC is used
+	NSEC3Param *NSEC3Param
+	// NSEC3Narrow enables NSEC3 narrow mode
+	NSEC3Narrow bool
+	// AllowNonCompliantNSEC3Param allows NSEC3 parameters which deviate from RFC 9276
+	AllowNonCompliantNSEC3Param bool
+}
+
+// DisableDNSSECOptions configures how DNSSEC is disabled for a zone
+type DisableDNSSECOptions struct {
+	// ParentHasDS reports whether the parent zone has DS records for the zone. It is used if the parent zone is not hosted on the server.
+	ParentHasDS func(ctx context.Context, zone string) (bool, error)
+	// Force skips the check of the DS records in the parent zone
+	Force bool
+}
+
+// EnableDNSSEC signs a zone: it creates the keys, configures NSEC or NSEC3, and rectifies the zone.
+// The resulting cryptokeys are returned, so that their DS records can be submitted to the parent.
+func (z *ZonesService) EnableDNSSEC(ctx context.Context, domain string, options *DNSSECOptions) ([]Cryptokey, error) {
+	if options == nil {
+		options = &DNSSECOptions{}
+	}
+
+	nsec3Param := ""
+	if options.NSEC3Param != nil {
+		if warnings := options.NSEC3Param.Warnings(); len(warnings) > 0 && !options.AllowNonCompliantNSEC3Param {
+			return nil, fmt.Errorf("%w: %s", ErrNonCompliantNSEC3Param, strings.Join(warnings, "; "))
+		}
+		nsec3Param = options.NSEC3Param.String()
+	}
+
+	zone, err := z.get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if BoolValue(zone.Presigned) {
+		return nil, fmt.Errorf("zone %s is presigned", StringValue(zone.Name))
+	}
+
+	for _, spec := range options.Keys {
+		if spec.Active == nil {
+			spec.Active = Bool(true)
+		}
+		if _, err := z.client.Cryptokeys.Create(ctx, domain, spec); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := z.Change(ctx, domain, &Zone{DNSsec: Bool(true), Nsec3Param: String(nsec3Param), Nsec3Narrow: Bool(options.NSEC3Narrow)}); err != nil {
+		return nil, err
+	}
+	if _, err := z.Rectify(ctx, domain); err != nil {
+		return nil, err
+	}
+
+	return z.client.Cryptokeys.List(ctx, domain)
+}
+
+// DisableDNSSEC removes all keys and the CDS/CDNSKEY configuration of a zone.
+// Unless forced, it refuses to do so while the parent zone still has DS records, because the zone would become bogus.
+func (z *ZonesService) DisableDNSSEC(ctx context.Context, domain string, options *DisableDNSSECOptions) error {
+	if options == nil {
+		options = &DisableDNSSECOptions{}
+	}
+
+	if !options.Force {
+		if err := z.checkParentDS(ctx, domain, options.ParentHasDS); err != nil {
+			return err
+		}
+	}
+
+	if err := z.SetCDSPublication(ctx, domain, &CDSPublication{}); err != nil {
+		return err
+	}
+	if err := z.WithdrawDeleteDS(ctx, domain); err != nil {
+		return err
+	}
+
+	if err := z.Change(ctx, domain, &Zone{DNSsec: Bool(false), Nsec3Param: String("")}); err != nil {
+		return err
+	}
+
+	// Older servers keep the keys when DNSSEC is disabled
+	cryptokeys, err := z.client.Cryptokeys.List(ctx, domain)
+	if err != nil {
+		return err
+	}
+	for _, cryptokey := range cryptokeys {
+		if err := z.client.Cryptokeys.Delete(ctx, domain, Uint64Value(cryptokey.ID)); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (z *ZonesService) checkParentDS(ctx context.Context, domain string, parentHasDS func(context.Context, string) (bool, error)) error {
+	child := strings.ToLower(makeDomainCanonical(idnToASCIIOrRaw(domain)))
+
+	var hasDS bool
+	parent, err := z.findEnclosing(ctx, parentName(child))
+	switch {
+	case err == nil && child != ".":
+		parentZone, err := z.get(ctx, StringValue(parent.Name))
+		if err != nil {
+			return err
+		}
+		ds := findRRSet(parentZone.RRsets, child, RRTypeDS)
+		hasDS = ds != nil && len(ds.Records) > 0
+	case err != nil && !isNotFound(err):
+		return err
+	case parentHasDS != nil:
+		if hasDS, err = parentHasDS(ctx, child); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrParentDSUnknown, child)
+	}
+
+	if hasDS {
+		return fmt.Errorf("%w: remove the DS records of %s and wait for their TTL to expire", ErrParentDSPresent, child)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func registerDNSSECZoneMockResponder(testDomain string, changes *[]Zone, rectified *int) {
+	httpmock.RegisterResponder("PUT", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var zone Zone
+			if json.NewDecoder(req.Body).Decode(&zone) != nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+			*changes = append(*changes, zone)
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+
+	httpmock.RegisterResponder("PUT", generateTestAPIVHostURL()+"/zones/"+testDomain+"/rectify",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			*rectified++
+			return httpmock.NewJsonResponse(http.StatusOK, RectifyResult{Result: String("Rectified")})
+		},
+	)
+}
+
+func TestEnableDNSSEC(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	keys := &cryptokeysState{keys: make(map[uint64]*Cryptokey)}
+	registerRolloverMockResponder(testDomain, "ns.example.com. hostmaster.example.com. 1 600 300 604800 3600", keys)
+	changes := make([]Zone, 0)
+	rectified := 0
+	registerDNSSECZoneMockResponder(testDomain, &changes, &rectified)
+
+	p := initialisePowerDNSTestClient()
+	cryptokeys, err := p.Zones.EnableDNSSEC(context.Background(), testDomain, &DNSSECOptions{
+		Keys: []CryptokeySpec{
+			{KeyType: CryptokeyTypeKSK, Algorithm: "ED25519"},
+			{KeyType: CryptokeyTypeZSK, Algorithm: "ED25519", Active: Bool(false)},
+		},
+		NSEC3Param: DefaultNSEC3Param(),
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(cryptokeys) != 2 || !*cryptokeys[0].Active || *cryptokeys[1].Active {
+		t.Errorf("Invalid cryptokeys: %+v", cryptokeys)
+	}
+	if len(changes) != 1 || !*changes[0].DNSsec || *changes[0].Nsec3Param != "1 0 0 -" || *changes[0].Nsec3Narrow {
+		t.Errorf("Invalid zone changes: %+v", changes)
+	}
+	if rectified != 1 {
+		t.Errorf("Zone has been rectified %d times", rectified)
+	}
+}
+
+func TestEnableDNSSECNonCompliantNSEC3Param(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	keys := &cryptokeysState{keys: make(map[uint64]*Cryptokey)}
+	registerRolloverMockResponder(testDomain, "ns.example.com. hostmaster.example.com. 1 600 300 604800 3600", keys)
+	changes := make([]Zone, 0)
+	rectified := 0
+	registerDNSSECZoneMockResponder(testDomain, &changes, &rectified)
+
+	p := initialisePowerDNSTestClient()
+	param := &NSEC3Param{HashAlgorithm: NSEC3HashSHA1, Iterations: 10, Salt: []byte{0xab}}
+	if _, err := p.Zones.EnableDNSSEC(context.Background(), testDomain, &DNSSECOptions{NSEC3Param: param}); !errors.Is(err, ErrNonCompliantNSEC3Param) {
+		t.Errorf("Expected ErrNonCompliantNSEC3Param, got %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Zone has been changed: %+v", changes)
+	}
+
+	if _, err := p.Zones.EnableDNSSEC(context.Background(), testDomain, &DNSSECOptions{NSEC3Param: param, AllowNonCompliantNSEC3Param: true}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(changes) != 1 || *changes[0].Nsec3Param != "1 0 10 ab" {
+		t.Errorf("Invalid zone changes: %+v", changes)
+	}
+
+	if _, err := p.Zones.EnableDNSSEC(context.Background(), testDomain, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(changes) != 2 || *changes[1].Nsec3Param != "" {
+		t.Errorf("NSEC has not been enabled: %+v", changes)
+	}
+}
+
+func TestDisableDNSSEC(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{
+		"example.com.": {{Name: String("child.example.com."), Type: RRTypePtr(RRTypeDS), TTL: Uint32(3600), Records: []Record{{Content: String("1 13 2 abcdef")}}}},
+	}
+	registerZonesStateMockResponder(zones, make(map[string][]RRset))
+	keys := newCryptokeysState()
+	registerRolloverMockResponder("child.example.com", "ns.example.com. hostmaster.example.com. 1 600 300 604800 3600", keys)
+	registerMetadataMockResponder("child.example.com", map[MetadataKind][]string{MetadataPublishCDS: {"2"}})
+	httpmock.RegisterResponder("PATCH", generateTestAPIVHostURL()+"/zones/child.example.com",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+	changes := make([]Zone, 0)
+	rectified := 0
+	registerDNSSECZoneMockResponder("child.example.com", &changes, &rectified)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if err := p.Zones.DisableDNSSEC(ctx, "child.example.com", nil); !errors.Is(err, ErrParentDSPresent) {
+		t.Fatalf("Expected ErrParentDSPresent, got %v", err)
+	}
+	if len(changes) != 0 || len(keys.keys) != 2 {
+		t.Fatal("Zone has been changed although the parent has DS records")
+	}
+
+	zones["example.com."] = nil
+	if err := p.Zones.DisableDNSSEC(ctx, "child.example.com", nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(changes) != 1 || *changes[0].DNSsec || changes[0].Nsec3Param == nil || *changes[0].Nsec3Param != "" || len(keys.keys) != 0 {
+		t.Errorf("DNSSEC has not been disabled: %+v, %d keys", changes, len(keys.keys))
+	}
+}
+
+func TestChangeZoneKeepsNSEC3Param(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	changes := make([]Zone, 0)
+	rectified := 0
+	registerDNSSECZoneMockResponder(testDomain, &changes, &rectified)
+
+	p := initialisePowerDNSTestClient()
+	if err := p.Zones.Change(context.Background(), testDomain, &Zone{DNSsec: Bool(false), Nsec3Param: String("1 0 0 -")}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(changes) != 1 || changes[0].Nsec3Param == nil || *changes[0].Nsec3Param != "1 0 0 -" {
+		t.Errorf("NSEC3 parameters have been changed: %+v", changes)
+	}
+}
+
+func TestDisableDNSSECParentNotHosted(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	registerZonesStateMockResponder(map[string][]RRset{}, make(map[string][]RRset))
+	keys := newCryptokeysState()
+	registerRolloverMockResponder("example.org", "ns.example.com. hostmaster.example.com. 1 600 300 604800 3600", keys)
+	registerMetadataMockResponder("example.org", map[MetadataKind][]string{})
+	httpmock.RegisterResponder("PATCH", generateTestAPIVHostURL()+"/zones/example.org",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+	changes := make([]Zone, 0)
+	rectified := 0
+	registerDNSSECZoneMockResponder("example.org", &changes, &rectified)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if err := p.Zones.DisableDNSSEC(ctx, "example.org", nil); !errors.Is(err, ErrParentDSUnknown) {
+		t.Errorf("Expected ErrParentDSUnknown, got %v", err)
+	}
+
+	parentHasDS := func(ctx context.Context, zone string) (bool, error) {
+		return zone == "example.org.", nil
+	}
+	if err := p.Zones.DisableDNSSEC(ctx, "example.org", &DisableDNSSECOptions{ParentHasDS: parentHasDS}); !errors.Is(err, ErrParentDSPresent) {
+		t.Errorf("Expected ErrParentDSPresent, got %v", err)
+	}
+
+	if err := p.Zones.DisableDNSSEC(ctx, "example.org", &DisableDNSSECOptions{Force: true}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(changes) != 1 || len(keys.keys) != 0 {
+		t.Errorf("DNSSEC has not been disabled: %+v, %d keys", changes, len(keys.keys))
+	}
+}
+
+func TestRectifyError(t *testing.T) {
+	p := initialisePowerDNSTestClient()
+	p.Port = "x"
+	if _, err := p.Zones.Rectify(context.Background(), "example.com"); err == nil {
+		t.Error("error is nil")
+	}
+}
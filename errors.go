@@ -1,6 +1,13 @@
 package powerdns
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNameOutsideZone is returned if a record name does not belong to the zone it is written to
+var ErrNameOutsideZone = errors.New("name outside of zone")
 
 // Error structure with JSON API metadata
 type Error struct {
@@ -12,3 +19,16 @@ type Error struct {
 func (e Error) Error() string {
 	return fmt.Sprintf("%v", e.Message)
 }
+
+func newNotFoundError(message string) *Error {
+	return &Error{
+		Status:     fmt.Sprintf("%d %s", http.StatusNotFound, http.StatusText(http.StatusNotFound)),
+		StatusCode: http.StatusNotFound,
+		Message:    message,
+	}
+}
+
+func isNotFound(err error) bool {
+	var apiError *Error
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound
+}
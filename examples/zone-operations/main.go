@@ -41,7 +41,7 @@ func main() {
 	}
 
 	// Add a TXT record
-	if err := pdns.Records.Add(ctx, domain, fmt.Sprintf("www.%s", domain), powerdns.RRTypeTXT, 1337, []string{"\"foo1\""}); err != nil {
+	if err := pdns.Records.AddTXT(ctx, domain, fmt.Sprintf("www.%s", domain), 1337, []string{"foo1"}); err != nil {
 		log.Fatalf("%v", err)
 	}
 
@@ -1,5 +1,9 @@
 module github.com/joeig/go-powerdns/v3
 
-go 1.17
+go 1.18
 
-require github.com/jarcoal/httpmock v1.0.4
+require (
+	github.com/jarcoal/httpmock v1.0.4
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
+)
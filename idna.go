@@ -0,0 +1,318 @@
+package powerdns
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/secure/bidirule"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// The IDNA conversion uses the UTS #46 lookup profile of golang.org/x/net/idna (non-transitional mapping, NFC,
+// hyphen, joiner and bidi rules) and additionally rejects code points which IDNA2008 disallows, like symbols.
+// Labels are converted one by one, so that ASCII labels like "_sip" are passed through unchanged.
+
+const acePrefix = "xn--"
+
+// ErrInvalidIDN is returned if a name cannot be converted between its Unicode and ASCII form
+var ErrInvalidIDN = errors.New("invalid internationalized domain name")
+
+// IDNToASCII converts a domain name to its ASCII form ("bücher.example" becomes "xn--bcher-kva.example").
+// ASCII labels are returned unchanged.
+func IDNToASCII(name string) (string, error) {
+	if isASCII(name) {
+		return name, nil
+	}
+
+	labels := strings.Split(mapFullStops(name), ".")
+	unicodeLabels := make([]string, len(labels))
+	for i, label := range labels {
+		unicodeLabels[i] = label
+		if isASCII(label) {
+			continue
+		}
+
+		ascii, err := idna.Lookup.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrInvalidIDN, name, err)
+		}
+		if len(ascii) > 63 {
+			return "", fmt.Errorf("%w: %s: label exceeds 63 octets", ErrInvalidIDN, name)
+		}
+		if unicodeLabels[i], err = idna.Lookup.ToUnicode(ascii); err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrInvalidIDN, name, err)
+		}
+		labels[i] = ascii
+	}
+
+	if err := validateIDNA2008Labels(unicodeLabels); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrInvalidIDN, name, err)
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// IDNToUnicode converts a domain name to its Unicode form ("xn--bcher-kva.example" becomes "bücher.example")
+func IDNToUnicode(name string) (string, error) {
+	labels := strings.Split(name, ".")
+	converted := false
+
+	for i, label := range labels {
+		if !strings.HasPrefix(strings.ToLower(label), acePrefix) {
+			continue
+		}
+
+		decoded, err := idna.Lookup.ToUnicode(label)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrInvalidIDN, name, err)
+		}
+		labels[i] = decoded
+		converted = true
+	}
+
+	if converted {
+		if err := validateIDNA2008Labels(labels); err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrInvalidIDN, name, err)
+		}
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// idnToASCIIOrRaw converts a name to its ASCII form and leaves it untouched if the conversion fails,
+// so that the server can report the error
+func idnToASCIIOrRaw(name string) string {
+	if converted, err := IDNToASCII(name); err == nil {
+		return converted
+	}
+	return name
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// mapFullStops maps the ideographic and full-width full stops to ".", like UTS #46 does
+func mapFullStops(name string) string {
+	return strings.NewReplacer("。", ".", "．", ".", "｡", ".").Replace(name)
+}
+
+// validateIDNA2008Labels checks the rules which the UTS #46 lookup profile does not enforce:
+// the Bidi rule for all labels of a domain containing right-to-left labels (RFC 5893, section 2),
+// the code points allowed by RFC 5892 and the CONTEXTO rules of RFC 5892, appendix A.
+func validateIDNA2008Labels(labels []string) error {
+	rtl := bidirule.DirectionString(strings.Join(labels, ".")) == bidi.RightToLeft
+
+	for _, label := range labels {
+		if isASCII(label) {
+			if rtl && label != "" && !bidirule.ValidString(label) {
+				return fmt.Errorf("label %q violates the Bidi rule", label)
+			}
+			continue
+		}
+
+		runes := []rune(label)
+		for i, r := range runes {
+			if !idna2008Allowed(r) {
+				return fmt.Errorf("label contains disallowed character %U", r)
+			}
+			if err := validateContextO(runes, i); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// idna2008Allowed reports whether a code point is PVALID, CONTEXTJ or CONTEXTO (RFC 5892, section 2)
+func idna2008Allowed(r rune) bool {
+	switch r {
+	case 0x06fd, 0x06fe, 0x0f0b, 0x3007, 0x200c, 0x200d, 0x00b7, 0x0375, 0x05f3, 0x05f4, 0x30fb:
+		return true
+	case 0x0640, 0x07fa, 0x302e, 0x302f, 0x3031, 0x3032, 0x3033, 0x3034, 0x3035, 0x303b:
+		return false
+	}
+	if r < utf8.RuneSelf {
+		return r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-'
+	}
+	return unicode.In(r, unicode.Ll, unicode.Lo, unicode.Lm, unicode.Nd, unicode.Mn, unicode.Mc)
+}
+
+// validateContextO applies the CONTEXTO rules of RFC 5892, appendix A.3 to A.9
+func validateContextO(runes []rune, i int) error {
+	has := func(match func(rune) bool) bool {
+		for _, r := range runes {
+			if match(r) {
+				return true
+			}
+		}
+		return false
+	}
+
+	valid := true
+	switch r := runes[i]; {
+	case r == 0x00b7:
+		valid = i > 0 && i < len(runes)-1 && runes[i-1] == 'l' && runes[i+1] == 'l'
+	case r == 0x0375:
+		valid = i < len(runes)-1 && unicode.Is(unicode.Greek, runes[i+1])
+	case r == 0x05f3 || r == 0x05f4:
+		valid = i > 0 && unicode.Is(unicode.Hebrew, runes[i-1])
+	case r == 0x30fb:
+		valid = has(func(r rune) bool {
+			return r != 0x30fb && (unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Han, r))
+		})
+	case r >= 0x0660 && r <= 0x0669:
+		valid = !has(func(r rune) bool { return r >= 0x06f0 && r <= 0x06f9 })
+	case r >= 0x06f0 && r <= 0x06f9:
+		valid = !has(func(r rune) bool { return r >= 0x0660 && r <= 0x0669 })
+	}
+
+	if !valid {
+		return fmt.Errorf("character %U is not allowed in this context", runes[i])
+	}
+	return nil
+}
+
+// nameContentFields lists the positions of domain names within the content of resource records.
+// Negative positions count from the end.
+var nameContentFields = map[RRType][]int{
+	RRTypeAFSDB: {1},
+	RRTypeALIAS: {0},
+	RRTypeCNAME: {0},
+	RRTypeDNAME: {0},
+	RRTypeKX:    {1},
+	RRTypeMINFO: {0, 1},
+	RRTypeMR:    {0},
+	RRTypeMX:    {1},
+	RRTypeNAPTR: {-1},
+	RRTypeNS:    {0},
+	RRTypePTR:   {0},
+	RRTypeRP:    {0, 1},
+	RRTypeSOA:   {0, 1},
+	RRTypeSRV:   {3},
+}
+
+// convertContentNames applies a conversion to all domain names within the content of a resource record.
+// Only the name fields are rewritten, whitespace and quoted strings are kept as they are.
+func convertContentNames(recordType RRType, content string, convert func(string) (string, error)) (string, error) {
+	positions, ok := nameContentFields[recordType]
+	if !ok {
+		return content, nil
+	}
+
+	fields := contentFieldRanges(content)
+	indices := make([]int, 0, len(positions))
+	for _, position := range positions {
+		if position < 0 {
+			position += len(fields)
+		}
+		if position >= 0 && position < len(fields) {
+			indices = append(indices, position)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(indices)))
+
+	// Fields are replaced from the end, so that the offsets of the preceding fields remain valid
+	for _, index := range indices {
+		start, end := fields[index][0], fields[index][1]
+		converted, err := convert(content[start:end])
+		if err != nil {
+			return "", err
+		}
+		content = content[:start] + converted + content[end:]
+	}
+
+	return content, nil
+}
+
+// contentFieldRanges returns the byte ranges of the whitespace-separated fields of a record content.
+// Quoted strings including their quotes form a single field, even if they contain whitespace.
+func contentFieldRanges(content string) [][2]int {
+	fields := make([][2]int, 0)
+	for i := 0; i < len(content); {
+		if content[i] == ' ' || content[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		quoted := content[i] == '"'
+		for i++; i < len(content); i++ {
+			if content[i] == '\\' {
+				i++
+				continue
+			}
+			if quoted && content[i] == '"' {
+				i++
+				break
+			}
+			if !quoted && (content[i] == ' ' || content[i] == '\t') {
+				break
+			}
+		}
+		if i > len(content) {
+			i = len(content)
+		}
+		fields = append(fields, [2]int{start, i})
+	}
+	return fields
+}
+
+// convertRRSetNames applies a conversion to the name and the name-valued content of a resource record set
+func convertRRSetNames(rrset *RRset, convert func(string) (string, error)) error {
+	if rrset.Name != nil {
+		name, err := convert(*rrset.Name)
+		if err != nil {
+			return err
+		}
+		rrset.Name = String(name)
+	}
+
+	if rrset.Type == nil {
+		return nil
+	}
+
+	for i := range rrset.Records {
+		if rrset.Records[i].Content == nil {
+			continue
+		}
+
+		content, err := convertContentNames(*rrset.Type, *rrset.Records[i].Content, convert)
+		if err != nil {
+			return err
+		}
+		rrset.Records[i].Content = String(content)
+	}
+
+	return nil
+}
+
+// zoneToUnicode converts the names of a zone received from the server to their Unicode form
+func zoneToUnicode(zone *Zone) {
+	convert := func(name string) (string, error) {
+		if converted, err := IDNToUnicode(name); err == nil {
+			return converted, nil
+		}
+		return name, nil
+	}
+
+	if zone.Name != nil {
+		name, _ := convert(*zone.Name)
+		zone.Name = String(name)
+	}
+	for i := range zone.Nameservers {
+		zone.Nameservers[i], _ = convert(zone.Nameservers[i])
+	}
+	for i := range zone.RRsets {
+		_ = convertRRSetNames(&zone.RRsets[i], convert)
+	}
+}
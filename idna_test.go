@@ -0,0 +1,161 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestIDNToASCII(t *testing.T) {
+	testCases := []struct {
+		name      string
+		wantASCII string
+		wantErr   bool
+	}{
+		{"www.example.com.", "www.example.com.", false},
+		{"bücher.example", "xn--bcher-kva.example", false},
+		{"BÜCHER.example.", "xn--bcher-kva.example.", false},
+		{"www.münchen。de", "www.xn--mnchen-3ya.de", false},
+		{"他们为什么不说中文.example", "xn--ihqwcrb4cv8a8dqg056pqjye.example", false},
+		{"3年B組金八先生", "xn--3b-ww4c5e180e575a65lsy2b", false},
+		{"ｂüｃher.example", "xn--bcher-kva.example", false},
+		{"-bücher.example", "", true},
+		{"bü cher.example", "", true},
+		{"̈bucher.example", "", true},
+		{"bu\u0308cher.example", "xn--bcher-kva.example", false},
+		{"_sip._udp.bücher.example.", "_sip._udp.xn--bcher-kva.example.", false},
+		{"l·l.example", "xn--ll-0ea.example", false},
+		{"a·b.example", "", true},
+		{"a\u200db.example", "", true},
+		{"💩.example", "", true},
+		{"☃.example", "", true},
+		{"שלום.example", "xn--9dbne9b.example", false},
+		{"1.שלום.example", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ascii, err := IDNToASCII(tc.name)
+			if tc.wantErr != errors.Is(err, ErrInvalidIDN) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ascii != tc.wantASCII {
+				t.Errorf("%s != %s", ascii, tc.wantASCII)
+			}
+		})
+	}
+}
+
+func TestIDNToUnicode(t *testing.T) {
+	testCases := []struct {
+		name        string
+		wantUnicode string
+		wantErr     bool
+	}{
+		{"www.example.com.", "www.example.com.", false},
+		{"xn--bcher-kva.example.", "bücher.example.", false},
+		{"XN--BCHER-KVA.example", "bücher.example", false},
+		{"xn--ihqwcrb4cv8a8dqg056pqjye", "他们为什么不说中文", false},
+		{"xn--bcher-!!!.example", "", true},
+		{"xn--bcher-kva9999999999.example", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			unicodeName, err := IDNToUnicode(tc.name)
+			if tc.wantErr != errors.Is(err, ErrInvalidIDN) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if unicodeName != tc.wantUnicode {
+				t.Errorf("%s != %s", unicodeName, tc.wantUnicode)
+			}
+		})
+	}
+}
+
+func TestConvertContentNames(t *testing.T) {
+	testCases := []struct {
+		recordType  RRType
+		content     string
+		wantContent string
+	}{
+		{RRTypeCNAME, "bücher.example.", "xn--bcher-kva.example."},
+		{RRTypeMX, "10 bücher.example.", "10 xn--bcher-kva.example."},
+		{RRTypeSRV, "0 5 5060 sip.bücher.example.", "0 5 5060 sip.xn--bcher-kva.example."},
+		{RRTypeNAPTR, "100 10 \"S\" \"SIP+D2U\" \"!^ .*$!x!\" _sip._udp.bücher.example.", "100 10 \"S\" \"SIP+D2U\" \"!^ .*$!x!\" _sip._udp.xn--bcher-kva.example."},
+		{RRTypeTXT, "\"bücher.example\"", "\"bücher.example\""},
+		{RRTypeMX, "10  bücher.example.", "10  xn--bcher-kva.example."},
+		{RRTypeSOA, "ns.bücher.example.\thostmaster.bücher.example. 1 7200 3600 1209600 300", "ns.xn--bcher-kva.example.\thostmaster.xn--bcher-kva.example. 1 7200 3600 1209600 300"},
+		{RRTypeNAPTR, "100 10 \"S\" \"SIP+D2U\" \"!^(a  b)\\\" .*$!x!\" bücher.example.", "100 10 \"S\" \"SIP+D2U\" \"!^(a  b)\\\" .*$!x!\" xn--bcher-kva.example."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.recordType), func(t *testing.T) {
+			content, err := convertContentNames(tc.recordType, tc.content, IDNToASCII)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if content != tc.wantContent {
+				t.Errorf("%s != %s", content, tc.wantContent)
+			}
+		})
+	}
+}
+
+func TestIDNRecordsAndZones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"xn--bcher-kva.example.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if err := p.Records.Add(ctx, "bücher.example", "www.bücher.example", RRTypeCNAME, 300, []string{"web.bücher.example"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.AddByFQDN(ctx, "shop.bücher.example", RRTypeMX, 300, []string{"10 mx.bücher.example."}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	rrsets := patched["xn--bcher-kva.example."]
+	if len(rrsets) != 2 || *rrsets[0].Name != "www.xn--bcher-kva.example." || *rrsets[0].Records[0].Content != "web.xn--bcher-kva.example." {
+		t.Fatalf("Invalid patches: %+v", rrsets)
+	}
+	if *rrsets[1].Name != "shop.xn--bcher-kva.example." || *rrsets[1].Records[0].Content != "10 mx.xn--bcher-kva.example." {
+		t.Fatalf("Invalid patches: %+v", rrsets)
+	}
+
+	zone, err := p.Zones.Get(ctx, "bücher.example")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *zone.Name != "xn--bcher-kva.example." {
+		t.Errorf("Zone name has been converted: %s", *zone.Name)
+	}
+
+	p.UnicodeResponses = true
+	zone, err = p.Zones.Get(ctx, "bücher.example")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *zone.Name != "bücher.example." {
+		t.Errorf("Zone name has not been converted: %s", *zone.Name)
+	}
+	if cname := findRRSet(zone.RRsets, "www.bücher.example.", RRTypeCNAME); cname == nil || *cname.Records[0].Content != "web.bücher.example." {
+		t.Errorf("RRset has not been converted: %+v", zone.RRsets)
+	}
+
+	enclosing, err := p.Zones.FindEnclosing(ctx, "www.bücher.example")
+	if err != nil || *enclosing.Name != "bücher.example." {
+		t.Errorf("Invalid enclosing zone: %v, %v", enclosing, err)
+	}
+
+	if err := p.Records.Add(ctx, "bücher.example", "-ungültig.bücher.example", RRTypeA, 300, []string{"192.0.2.1"}); !errors.Is(err, ErrInvalidIDN) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// MetadataService handles communication with the zone metadata related methods of the Client API
+type MetadataService service
+
+// ErrInvalidMetadata is returned if metadata values do not match the format of their kind
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata structure with JSON API metadata
+type Metadata struct {
+	Type     *string       `json:"type,omitempty"`
+	Kind     *MetadataKind `json:"kind,omitempty"`
+	Metadata []string      `json:"metadata"`
+}
+
+// MetadataKind represents a string-valued metadata kind
+type MetadataKind string
+
+// MetadataKindPtr is a helper function that allocates a new MetadataKind value to store v and returns a pointer to it.
+func MetadataKindPtr(v MetadataKind) *MetadataKind {
+	return &v
+}
+
+const (
+	// MetadataAllowAXFRFrom lists the IP ranges which are allowed to perform AXFR, or AUTO-NS
+	MetadataAllowAXFRFrom MetadataKind = "ALLOW-AXFR-FROM"
+	// MetadataAllowDNSUpdateFrom lists the IP ranges which are allowed to perform DNS updates
+	MetadataAllowDNSUpdateFrom MetadataKind = "ALLOW-DNSUPDATE-FROM"
+	// MetadataAlsoNotify lists additional IP addresses, optionally with port, which receive notifications
+	MetadataAlsoNotify MetadataKind = "ALSO-NOTIFY"
+	// MetadataAPIRectify enables rectification after changes made through the API
+	MetadataAPIRectify MetadataKind = "API-RECTIFY"
+	// MetadataAXFRMasterTSIG names the TSIG key used for zone transfers from the master
+	MetadataAXFRMasterTSIG MetadataKind = "AXFR-MASTER-TSIG"
+	// MetadataAXFRSource defines the source IP address of outgoing zone transfers
+	MetadataAXFRSource MetadataKind = "AXFR-SOURCE"
+	// MetadataForwardDNSUpdate forwards DNS updates to the master
+	MetadataForwardDNSUpdate MetadataKind = "FORWARD-DNSUPDATE"
+	// MetadataIXFR enables IXFR for slave zones
+	MetadataIXFR MetadataKind = "IXFR"
+	// MetadataNotifyDNSUpdate sends notifications after DNS updates
+	MetadataNotifyDNSUpdate MetadataKind = "NOTIFY-DNSUPDATE"
+	// MetadataPublishCDNSKEY publishes CDNSKEY records of the KSKs
+	MetadataPublishCDNSKEY MetadataKind = "PUBLISH-CDNSKEY"
+	// MetadataPublishCDS publishes CDS records with a comma-separated list of digest types
+	MetadataPublishCDS MetadataKind = "PUBLISH-CDS"
+	// MetadataSlaveRenotify re-sends notifications after a slave zone has been transferred
+	MetadataSlaveRenotify MetadataKind = "SLAVE-RENOTIFY"
+	// MetadataSOAEdit defines how the SOA serial is modified when the zone is served
+	MetadataSOAEdit MetadataKind = "SOA-EDIT"
+	// MetadataSOAEditAPI defines how the SOA serial is modified after changes made through the API
+	MetadataSOAEditAPI MetadataKind = "SOA-EDIT-API"
+	// MetadataSOAEditDNSUpdate defines how the SOA serial is modified after DNS updates
+	MetadataSOAEditDNSUpdate MetadataKind = "SOA-EDIT-DNSUPDATE"
+	// MetadataTSIGAllowAXFR names the TSIG keys which are allowed to perform AXFR
+	MetadataTSIGAllowAXFR MetadataKind = "TSIG-ALLOW-AXFR"
+	// MetadataTSIGAllowDNSUpdate names the TSIG keys which are allowed to perform DNS updates
+	MetadataTSIGAllowDNSUpdate MetadataKind = "TSIG-ALLOW-DNSUPDATE"
+)
+
+// customMetadataPrefix marks metadata kinds which are not interpreted by PowerDNS
+const customMetadataPrefix = "X-"
+
+// CustomMetadataKind returns the kind of a custom metadata key, prefixed with "X-"
+func CustomMetadataKind(name string) MetadataKind {
+	if strings.HasPrefix(strings.ToUpper(name), customMetadataPrefix) {
+		return MetadataKind(name)
+	}
+	return MetadataKind(customMetadataPrefix + name)
+}
+
+// IsCustom reports whether a metadata kind is a custom "X-" kind
+func (k MetadataKind) IsCustom() bool {
+	return strings.HasPrefix(strings.ToUpper(string(k)), customMetadataPrefix)
+}
+
+// metadataValidators contains the value validators of the known metadata kinds
+var metadataValidators = map[MetadataKind]func([]string) error{
+	MetadataAllowAXFRFrom:      validateAllowAXFRFrom,
+	MetadataAllowDNSUpdateFrom: validateEach(validatePrefix),
+	MetadataAlsoNotify:         validateEach(validateAddrPort),
+	MetadataAPIRectify:         validateSingle(validateBoolean),
+	MetadataAXFRMasterTSIG:     validateSingle(validateKeyName),
+	MetadataAXFRSource:         validateSingle(validateAddr),
+	MetadataForwardDNSUpdate:   validateSingle(validateAny),
+	MetadataIXFR:               validateSingle(validateBoolean),
+	MetadataNotifyDNSUpdate:    validateSingle(validateBoolean),
+	MetadataPublishCDNSKEY:     validateSingle(validateBoolean),
+	MetadataPublishCDS:         validateSingle(validateDigestTypes),
+	MetadataSlaveRenotify:      validateSingle(validateBoolean),
+	MetadataSOAEdit:            validateSingle(validateOneOf("INCREMENT-WEEKS", "INCEPTION-EPOCH", "INCEPTION-INCREMENT", "EPOCH", "NONE")),
+	MetadataSOAEditAPI:         validateSingle(validateOneOf("DEFAULT", "INCREASE", "EPOCH", "SOA-EDIT", "SOA-EDIT-INCREASE")),
+	MetadataSOAEditDNSUpdate:   validateSingle(validateOneOf("DEFAULT", "INCREASE", "EPOCH", "SOA-EDIT", "SOA-EDIT-INCREASE")),
+	MetadataTSIGAllowAXFR:      validateEach(validateKeyName),
+	MetadataTSIGAllowDNSUpdate: validateEach(validateKeyName),
+}
+
+// ValidateMetadata checks the values of a metadata kind.
+// Custom "X-" kinds and kinds unknown to this package accept any value, the server has the final say.
+func ValidateMetadata(kind MetadataKind, values []string) error {
+	if kind == "" {
+		return fmt.Errorf("%w: empty kind", ErrInvalidMetadata)
+	}
+
+	validate, ok := metadataValidators[MetadataKind(strings.ToUpper(string(kind)))]
+	if !ok {
+		return nil
+	}
+	if err := validate(values); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidMetadata, kind, err)
+	}
+	return nil
+}
+
+func validateEach(validate func(string) error) func([]string) error {
+	return func(values []string) error {
+		for _, value := range values {
+			if err := validate(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func validateSingle(validate func(string) error) func([]string) error {
+	return func(values []string) error {
+		if len(values) > 1 {
+			return fmt.Errorf("expected a single value, got %d", len(values))
+		}
+		return validateEach(validate)(values)
+	}
+}
+
+func validateAllowAXFRFrom(values []string) error {
+	return validateEach(func(value string) error {
+		if value == "AUTO-NS" {
+			return nil
+		}
+		return validatePrefix(value)
+	})(values)
+}
+
+func validateAny(string) error {
+	return nil
+}
+
+func validatePrefix(value string) error {
+	if _, err := netip.ParsePrefix(value); err == nil {
+		return nil
+	}
+	if _, err := netip.ParseAddr(value); err == nil {
+		return nil
+	}
+	return fmt.Errorf("%q is neither an IP address nor a CIDR range", value)
+}
+
+func validateAddr(value string) error {
+	if _, err := netip.ParseAddr(value); err != nil {
+		return fmt.Errorf("%q is not an IP address", value)
+	}
+	return nil
+}
+
+func validateAddrPort(value string) error {
+	if _, err := netip.ParseAddrPort(value); err == nil {
+		return nil
+	}
+	return validateAddr(value)
+}
+
+func validateBoolean(value string) error {
+	if value != "0" && value != "1" {
+		return fmt.Errorf("%q is neither 0 nor 1", value)
+	}
+	return nil
+}
+
+func validateKeyName(value string) error {
+	if value == "" || strings.ContainsAny(value, " \t") {
+		return fmt.Errorf("%q is not a valid key name", value)
+	}
+	return nil
+}
+
+func validateDigestTypes(value string) error {
+	for _, digestType := range strings.Split(value, ",") {
+		if _, err := strconv.ParseUint(strings.TrimSpace(digestType), 10, 8); err != nil {
+			return fmt.Errorf("%q is not a comma-separated list of digest types", value)
+		}
+	}
+	return nil
+}
+
+func validateOneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+func metadataPath(vHost string, domain string, kind MetadataKind) string {
+	path := fmt.Sprintf("servers/%s/zones/%s/metadata", vHost, escapeZoneID(domain))
+	if kind != "" {
+		path += "/" + string(kind)
+	}
+	return path
+}
+
+// List retrieves all metadata of a zone
+func (m *MetadataService) List(ctx context.Context, domain string) ([]Metadata, error) {
+	req, err := m.client.newRequest(ctx, "GET", metadataPath(m.client.VHost, domain, ""), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make([]Metadata, 0)
+	_, err = m.client.do(req, &metadata)
+	return metadata, err
+}
+
+// Get returns the values of a metadata kind
+func (m *MetadataService) Get(ctx context.Context, domain string, kind MetadataKind) (*Metadata, error) {
+	req, err := m.client.newRequest(ctx, "GET", metadataPath(m.client.VHost, domain, kind), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := new(Metadata)
+	_, err = m.client.do(req, &metadata)
+	return metadata, err
+}
+
+// Set replaces all values of a metadata kind
+func (m *MetadataService) Set(ctx context.Context, domain string, kind MetadataKind, values []string) (*Metadata, error) {
+	if err := ValidateMetadata(kind, values); err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{Kind: &kind, Metadata: values}
+	if metadata.Metadata == nil {
+		metadata.Metadata = make([]string, 0)
+	}
+
+	req, err := m.client.newRequest(ctx, "PUT", metadataPath(m.client.VHost, domain, kind), nil, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := new(Metadata)
+	_, err = m.client.do(req, &updated)
+	return updated, err
+}
+
+// Append adds values to a metadata kind, keeping the existing values
+func (m *MetadataService) Append(ctx context.Context, domain string, kind MetadataKind, values []string) (*Metadata, error) {
+	if err := ValidateMetadata(kind, values); err != nil {
+		return nil, err
+	}
+
+	// Single-valued kinds must not end up with several values
+	existing, err := m.Get(ctx, domain, kind)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := ValidateMetadata(kind, append(append([]string{}, existing.Metadata...), values...)); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata := &Metadata{Kind: &kind, Metadata: values}
+	req, err := m.client.newRequest(ctx, "POST", metadataPath(m.client.VHost, domain, ""), nil, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := new(Metadata)
+	_, err = m.client.do(req, &updated)
+	return updated, err
+}
+
+// Delete removes all values of a metadata kind
+func (m *MetadataService) Delete(ctx context.Context, domain string, kind MetadataKind) error {
+	req, err := m.client.newRequest(ctx, "DELETE", metadataPath(m.client.VHost, domain, kind), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.client.do(req, nil)
+	return err
+}
@@ -0,0 +1,239 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func registerMetadataMockResponder(testDomain string, state map[MetadataKind][]string) {
+	var mu sync.Mutex
+	baseURL := generateTestAPIVHostURL() + "/zones/" + testDomain + "/metadata"
+
+	respond := func(kind MetadataKind) (*http.Response, error) {
+		values, ok := state[kind]
+		if !ok {
+			return httpmock.NewJsonResponse(http.StatusNotFound, Error{Message: "Not Found"})
+		}
+		return httpmock.NewJsonResponse(http.StatusOK, Metadata{Type: String("Metadata"), Kind: MetadataKindPtr(kind), Metadata: values})
+	}
+
+	httpmock.RegisterResponder("GET", baseURL,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			metadataMock := make([]Metadata, 0, len(state))
+			for kind, values := range state {
+				metadataMock = append(metadataMock, Metadata{Type: String("Metadata"), Kind: MetadataKindPtr(kind), Metadata: values})
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, metadataMock)
+		},
+	)
+
+	httpmock.RegisterResponder("POST", baseURL,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var metadata Metadata
+			if json.NewDecoder(req.Body).Decode(&metadata) != nil || metadata.Kind == nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			state[*metadata.Kind] = append(state[*metadata.Kind], metadata.Metadata...)
+			return respond(*metadata.Kind)
+		},
+	)
+
+	httpmock.RegisterResponder("GET", `=~^`+baseURL+`/[^/]+\z`,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			return respond(MetadataKind(req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]))
+		},
+	)
+
+	httpmock.RegisterResponder("PUT", `=~^`+baseURL+`/[^/]+\z`,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var metadata Metadata
+			if json.NewDecoder(req.Body).Decode(&metadata) != nil || metadata.Metadata == nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			kind := MetadataKind(req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+			state[kind] = metadata.Metadata
+			return respond(kind)
+		},
+	)
+
+	httpmock.RegisterResponder("DELETE", `=~^`+baseURL+`/[^/]+\z`,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			delete(state, MetadataKind(req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]))
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+}
+
+func TestValidateMetadata(t *testing.T) {
+	testCases := []struct {
+		kind    MetadataKind
+		values  []string
+		wantErr bool
+	}{
+		{MetadataAllowAXFRFrom, []string{"AUTO-NS", "192.0.2.0/24", "2001:db8::/32", "198.51.100.1"}, false},
+		{MetadataAllowAXFRFrom, []string{"192.0.2.0/33"}, true},
+		{MetadataAllowAXFRFrom, []string{"example.com"}, true},
+		{MetadataAlsoNotify, []string{"192.0.2.1", "192.0.2.2:5300", "[2001:db8::1]:53"}, false},
+		{MetadataAlsoNotify, []string{"192.0.2.1:x"}, true},
+		{MetadataAXFRSource, []string{"192.0.2.1"}, false},
+		{MetadataAXFRSource, []string{"192.0.2.1", "192.0.2.2"}, true},
+		{MetadataTSIGAllowAXFR, []string{"transfer-key"}, false},
+		{MetadataTSIGAllowAXFR, []string{"invalid key"}, true},
+		{MetadataSOAEdit, []string{"INCEPTION-INCREMENT"}, false},
+		{MetadataSOAEdit, []string{"INCREASE"}, true},
+		{MetadataPublishCDS, []string{"2, 4"}, false},
+		{MetadataPublishCDS, []string{"SHA256"}, true},
+		{MetadataPublishCDNSKEY, []string{"1"}, false},
+		{MetadataPublishCDNSKEY, []string{"yes"}, true},
+		{CustomMetadataKind("foo"), []string{"anything", "goes"}, false},
+		{"", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.kind), func(t *testing.T) {
+			err := ValidateMetadata(tc.kind, tc.values)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("%s %v: got error %v", tc.kind, tc.values, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidMetadata) {
+				t.Errorf("%s is not ErrInvalidMetadata", err)
+			}
+		})
+	}
+}
+
+func TestCustomMetadataKind(t *testing.T) {
+	if CustomMetadataKind("foo") != "X-foo" || CustomMetadataKind("X-foo") != "X-foo" {
+		t.Error("Invalid custom metadata kind")
+	}
+	if !CustomMetadataKind("foo").IsCustom() || MetadataSOAEdit.IsCustom() {
+		t.Error("Invalid IsCustom result")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerMetadataMockResponder(testDomain, map[MetadataKind][]string{MetadataSOAEdit: {"INCEPTION-INCREMENT"}})
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if _, err := p.Metadata.Set(ctx, testDomain, MetadataAllowAXFRFrom, []string{"192.0.2.0/24"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	metadata, err := p.Metadata.Append(ctx, testDomain, MetadataAllowAXFRFrom, []string{"AUTO-NS"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(metadata.Metadata) != 2 || metadata.Metadata[1] != "AUTO-NS" {
+		t.Errorf("Invalid metadata after append: %v", metadata.Metadata)
+	}
+
+	if _, err := p.Metadata.Append(ctx, testDomain, MetadataSOAEdit, []string{"EPOCH"}); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("Expected ErrInvalidMetadata for a second value of a single-valued kind, got %v", err)
+	}
+
+	metadata, err = p.Metadata.Get(ctx, testDomain, MetadataSOAEdit)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *metadata.Kind != MetadataSOAEdit || metadata.Metadata[0] != "INCEPTION-INCREMENT" {
+		t.Errorf("Invalid metadata: %+v", metadata)
+	}
+
+	if err := p.Metadata.Delete(ctx, testDomain, MetadataSOAEdit); err != nil {
+		t.Fatalf("%s", err)
+	}
+	list, err := p.Metadata.List(ctx, testDomain)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(list) != 1 || *list[0].Kind != MetadataAllowAXFRFrom {
+		t.Errorf("Invalid metadata list: %+v", list)
+	}
+
+	if _, err := p.Metadata.Get(ctx, testDomain, MetadataSOAEdit); !isNotFound(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestMetadataPath(t *testing.T) {
+	u := generateAPIURL("http", "localhost", "8080", metadataPath("localhost", "example.com", "X-Custom Kind"), nil)
+	if want := "http://localhost:8080/api/v1/servers/localhost/zones/example.com/metadata/X-Custom%20Kind"; u.String() != want {
+		t.Errorf("%s != %s", u.String(), want)
+	}
+}
+
+func TestSetMetadataInvalid(t *testing.T) {
+	testDomain := generateTestZone(false)
+	p := initialisePowerDNSTestClient()
+	if _, err := p.Metadata.Set(context.Background(), testDomain, MetadataAllowAXFRFrom, []string{"invalid"}); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("Expected ErrInvalidMetadata, got %v", err)
+	}
+	if _, err := p.Metadata.Append(context.Background(), testDomain, MetadataAXFRSource, []string{"invalid"}); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("Expected ErrInvalidMetadata, got %v", err)
+	}
+}
+
+func TestMetadataError(t *testing.T) {
+	testDomain := generateTestZone(false)
+	p := initialisePowerDNSTestClient()
+	p.Port = "x"
+	ctx := context.Background()
+	if _, err := p.Metadata.List(ctx, testDomain); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.Metadata.Get(ctx, testDomain, MetadataSOAEdit); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.Metadata.Set(ctx, testDomain, MetadataSOAEdit, []string{"EPOCH"}); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.Metadata.Append(ctx, testDomain, MetadataSOAEdit, []string{"EPOCH"}); err == nil {
+		t.Error("error is nil")
+	}
+	if err := p.Metadata.Delete(ctx, testDomain, MetadataSOAEdit); err == nil {
+		t.Error("error is nil")
+	}
+}
@@ -0,0 +1,621 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MultiSignerOperationType represents a change of the signers of a multi-signer zone
+type MultiSignerOperationType string
+
+const (
+	// MultiSignerAddSigner adds a signer to a zone which is served by the other signer (RFC 8901, section 8.1)
+	MultiSignerAddSigner MultiSignerOperationType = "add-signer"
+	// MultiSignerRemoveSigner removes a signer from a zone which is served by both signers (RFC 8901, section 8.2)
+	MultiSignerRemoveSigner MultiSignerOperationType = "remove-signer"
+)
+
+// MultiSignerPhase represents the state of a multi-signer operation
+type MultiSignerPhase string
+
+const (
+	// MultiSignerPhaseDNSKEYPublished waits until the DNSKEY RRsets containing the ZSKs of both signers are known to all resolvers
+	MultiSignerPhaseDNSKEYPublished MultiSignerPhase = "dnskey-published"
+	// MultiSignerPhaseWaitingForDS waits until the DS records of the joining signer are present at the parent
+	MultiSignerPhaseWaitingForDS MultiSignerPhase = "waiting-for-ds"
+	// MultiSignerPhaseDSPublished waits until the DS records of the joining signer are known to all resolvers
+	MultiSignerPhaseDSPublished MultiSignerPhase = "ds-published"
+	// MultiSignerPhaseWaitingForNS waits until the NS RRset at the parent matches the NS RRset of the zone
+	MultiSignerPhaseWaitingForNS MultiSignerPhase = "waiting-for-ns"
+	// MultiSignerPhaseNSWithdrawn waits until the name servers of the leaving signer are not used by any resolver anymore
+	MultiSignerPhaseNSWithdrawn MultiSignerPhase = "ns-withdrawn"
+	// MultiSignerPhaseWaitingForDSRemoval waits until the DS records of the leaving signer are removed from the parent
+	MultiSignerPhaseWaitingForDSRemoval MultiSignerPhase = "waiting-for-ds-removal"
+	// MultiSignerPhaseDSWithdrawn waits until the DS records of the leaving signer are not used by any resolver anymore
+	MultiSignerPhaseDSWithdrawn MultiSignerPhase = "ds-withdrawn"
+	// MultiSignerPhaseDone marks a finished operation
+	MultiSignerPhaseDone MultiSignerPhase = "done"
+)
+
+// MultiSignerState is the state of an add or remove signer operation.
+// It can be serialized to JSON, so that the operation can be resumed after a restart.
+type MultiSignerState struct {
+	Zone string                   `json:"zone"`
+	Type MultiSignerOperationType `json:"type"`
+	// Signer is the index of the joining or leaving signer
+	Signer       int              `json:"signer"`
+	Phase        MultiSignerPhase `json:"phase"`
+	Nameservers  [2][]string      `json:"nameservers"`
+	DS           []string         `json:"ds"`
+	StartedAt    time.Time        `json:"started_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	NextActionAt time.Time        `json:"next_action_at"`
+}
+
+// Done reports whether the operation has finished
+func (s *MultiSignerState) Done() bool {
+	return s.Phase == MultiSignerPhaseDone
+}
+
+// MultiSignerStatus describes the differences between the zone of a signer and the combined DNSKEY, CDS, CDNSKEY and NS RRsets
+type MultiSignerStatus struct {
+	Signer            int
+	MissingDNSKEY     []string
+	UnexpectedDNSKEY  []string
+	MissingCDS        []string
+	UnexpectedCDS     []string
+	MissingCDNSKEY    []string
+	UnexpectedCDNSKEY []string
+	MissingNS         []string
+	UnexpectedNS      []string
+}
+
+// InSync reports whether the zone of the signer contains the combined RRsets
+func (s *MultiSignerStatus) InSync() bool {
+	return len(s.MissingDNSKEY) == 0 && len(s.UnexpectedDNSKEY) == 0 && len(s.MissingCDS) == 0 && len(s.UnexpectedCDS) == 0 &&
+		len(s.MissingCDNSKEY) == 0 && len(s.UnexpectedCDNSKEY) == 0 && len(s.MissingNS) == 0 && len(s.UnexpectedNS) == 0
+}
+
+// MultiSigner coordinates a zone which is signed independently by two PowerDNS servers (RFC 8901, model 2).
+// Each server keeps its own KSK and ZSK. The ZSKs of the other server are published as DNSKEY records in the zone,
+// which requires direct-dnskey=yes on both servers. CDS and CDNSKEY records are written explicitly,
+// so that both servers publish the KSKs of both signers.
+type MultiSigner struct {
+	zone    string
+	clients [2]*Client
+
+	// Nameservers lists the name servers operated by each signer. If empty, the NS RRset of the signer's zone is used.
+	Nameservers [2][]string
+	// TTL of the DNSKEY, CDS, CDNSKEY and NS records written by the coordinator
+	TTL uint32
+	// DigestTypes defines which CDS records are published
+	DigestTypes []DSDigestType
+	// Clock defines the current time, it can be replaced for testing
+	Clock Clock
+	// ParentDS returns the DS records of the zone at the parent, it is required for adding and removing signers
+	ParentDS func(ctx context.Context, zone string) ([]string, error)
+	// ParentNS returns the NS records of the zone at the parent. If nil, changes of the delegation are not awaited.
+	ParentNS func(ctx context.Context, zone string) ([]string, error)
+	// PollInterval defines the delay between two checks of the parent
+	PollInterval time.Duration
+	// PropagationDelay is the time until changes reach all authoritative servers of a signer
+	PropagationDelay time.Duration
+	// ParentDSTTL is the TTL of the DS records at the parent. If zero, TTL is used.
+	ParentDSTTL time.Duration
+}
+
+// NewMultiSigner creates a coordinator for a zone served by two signers, which publishes SHA-256 CDS records
+func NewMultiSigner(zone string, a *Client, b *Client) *MultiSigner {
+	return &MultiSigner{
+		zone:         strings.ToLower(makeDomainCanonical(idnToASCIIOrRaw(zone))),
+		clients:      [2]*Client{a, b},
+		TTL:          3600,
+		DigestTypes:  []DSDigestType{DigestSHA256},
+		Clock:        systemClock{},
+		PollInterval: time.Hour,
+	}
+}
+
+// multiSignerKeys contains the DNSKEYs of a signer
+type multiSignerKeys struct {
+	ksks []*DNSKEY
+	zsks []*DNSKEY
+}
+
+// multiSignerView defines which signers contribute to the combined RRsets.
+// The zone of a signer always contains its own contribution.
+type multiSignerView struct {
+	dnskey [2]bool
+	cds    [2]bool
+	ns     [2]bool
+}
+
+var multiSignerBoth = [2]bool{true, true}
+
+// multiSignerOnly returns a view part which only contains a single signer
+func multiSignerOnly(signer int) [2]bool {
+	var only [2]bool
+	only[signer] = true
+	return only
+}
+
+// multiSignerContents are the combined RRsets expected in the zone of a signer
+type multiSignerContents struct {
+	dnskey  []string
+	cds     []string
+	cdnskey []string
+	ns      []string
+}
+
+// keys returns the published ZSKs and the active and published KSKs of a signer. CSKs count as both.
+func (m *MultiSigner) keys(ctx context.Context, signer int) (*multiSignerKeys, error) {
+	cryptokeys, err := m.clients[signer].Cryptokeys.List(ctx, m.zone)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &multiSignerKeys{ksks: make([]*DNSKEY, 0), zsks: make([]*DNSKEY, 0)}
+	for _, cryptokey := range cryptokeys {
+		if cryptokey.Published != nil && !*cryptokey.Published {
+			continue
+		}
+		dnskey, err := cryptokey.ParsedDNSKEY()
+		if err != nil {
+			return nil, err
+		}
+
+		keyType := CryptokeyType(StringValue(cryptokey.KeyType))
+		if (keyType == CryptokeyTypeKSK || keyType == CryptokeyTypeCSK) && BoolValue(cryptokey.Active) {
+			keys.ksks = append(keys.ksks, dnskey)
+		}
+		if keyType == CryptokeyTypeZSK || keyType == CryptokeyTypeCSK {
+			keys.zsks = append(keys.zsks, dnskey)
+		}
+	}
+	return keys, nil
+}
+
+func (m *MultiSigner) allKeys(ctx context.Context) ([2]*multiSignerKeys, error) {
+	var keys [2]*multiSignerKeys
+	for signer := range m.clients {
+		var err error
+		if keys[signer], err = m.keys(ctx, signer); err != nil {
+			return keys, fmt.Errorf("signer %d: %w", signer, err)
+		}
+	}
+	return keys, nil
+}
+
+// ds returns the CDS records of the KSKs of a signer
+func (m *MultiSigner) ds(keys *multiSignerKeys) ([]string, error) {
+	ds := make([]string, 0, len(keys.ksks)*len(m.DigestTypes))
+	for _, ksk := range keys.ksks {
+		for _, digestType := range m.DigestTypes {
+			record, err := ksk.DS(m.zone, digestType)
+			if err != nil {
+				return nil, err
+			}
+			ds = append(ds, record.String())
+		}
+	}
+	return ds, nil
+}
+
+// nameservers returns the configured name servers of both signers, falling back to the NS RRsets of the zones
+func (m *MultiSigner) nameservers(zones [2]*Zone) [2][]string {
+	var nameservers [2][]string
+	for signer, zone := range zones {
+		nameservers[signer] = m.Nameservers[signer]
+		if len(nameservers[signer]) == 0 {
+			nameservers[signer] = nsContents(findRRSet(zone.RRsets, m.zone, RRTypeNS))
+		}
+	}
+	return nameservers
+}
+
+func (m *MultiSigner) zones(ctx context.Context) ([2]*Zone, error) {
+	var zones [2]*Zone
+	for signer, client := range m.clients {
+		var err error
+		if zones[signer], err = client.Zones.get(ctx, m.zone); err != nil {
+			return zones, fmt.Errorf("signer %d: %w", signer, err)
+		}
+	}
+	return zones, nil
+}
+
+// expected returns the combined RRsets for the zone of a signer
+func (m *MultiSigner) expected(signer int, keys [2]*multiSignerKeys, nameservers [2][]string, view *multiSignerView) (*multiSignerContents, error) {
+	contents := &multiSignerContents{dnskey: make([]string, 0), cds: make([]string, 0), cdnskey: make([]string, 0), ns: make([]string, 0)}
+	for other := range keys {
+		if other != signer && view.dnskey[other] {
+			for _, zsk := range keys[other].zsks {
+				contents.dnskey = append(contents.dnskey, zsk.String())
+			}
+		}
+		if other == signer || view.cds[other] {
+			ds, err := m.ds(keys[other])
+			if err != nil {
+				return nil, err
+			}
+			contents.cds = append(contents.cds, ds...)
+			for _, ksk := range keys[other].ksks {
+				contents.cdnskey = append(contents.cdnskey, ksk.String())
+			}
+		}
+		if other == signer || view.ns[other] {
+			for _, ns := range nameservers[other] {
+				if !containsFold(contents.ns, normalizeNS(ns)) {
+					contents.ns = append(contents.ns, normalizeNS(ns))
+				}
+			}
+		}
+	}
+	return contents, nil
+}
+
+func normalizeDNSKEY(content string) string {
+	if dnskey, err := ParseDNSKEY(content); err == nil {
+		return dnskey.String()
+	}
+	return content
+}
+
+// status compares the zone of a signer with its combined RRsets
+func (m *MultiSigner) status(signer int, zone *Zone, contents *multiSignerContents) *MultiSignerStatus {
+	status := &MultiSignerStatus{Signer: signer}
+	status.MissingDNSKEY, status.UnexpectedDNSKEY = diffContents(contents.dnskey, rrsetContents(findRRSet(zone.RRsets, m.zone, RRTypeDNSKEY)), normalizeDNSKEY)
+	status.MissingCDS, status.UnexpectedCDS = diffContents(contents.cds, rrsetContents(findRRSet(zone.RRsets, m.zone, RRTypeCDS)), normalizeDS)
+	status.MissingCDNSKEY, status.UnexpectedCDNSKEY = diffContents(contents.cdnskey, rrsetContents(findRRSet(zone.RRsets, m.zone, RRTypeCDNSKEY)), normalizeDNSKEY)
+	status.MissingNS, status.UnexpectedNS = diffContents(contents.ns, nsContents(findRRSet(zone.RRsets, m.zone, RRTypeNS)), normalizeNS)
+	return status
+}
+
+// apply writes the combined RRsets of a view into the zones of both signers. Unchanged RRsets are left untouched.
+func (m *MultiSigner) apply(ctx context.Context, keys [2]*multiSignerKeys, nameservers [2][]string, view *multiSignerView) error {
+	zones, err := m.zones(ctx)
+	if err != nil {
+		return err
+	}
+
+	for signer, client := range m.clients {
+		contents, err := m.expected(signer, keys, nameservers, view)
+		if err != nil {
+			return err
+		}
+		status := m.status(signer, zones[signer], contents)
+
+		rrsets := make([]RRset, 0, 4)
+		if len(status.MissingDNSKEY) > 0 || len(status.UnexpectedDNSKEY) > 0 {
+			rrsets = append(rrsets, delegationRRSet(m.zone, RRTypeDNSKEY, m.TTL, contents.dnskey))
+		}
+		if len(status.MissingCDS) > 0 || len(status.UnexpectedCDS) > 0 {
+			rrsets = append(rrsets, delegationRRSet(m.zone, RRTypeCDS, m.TTL, contents.cds))
+		}
+		if len(status.MissingCDNSKEY) > 0 || len(status.UnexpectedCDNSKEY) > 0 {
+			rrsets = append(rrsets, delegationRRSet(m.zone, RRTypeCDNSKEY, m.TTL, contents.cdnskey))
+		}
+		if len(status.MissingNS) > 0 || len(status.UnexpectedNS) > 0 {
+			rrsets = append(rrsets, delegationRRSet(m.zone, RRTypeNS, m.TTL, contents.ns))
+		}
+		if len(rrsets) == 0 {
+			continue
+		}
+
+		// Generated CDS and CDNSKEY records would only contain the KSKs of the server itself
+		if len(status.MissingCDS)+len(status.UnexpectedCDS)+len(status.MissingCDNSKEY)+len(status.UnexpectedCDNSKEY) > 0 {
+			if err := client.Zones.SetCDSPublication(ctx, m.zone, &CDSPublication{}); err != nil {
+				return fmt.Errorf("signer %d: %w", signer, err)
+			}
+		}
+		if err := client.Records.Patch(ctx, m.zone, &RRsets{Sets: rrsets}); err != nil {
+			return fmt.Errorf("signer %d: %w", signer, err)
+		}
+	}
+	return nil
+}
+
+// Status compares the zones of both signers with the combined DNSKEY, CDS, CDNSKEY and NS RRsets
+func (m *MultiSigner) Status(ctx context.Context) ([]MultiSignerStatus, error) {
+	keys, err := m.allKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := m.zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nameservers := m.nameservers(zones)
+	view := &multiSignerView{dnskey: multiSignerBoth, cds: multiSignerBoth, ns: multiSignerBoth}
+
+	statuses := make([]MultiSignerStatus, 0, len(zones))
+	for signer, zone := range zones {
+		contents, err := m.expected(signer, keys, nameservers, view)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *m.status(signer, zone, contents))
+	}
+	return statuses, nil
+}
+
+// Sync writes the combined DNSKEY, CDS, CDNSKEY and NS RRsets into the zones of both signers.
+// It is meant for zones which are already served by both signers, e.g. after a ZSK rollover of one of them.
+func (m *MultiSigner) Sync(ctx context.Context) error {
+	keys, err := m.allKeys(ctx)
+	if err != nil {
+		return err
+	}
+	zones, err := m.zones(ctx)
+	if err != nil {
+		return err
+	}
+	return m.apply(ctx, keys, m.nameservers(zones), &multiSignerView{dnskey: multiSignerBoth, cds: multiSignerBoth, ns: multiSignerBoth})
+}
+
+// StartAddSigner adds a signer to a zone which is currently served by the other signer only.
+// The ZSKs of both signers are exchanged first, the CDS records and the name servers of the joining signer follow in later steps.
+func (m *MultiSigner) StartAddSigner(ctx context.Context, signer int) (*MultiSignerState, error) {
+	return m.start(ctx, MultiSignerAddSigner, signer)
+}
+
+// StartRemoveSigner removes a signer from a zone which is served by both signers.
+// The name servers of the leaving signer are withdrawn first, its CDS records and ZSKs follow in later steps.
+func (m *MultiSigner) StartRemoveSigner(ctx context.Context, signer int) (*MultiSignerState, error) {
+	return m.start(ctx, MultiSignerRemoveSigner, signer)
+}
+
+func (m *MultiSigner) start(ctx context.Context, operationType MultiSignerOperationType, signer int) (*MultiSignerState, error) {
+	if signer != 0 && signer != 1 {
+		return nil, fmt.Errorf("invalid signer %d", signer)
+	}
+	if m.ParentDS == nil {
+		return nil, errors.New("no ParentDS check configured")
+	}
+	if len(m.DigestTypes) == 0 {
+		return nil, errors.New("no CDS digest types configured")
+	}
+
+	keys, err := m.allKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if len(keys[i].ksks) == 0 || len(keys[i].zsks) == 0 {
+			return nil, fmt.Errorf("signer %d has no active KSK and published ZSK for zone %s", i, m.zone)
+		}
+	}
+	ds, err := m.ds(keys[signer])
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := m.zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nameservers := m.nameservers(zones)
+	if operationType == MultiSignerRemoveSigner {
+		// Both zones contain the name servers of both signers, so only the configured ones can be told apart
+		if len(m.Nameservers[signer]) == 0 {
+			return nil, fmt.Errorf("name servers of signer %d are unknown", signer)
+		}
+		if len(m.Nameservers[1-signer]) == 0 {
+			leaving := make([]string, 0, len(m.Nameservers[signer]))
+			for _, ns := range m.Nameservers[signer] {
+				leaving = append(leaving, normalizeNS(ns))
+			}
+			remaining := make([]string, 0)
+			for _, ns := range nameservers[1-signer] {
+				if !containsFold(leaving, ns) {
+					remaining = append(remaining, ns)
+				}
+			}
+			nameservers[1-signer] = remaining
+		}
+	}
+	for i := range nameservers {
+		if len(nameservers[i]) == 0 {
+			return nil, fmt.Errorf("signer %d has no name servers", i)
+		}
+	}
+
+	now := m.Clock.Now()
+	state := &MultiSignerState{
+		Zone:        m.zone,
+		Type:        operationType,
+		Signer:      signer,
+		Nameservers: nameservers,
+		DS:          ds,
+		StartedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	remaining := multiSignerOnly(1 - signer)
+	if operationType == MultiSignerAddSigner {
+		if err := m.apply(ctx, keys, nameservers, &multiSignerView{dnskey: multiSignerBoth, cds: remaining, ns: remaining}); err != nil {
+			return nil, err
+		}
+		state.Phase = MultiSignerPhaseDNSKEYPublished
+		state.NextActionAt = now.Add(m.ttl() + m.PropagationDelay)
+		return state, nil
+	}
+
+	if err := m.apply(ctx, keys, nameservers, &multiSignerView{dnskey: multiSignerBoth, cds: multiSignerBoth, ns: remaining}); err != nil {
+		return nil, err
+	}
+	m.awaitNS(state, MultiSignerPhaseNSWithdrawn)
+	return state, nil
+}
+
+// Step advances an operation as far as possible at this point in time. It has to be called periodically until the operation is done.
+func (m *MultiSigner) Step(ctx context.Context, state *MultiSignerState) error {
+	if !strings.EqualFold(state.Zone, m.zone) {
+		return fmt.Errorf("operation for zone %s cannot be continued for zone %s", state.Zone, m.zone)
+	}
+
+	for !state.Done() && !m.Clock.Now().Before(state.NextActionAt) {
+		phase := state.Phase
+		if err := m.advance(ctx, state); err != nil {
+			return err
+		}
+		state.UpdatedAt = m.Clock.Now()
+		if state.Phase == phase {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MultiSigner) advance(ctx context.Context, state *MultiSignerState) error {
+	switch state.Phase {
+	case MultiSignerPhaseDNSKEYPublished:
+		return m.publishDS(ctx, state)
+	case MultiSignerPhaseWaitingForDS:
+		return m.checkDS(ctx, state, true, MultiSignerPhaseDSPublished)
+	case MultiSignerPhaseDSPublished:
+		return m.publishNS(ctx, state)
+	case MultiSignerPhaseWaitingForNS:
+		return m.checkNS(ctx, state)
+	case MultiSignerPhaseNSWithdrawn:
+		return m.withdrawDS(ctx, state)
+	case MultiSignerPhaseWaitingForDSRemoval:
+		return m.checkDS(ctx, state, false, MultiSignerPhaseDSWithdrawn)
+	case MultiSignerPhaseDSWithdrawn:
+		return m.withdrawDNSKEY(ctx, state)
+	default:
+		return fmt.Errorf("unknown multi-signer phase %q", state.Phase)
+	}
+}
+
+func (m *MultiSigner) ttl() time.Duration {
+	return time.Duration(m.TTL) * time.Second
+}
+
+// view returns the contributions for an operation, once all steps up to a given phase have been applied
+func (m *MultiSigner) view(state *MultiSignerState, dnskey bool, cds bool, ns bool) *multiSignerView {
+	view := &multiSignerView{}
+	for _, part := range []struct {
+		target  *[2]bool
+		include bool
+	}{{&view.dnskey, dnskey}, {&view.cds, cds}, {&view.ns, ns}} {
+		*part.target = multiSignerOnly(1 - state.Signer)
+		if part.include {
+			*part.target = multiSignerBoth
+		}
+	}
+	return view
+}
+
+func (m *MultiSigner) applyState(ctx context.Context, state *MultiSignerState, view *multiSignerView) error {
+	keys, err := m.allKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return m.apply(ctx, keys, state.Nameservers, view)
+}
+
+func (m *MultiSigner) publishDS(ctx context.Context, state *MultiSignerState) error {
+	if err := m.applyState(ctx, state, m.view(state, true, true, false)); err != nil {
+		return err
+	}
+	state.Phase = MultiSignerPhaseWaitingForDS
+	state.NextActionAt = m.Clock.Now()
+	return nil
+}
+
+// checkDS waits until the DS records of the joining or leaving signer are present at the parent or removed from it
+func (m *MultiSigner) checkDS(ctx context.Context, state *MultiSignerState, present bool, next MultiSignerPhase) error {
+	if m.ParentDS == nil {
+		return errors.New("no ParentDS check configured")
+	}
+	parentDS, err := m.ParentDS(ctx, state.Zone)
+	if err != nil {
+		return err
+	}
+
+	missing, _ := diffContents(state.DS, parentDS, normalizeDS)
+	if present && len(missing) > 0 || !present && len(missing) < len(state.DS) {
+		state.NextActionAt = m.Clock.Now().Add(m.PollInterval)
+		return nil
+	}
+
+	dsTTL := m.ParentDSTTL
+	if dsTTL == 0 {
+		dsTTL = m.ttl()
+	}
+	state.Phase = next
+	state.NextActionAt = m.Clock.Now().Add(dsTTL + m.PropagationDelay)
+	return nil
+}
+
+func (m *MultiSigner) publishNS(ctx context.Context, state *MultiSignerState) error {
+	if err := m.applyState(ctx, state, m.view(state, true, true, true)); err != nil {
+		return err
+	}
+	m.awaitNS(state, MultiSignerPhaseDone)
+	return nil
+}
+
+// awaitNS continues with the next phase once the parent serves the NS RRset of the zone, if a ParentNS check is configured
+func (m *MultiSigner) awaitNS(state *MultiSignerState, next MultiSignerPhase) {
+	state.NextActionAt = m.Clock.Now()
+	if m.ParentNS != nil {
+		state.Phase = MultiSignerPhaseWaitingForNS
+		return
+	}
+	state.Phase = next
+	if next != MultiSignerPhaseDone {
+		state.NextActionAt = state.NextActionAt.Add(m.ttl() + m.PropagationDelay)
+	}
+}
+
+func (m *MultiSigner) checkNS(ctx context.Context, state *MultiSignerState) error {
+	if m.ParentNS == nil {
+		return errors.New("no ParentNS check configured")
+	}
+	parentNS, err := m.ParentNS(ctx, state.Zone)
+	if err != nil {
+		return err
+	}
+
+	expected := make([]string, 0)
+	for signer, nameservers := range state.Nameservers {
+		if state.Type == MultiSignerAddSigner || signer != state.Signer {
+			expected = append(expected, nameservers...)
+		}
+	}
+	missing, unexpected := diffContents(expected, parentNS, normalizeNS)
+	if len(missing) > 0 || len(unexpected) > 0 {
+		state.NextActionAt = m.Clock.Now().Add(m.PollInterval)
+		return nil
+	}
+
+	if state.Type == MultiSignerAddSigner {
+		state.Phase = MultiSignerPhaseDone
+		return nil
+	}
+	state.Phase = MultiSignerPhaseNSWithdrawn
+	state.NextActionAt = m.Clock.Now().Add(m.ttl() + m.PropagationDelay)
+	return nil
+}
+
+func (m *MultiSigner) withdrawDS(ctx context.Context, state *MultiSignerState) error {
+	if err := m.applyState(ctx, state, m.view(state, true, false, false)); err != nil {
+		return err
+	}
+	state.Phase = MultiSignerPhaseWaitingForDSRemoval
+	state.NextActionAt = m.Clock.Now()
+	return nil
+}
+
+func (m *MultiSigner) withdrawDNSKEY(ctx context.Context, state *MultiSignerState) error {
+	if err := m.applyState(ctx, state, m.view(state, false, false, false)); err != nil {
+		return err
+	}
+	state.Phase = MultiSignerPhaseDone
+	return nil
+}
@@ -0,0 +1,297 @@
+package powerdns
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+)
+
+// testMultiSignerZone is the state of a zone on one of the signers
+type testMultiSignerZone struct {
+	rrsets     []RRset
+	cryptokeys []Cryptokey
+	patches    int
+}
+
+func registerMultiSignerMockResponder(vHost string, zone string, state *testMultiSignerZone) {
+	zoneURL := generateTestAPIURL() + "/servers/" + vHost + "/zones/" + escapeZoneID(zone)
+
+	httpmock.RegisterResponder("GET", zoneURL,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, Zone{ID: String(escapeZoneID(zone) + "."), Name: String(zone), RRsets: state.rrsets})
+		},
+	)
+
+	httpmock.RegisterResponder("PATCH", zoneURL,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var payload RRsets
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				return httpmock.NewBytesResponse(http.StatusBadRequest, []byte{}), nil
+			}
+			state.patches++
+			for _, set := range payload.Sets {
+				state.rrsets = removeTestRRset(state.rrsets, *set.Name, *set.Type)
+				if *set.ChangeType == ChangeTypeReplace {
+					set.ChangeType = nil
+					state.rrsets = append(state.rrsets, set)
+				}
+			}
+			return httpmock.NewBytesResponse(http.StatusNoContent, []byte{}), nil
+		},
+	)
+
+	httpmock.RegisterResponder("GET", zoneURL+"/cryptokeys",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, state.cryptokeys)
+		},
+	)
+
+	httpmock.RegisterResponder("DELETE", `=~^`+zoneURL+`/metadata/`,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewBytesResponse(http.StatusNoContent, []byte{}), nil
+		},
+	)
+}
+
+func generateTestMultiSignerKeys(t *testing.T) (*DNSKEY, *DNSKEY) {
+	t.Helper()
+	keys := make([]*DNSKEY, 0, 2)
+	for _, flags := range []uint16{257, 256} {
+		_, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		key, err := NewSigningKey(flags, AlgorithmED25519, private)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		keys = append(keys, key.DNSKEY)
+	}
+	return keys[0], keys[1]
+}
+
+func generateTestMultiSignerZone(ksk *DNSKEY, zsk *DNSKEY, nameserver string) *testMultiSignerZone {
+	return &testMultiSignerZone{
+		rrsets: []RRset{
+			{Name: String("example.com."), Type: RRTypePtr(RRTypeSOA), TTL: Uint32(3600), Records: []Record{{Content: String("ns1.a.example. hostmaster.example.com. 1 10800 3600 604800 3600")}}},
+			{Name: String("example.com."), Type: RRTypePtr(RRTypeNS), TTL: Uint32(3600), Records: []Record{{Content: String(nameserver)}}},
+		},
+		cryptokeys: []Cryptokey{
+			{ID: Uint64(1), KeyType: String("ksk"), Active: Bool(true), Published: Bool(true), DNSkey: String(ksk.String())},
+			{ID: Uint64(2), KeyType: String("zsk"), Active: Bool(true), Published: Bool(true), DNSkey: String(zsk.String())},
+		},
+	}
+}
+
+func testMultiSignerDS(t *testing.T, ksk *DNSKEY) string {
+	t.Helper()
+	ds, err := ksk.DS("example.com.", DigestSHA256)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return ds.String()
+}
+
+func assertTestContents(t *testing.T, zone *testMultiSignerZone, recordType RRType, expected ...string) {
+	t.Helper()
+	missing, unexpected := diffContents(expected, rrsetContents(findRRSet(zone.rrsets, "example.com.", recordType)), func(s string) string { return s })
+	if len(missing) > 0 || len(unexpected) > 0 {
+		t.Errorf("Invalid %s RRset: missing %v, unexpected %v", recordType, missing, unexpected)
+	}
+}
+
+func newTestMultiSigner(t *testing.T) (*MultiSigner, [2]*testMultiSignerZone, [2]*DNSKEY, [2]*DNSKEY, *fakeClock) {
+	t.Helper()
+	var ksks, zsks [2]*DNSKEY
+	ksks[0], zsks[0] = generateTestMultiSignerKeys(t)
+	ksks[1], zsks[1] = generateTestMultiSignerKeys(t)
+	zones := [2]*testMultiSignerZone{
+		generateTestMultiSignerZone(ksks[0], zsks[0], "ns1.a.example."),
+		generateTestMultiSignerZone(ksks[1], zsks[1], "ns1.b.example."),
+	}
+	registerMultiSignerMockResponder("signer-a", "example.com.", zones[0])
+	registerMultiSignerMockResponder("signer-b", "example.com.", zones[1])
+
+	headers := map[string]string{"X-API-Key": testAPIKey}
+	multiSigner := NewMultiSigner("example.com", NewClient(testBaseURL, "signer-a", headers, nil), NewClient(testBaseURL, "signer-b", headers, nil))
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	multiSigner.Clock = clock
+	return multiSigner, zones, ksks, zsks, clock
+}
+
+func TestMultiSignerAddSigner(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	multiSigner, zones, ksks, zsks, clock := newTestMultiSigner(t)
+	parentDS := []string{testMultiSignerDS(t, ksks[0])}
+	parentNS := []string{"ns1.a.example."}
+	multiSigner.ParentDS = func(ctx context.Context, zone string) ([]string, error) { return parentDS, nil }
+	multiSigner.ParentNS = func(ctx context.Context, zone string) ([]string, error) { return parentNS, nil }
+	ctx := context.Background()
+
+	state, err := multiSigner.StartAddSigner(ctx, 1)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if state.Phase != MultiSignerPhaseDNSKEYPublished || !state.NextActionAt.Equal(clock.now.Add(time.Hour)) {
+		t.Fatalf("Invalid state: %+v", state)
+	}
+	assertTestContents(t, zones[0], RRTypeDNSKEY, zsks[1].String())
+	assertTestContents(t, zones[0], RRTypeCDS, testMultiSignerDS(t, ksks[0]))
+	assertTestContents(t, zones[0], RRTypeNS, "ns1.a.example.")
+	assertTestContents(t, zones[1], RRTypeDNSKEY, zsks[0].String())
+	assertTestContents(t, zones[1], RRTypeCDNSKEY, ksks[0].String(), ksks[1].String())
+
+	// Nothing happens until the DNSKEY TTL has expired
+	if err := multiSigner.Step(ctx, state); err != nil || state.Phase != MultiSignerPhaseDNSKEYPublished {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+
+	clock.Advance(time.Hour)
+	if err := multiSigner.Step(ctx, state); err != nil || state.Phase != MultiSignerPhaseWaitingForDS {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+	assertTestContents(t, zones[0], RRTypeCDS, testMultiSignerDS(t, ksks[0]), testMultiSignerDS(t, ksks[1]))
+
+	parentDS = append(parentDS, testMultiSignerDS(t, ksks[1]))
+	clock.Advance(time.Hour)
+	if err := multiSigner.Step(ctx, state); err != nil || state.Phase != MultiSignerPhaseDSPublished {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+
+	clock.Advance(time.Hour)
+	if err := multiSigner.Step(ctx, state); err != nil || state.Phase != MultiSignerPhaseWaitingForNS {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+	assertTestContents(t, zones[0], RRTypeNS, "ns1.a.example.", "ns1.b.example.")
+
+	parentNS = append(parentNS, "ns1.b.example.")
+	clock.Advance(time.Hour)
+	if err := multiSigner.Step(ctx, state); err != nil || !state.Done() {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+
+	statuses, err := multiSigner.Status(ctx)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, status := range statuses {
+		if !status.InSync() {
+			t.Errorf("Signer %d is not in sync: %+v", status.Signer, status)
+		}
+	}
+
+	// Another sync does not change anything
+	patches := zones[0].patches + zones[1].patches
+	if err := multiSigner.Sync(ctx); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if zones[0].patches+zones[1].patches != patches {
+		t.Error("Unexpected changes")
+	}
+}
+
+func TestMultiSignerRemoveSigner(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	multiSigner, zones, ksks, zsks, clock := newTestMultiSigner(t)
+	multiSigner.Nameservers = [2][]string{{"ns1.a.example."}, {"ns1.b.example."}}
+	parentDS := []string{testMultiSignerDS(t, ksks[0]), testMultiSignerDS(t, ksks[1])}
+	multiSigner.ParentDS = func(ctx context.Context, zone string) ([]string, error) { return parentDS, nil }
+	ctx := context.Background()
+
+	statuses, err := multiSigner.Status(ctx)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if statuses[0].InSync() || len(statuses[0].MissingDNSKEY) != 1 || len(statuses[0].MissingNS) != 1 {
+		t.Errorf("Invalid status: %+v", statuses[0])
+	}
+	if err := multiSigner.Sync(ctx); err != nil {
+		t.Fatalf("%s", err)
+	}
+	assertTestContents(t, zones[0], RRTypeDNSKEY, zsks[1].String())
+	assertTestContents(t, zones[1], RRTypeNS, "ns1.a.example.", "ns1.b.example.")
+
+	state, err := multiSigner.StartRemoveSigner(ctx, 1)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if state.Phase != MultiSignerPhaseNSWithdrawn {
+		t.Fatalf("Invalid state: %+v", state)
+	}
+	assertTestContents(t, zones[0], RRTypeNS, "ns1.a.example.")
+	assertTestContents(t, zones[0], RRTypeCDS, parentDS...)
+
+	clock.Advance(time.Hour)
+	if err := multiSigner.Step(ctx, state); err != nil || state.Phase != MultiSignerPhaseWaitingForDSRemoval {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+	assertTestContents(t, zones[0], RRTypeCDS, testMultiSignerDS(t, ksks[0]))
+	assertTestContents(t, zones[0], RRTypeCDNSKEY, ksks[0].String())
+
+	parentDS = parentDS[:1]
+	clock.Advance(time.Hour)
+	if err := multiSigner.Step(ctx, state); err != nil || state.Phase != MultiSignerPhaseDSWithdrawn {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+
+	clock.Advance(time.Hour)
+	if err := multiSigner.Step(ctx, state); err != nil || !state.Done() {
+		t.Fatalf("Unexpected step: %+v, %v", state, err)
+	}
+	if findRRSet(zones[0].rrsets, "example.com.", RRTypeDNSKEY) != nil {
+		t.Error("Foreign DNSKEY records have not been removed")
+	}
+}
+
+func TestMultiSignerInvalid(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	multiSigner, zones, _, _, _ := newTestMultiSigner(t)
+	ctx := context.Background()
+
+	if _, err := multiSigner.StartAddSigner(ctx, 1); err == nil {
+		t.Error("Expected error without ParentDS check")
+	}
+	multiSigner.ParentDS = func(ctx context.Context, zone string) ([]string, error) { return nil, nil }
+	if _, err := multiSigner.StartAddSigner(ctx, 2); err == nil {
+		t.Error("Expected error for invalid signer")
+	}
+	if _, err := multiSigner.StartRemoveSigner(ctx, 1); err == nil {
+		t.Error("Expected error for unknown name servers")
+	}
+
+	zones[1].cryptokeys = zones[1].cryptokeys[:1]
+	if _, err := multiSigner.StartAddSigner(ctx, 1); err == nil {
+		t.Error("Expected error for missing ZSK")
+	}
+	if zones[0].patches+zones[1].patches != 0 {
+		t.Error("Unexpected changes")
+	}
+
+	if err := multiSigner.Step(ctx, &MultiSignerState{Zone: "example.net.", Phase: MultiSignerPhaseDSPublished}); err == nil {
+		t.Error("Expected error for different zone")
+	}
+}
@@ -0,0 +1,105 @@
+package powerdns
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NSEC3HashSHA1 is the only NSEC3 hash algorithm defined (RFC 5155)
+const NSEC3HashSHA1 uint8 = 1
+
+// NSEC3FlagOptOut marks NSEC3 records which may cover unsigned delegations (RFC 5155, section 3.1.2.1)
+const NSEC3FlagOptOut uint8 = 0x01
+
+// nsec3MaxSaltLength is the maximum salt length recommended by RFC 9276, section 3.1
+const nsec3MaxSaltLength = 8
+
+// NSEC3Param contains the parameters of NSEC3 records (RFC 5155, section 4)
+type NSEC3Param struct {
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+}
+
+// DefaultNSEC3Param returns the parameters recommended by RFC 9276: SHA-1, no opt-out, no additional iterations and an empty salt
+func DefaultNSEC3Param() *NSEC3Param {
+	return &NSEC3Param{HashAlgorithm: NSEC3HashSHA1}
+}
+
+// ParseNSEC3Param parses the presentation format of NSEC3PARAM content like "1 0 0 -"
+func ParseNSEC3Param(content string) (*NSEC3Param, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid NSEC3PARAM %q", content)
+	}
+
+	algorithm, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSEC3 hash algorithm %q", fields[0])
+	}
+	flags, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSEC3 flags %q", fields[1])
+	}
+	iterations, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NSEC3 iterations %q", fields[2])
+	}
+
+	var salt []byte
+	if fields[3] != "-" {
+		if salt, err = hex.DecodeString(fields[3]); err != nil {
+			return nil, fmt.Errorf("invalid NSEC3 salt %q", fields[3])
+		}
+		if len(salt) > 255 {
+			return nil, fmt.Errorf("NSEC3 salt exceeds 255 octets")
+		}
+	}
+
+	return &NSEC3Param{HashAlgorithm: uint8(algorithm), Flags: uint8(flags), Iterations: uint16(iterations), Salt: salt}, nil
+}
+
+// String returns the presentation format of NSEC3 parameters, as expected by PowerDNS
+func (p *NSEC3Param) String() string {
+	salt := "-"
+	if len(p.Salt) > 0 {
+		salt = hex.EncodeToString(p.Salt)
+	}
+	return fmt.Sprintf("%d %d %d %s", p.HashAlgorithm, p.Flags, p.Iterations, salt)
+}
+
+// OptOut reports whether the opt-out flag is set
+func (p *NSEC3Param) OptOut() bool {
+	return p.Flags&NSEC3FlagOptOut != 0
+}
+
+// Warnings lists the deviations from the recommendations of RFC 9276
+func (p *NSEC3Param) Warnings() []string {
+	warnings := make([]string, 0)
+	if p.HashAlgorithm != NSEC3HashSHA1 {
+		warnings = append(warnings, fmt.Sprintf("hash algorithm %d is unknown to validators", p.HashAlgorithm))
+	}
+	if p.Iterations != 0 {
+		warnings = append(warnings, fmt.Sprintf("%d additional iterations instead of 0 (RFC 9276, section 3.1)", p.Iterations))
+	}
+	if len(p.Salt) > nsec3MaxSaltLength {
+		warnings = append(warnings, fmt.Sprintf("salt of %d octets is longer than %d octets (RFC 9276, section 3.1)", len(p.Salt), nsec3MaxSaltLength))
+	} else if len(p.Salt) > 0 {
+		warnings = append(warnings, "salt should be empty (RFC 9276, section 3.1)")
+	}
+	if p.OptOut() {
+		warnings = append(warnings, "opt-out should only be used for very large, sparsely signed zones (RFC 9276, section 3.1)")
+	}
+	return warnings
+}
+
+// ParsedNSEC3Param returns the parsed NSEC3 parameters of a Zone, or nil if the Zone uses NSEC
+func (z *Zone) ParsedNSEC3Param() (*NSEC3Param, error) {
+	if z.Nsec3Param == nil || *z.Nsec3Param == "" {
+		return nil, nil
+	}
+	return ParseNSEC3Param(*z.Nsec3Param)
+}
@@ -0,0 +1,58 @@
+package powerdns
+
+import (
+	"testing"
+)
+
+func TestParseNSEC3Param(t *testing.T) {
+	testCases := []struct {
+		content  string
+		param    string
+		warnings int
+		wantErr  bool
+	}{
+		{"1 0 0 -", "1 0 0 -", 0, false},
+		{"1 1 0 -", "1 1 0 -", 1, false},
+		{"1 0 10 AABBCCDD", "1 0 10 aabbccdd", 2, false},
+		{"1 0 0 00112233445566778899", "1 0 0 00112233445566778899", 1, false},
+		{"2 0 0 -", "2 0 0 -", 1, false},
+		{"1 0 0", "", 0, true},
+		{"x 0 0 -", "", 0, true},
+		{"1 x 0 -", "", 0, true},
+		{"1 0 70000 -", "", 0, true},
+		{"1 0 0 xyz", "", 0, true},
+	}
+
+	for _, tc := range testCases {
+		param, err := ParseNSEC3Param(tc.content)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: unexpected error %v", tc.content, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if param.String() != tc.param {
+			t.Errorf("%q: got %q, want %q", tc.content, param, tc.param)
+		}
+		if warnings := param.Warnings(); len(warnings) != tc.warnings {
+			t.Errorf("%q: got warnings %v", tc.content, warnings)
+		}
+	}
+}
+
+func TestDefaultNSEC3Param(t *testing.T) {
+	param := DefaultNSEC3Param()
+	if param.String() != "1 0 0 -" || len(param.Warnings()) != 0 || param.OptOut() {
+		t.Errorf("Invalid default NSEC3 parameters %s", param)
+	}
+}
+
+func TestZoneParsedNSEC3Param(t *testing.T) {
+	if param, err := (&Zone{Nsec3Param: String("")}).ParsedNSEC3Param(); param != nil || err != nil {
+		t.Errorf("Unexpected NSEC3 parameters %v, %v", param, err)
+	}
+	if param, err := (&Zone{Nsec3Param: String("1 0 0 ab")}).ParsedNSEC3Param(); err != nil || len(param.Salt) != 1 {
+		t.Errorf("Invalid NSEC3 parameters %v, %v", param, err)
+	}
+}
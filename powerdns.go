@@ -25,16 +25,31 @@ type Client struct {
 	VHost    string
 	Headers  map[string]string
 
+	// RelativeNames enables names relative to the zone in RecordsService: "@" refers to the zone apex,
+	// and names without a trailing dot are appended to the zone name.
+	RelativeNames bool
+
+	// UnicodeResponses converts internationalized domain names in zones received from the server to their Unicode form.
+	// Names sent to the server are always converted to their ASCII form.
+	UnicodeResponses bool
+
 	httpClient *http.Client
 
 	common service // Reuse a single struct instead of allocating one for each service on the heap
 
 	Config     *ConfigService
 	Cryptokeys *CryptokeysService
+	Metadata   *MetadataService
 	Records    *RecordsService
+	Search     *SearchService
 	Servers    *ServersService
 	Statistics *StatisticsService
+	TSIGKeys   *TSIGKeysService
 	Zones      *ZonesService
+
+	ptrManager        *PTRManager
+	delegationManager *DelegationManager
+	zoneIndex         *zoneIndex
 }
 
 // logFatalf makes log.Fatalf testable
@@ -54,6 +69,7 @@ func NewClient(baseURL string, vHost string, headers map[string]string, httpClie
 		VHost:      parseVHost(vHost),
 		Headers:    headers,
 		httpClient: httpClient,
+		zoneIndex:  newZoneIndex(),
 	}
 
 	if c.httpClient == nil {
@@ -64,9 +80,12 @@ func NewClient(baseURL string, vHost string, headers map[string]string, httpClie
 
 	c.Config = (*ConfigService)(&c.common)
 	c.Cryptokeys = (*CryptokeysService)(&c.common)
+	c.Metadata = (*MetadataService)(&c.common)
 	c.Records = (*RecordsService)(&c.common)
+	c.Search = (*SearchService)(&c.common)
 	c.Servers = (*ServersService)(&c.common)
 	c.Statistics = (*StatisticsService)(&c.common)
+	c.TSIGKeys = (*TSIGKeysService)(&c.common)
 	c.Zones = (*ZonesService)(&c.common)
 
 	return c
@@ -121,6 +140,26 @@ func makeDomainCanonical(domain string) string {
 	return fmt.Sprintf("%s.", trimDomain(domain))
 }
 
+// escapeZoneID converts a domain into a zone ID which is safe to use in the URL path, following the escaping rules of PowerDNS
+func escapeZoneID(domain string) string {
+	domain = trimDomain(idnToASCIIOrRaw(domain))
+	if domain == "" {
+		return "=2E"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(domain); i++ {
+		c := domain[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "=%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
 func (p *Client) newRequest(ctx context.Context, method string, path string, query *url.Values, body interface{}) (*http.Request, error) {
 	var buf io.ReadWriter
 	if body != nil {
@@ -63,7 +63,7 @@ func registerDoMockResponder() {
 
 func TestNewClient(t *testing.T) {
 	t.Run("TestValidURL", func(t *testing.T) {
-		tmpl := &Client{"http", "localhost", "8080", "localhost", map[string]string{"X-API-Key": "apipw"}, http.DefaultClient, service{}, nil, nil, nil, nil, nil, nil}
+		tmpl := &Client{Scheme: "http", Hostname: "localhost", Port: "8080", VHost: "localhost", Headers: map[string]string{"X-API-Key": "apipw"}, httpClient: http.DefaultClient}
 		p := NewClient("http://localhost:8080", "localhost", map[string]string{"X-API-Key": "apipw"}, http.DefaultClient)
 		if p.Hostname != tmpl.Hostname {
 			t.Error("NewClient returns invalid Client object")
@@ -78,7 +78,7 @@ func TestNewClient(t *testing.T) {
 		var errors []string
 		logFatalf = func(format string, args ...interface{}) {
 			if len(args) > 0 {
-				errors = append(errors, fmt.Sprintf(format, args))
+				errors = append(errors, fmt.Sprintf(format, args...))
 			} else {
 				errors = append(errors, format)
 			}
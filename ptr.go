@@ -0,0 +1,362 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PTRConflictPolicy defines how PTR records are handled which point to a different name
+type PTRConflictPolicy string
+
+const (
+	// PTRConflictSkip leaves PTR records of other owners untouched
+	PTRConflictSkip PTRConflictPolicy = "skip"
+	// PTRConflictOverwrite replaces PTR records of other owners
+	PTRConflictOverwrite PTRConflictPolicy = "overwrite"
+	// PTRConflictAppend adds the name to PTR records of other owners
+	PTRConflictAppend PTRConflictPolicy = "append"
+	// PTRConflictFail aborts the change with a PTRConflictError before any zone is patched
+	PTRConflictFail PTRConflictPolicy = "fail"
+)
+
+// PTRConflictError is returned if a PTR record is held by another owner and PTRConflictFail is set
+type PTRConflictError struct {
+	PTRName string
+	Name    string
+	Owners  []string
+}
+
+func (e PTRConflictError) Error() string {
+	return fmt.Sprintf("PTR %s is held by %s, cannot point it to %s", e.PTRName, strings.Join(e.Owners, ", "), e.Name)
+}
+
+// PTRManager keeps PTR records in sync with A and AAAA records which are changed through the RecordsService
+type PTRManager struct {
+	client         *Client
+	ConflictPolicy PTRConflictPolicy
+}
+
+// EnablePTRManagement enables the client-side PTR management for RecordsService
+func (p *Client) EnablePTRManagement(conflictPolicy PTRConflictPolicy) *PTRManager {
+	p.ptrManager = &PTRManager{client: p, ConflictPolicy: conflictPolicy}
+	return p.ptrManager
+}
+
+// DisablePTRManagement disables the client-side PTR management for RecordsService
+func (p *Client) DisablePTRManagement() {
+	p.ptrManager = nil
+}
+
+// PTRName returns the in-addr.arpa or ip6.arpa name of an IP address
+func PTRName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	labels := make([]string, 0, 32)
+
+	if addr.Is4() {
+		for _, b := range addr.As4() {
+			labels = append(labels, strconv.Itoa(int(b)))
+		}
+		reverseLabels(labels)
+		return strings.Join(labels, ".") + ".in-addr.arpa."
+	}
+
+	for _, b := range addr.As16() {
+		labels = append(labels, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0x0f), 16))
+	}
+	reverseLabels(labels)
+	return strings.Join(labels, ".") + ".ip6.arpa."
+}
+
+func reverseLabels(labels []string) {
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+}
+
+func isAddressRRType(recordType RRType) bool {
+	return recordType == RRTypeA || recordType == RRTypeAAAA
+}
+
+func addressesOfRRSet(rrset *RRset) []string {
+	if rrset == nil {
+		return nil
+	}
+
+	addresses := make([]string, 0, len(rrset.Records))
+	for _, record := range rrset.Records {
+		addresses = append(addresses, StringValue(record.Content))
+	}
+	return addresses
+}
+
+// ptrChange describes the forward addresses of a name before and after a change
+type ptrChange struct {
+	name   string
+	ttl    uint32
+	before []string
+	after  []string
+}
+
+// collectPTRChanges retrieves the current state of all A and AAAA RRsets which are going to be patched
+func (m *PTRManager) collectPTRChanges(ctx context.Context, domain string, rrSets []RRset) ([]ptrChange, error) {
+	var zone *Zone
+	changes := make([]ptrChange, 0)
+
+	for _, rrset := range rrSets {
+		if rrset.Type == nil || !isAddressRRType(*rrset.Type) || rrset.ChangeType == nil {
+			continue
+		}
+
+		if zone == nil {
+			var err error
+			zone, err = m.client.Zones.get(ctx, domain)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		change := ptrChange{name: makeDomainCanonical(StringValue(rrset.Name)), ttl: Uint32Value(rrset.TTL)}
+		if existing := findRRSet(zone.RRsets, change.name, *rrset.Type); existing != nil {
+			change.before = addressesOfRRSet(existing)
+			if change.ttl == 0 {
+				change.ttl = Uint32Value(existing.TTL)
+			}
+		}
+
+		if *rrset.ChangeType == ChangeTypeReplace {
+			if rrset.Records == nil {
+				continue
+			}
+			change.after = addressesOfRRSet(&rrset)
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// reverseZoneState caches the reverse zone contents and pending changes during a single synchronisation
+type reverseZoneState struct {
+	zone    *Zone
+	pending map[string]RRset
+}
+
+func (s *reverseZoneState) ptrRRSet(name string) *RRset {
+	if rrset, ok := s.pending[name]; ok {
+		return &rrset
+	}
+	return findRRSet(s.zone.RRsets, name, RRTypePTR)
+}
+
+// ptrPatch contains the PTR changes of a single reverse zone
+type ptrPatch struct {
+	zone   string
+	rrSets *RRsets
+}
+
+// plan computes the PTR changes for the given forward changes without sending them.
+// Conflicts are reported here, so that PTRConflictFail aborts before the forward zone is changed.
+func (m *PTRManager) plan(ctx context.Context, changes []ptrChange) ([]ptrPatch, error) {
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	states := make(map[string]*reverseZoneState)
+	order := make([]string, 0)
+	stateFor := func(ptrName string) (*reverseZoneState, error) {
+		zone, err := m.client.Zones.findEnclosing(ctx, ptrName)
+		if isNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		zoneName := StringValue(zone.Name)
+		if state, ok := states[zoneName]; ok {
+			return state, nil
+		}
+
+		fullZone, err := m.client.Zones.get(ctx, zoneName)
+		if err != nil {
+			return nil, err
+		}
+
+		states[zoneName] = &reverseZoneState{zone: fullZone, pending: make(map[string]RRset)}
+		order = append(order, zoneName)
+		return states[zoneName], nil
+	}
+
+	for _, change := range changes {
+		after := make(map[string]bool, len(change.after))
+		for _, address := range change.after {
+			after[address] = true
+		}
+
+		for _, address := range change.before {
+			if after[address] {
+				continue
+			}
+			if err := m.removePTR(change, address, stateFor); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, address := range change.after {
+			if err := m.addPTR(change, address, stateFor); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	patches := make([]ptrPatch, 0, len(order))
+	for _, zoneName := range order {
+		state := states[zoneName]
+		if len(state.pending) == 0 {
+			continue
+		}
+
+		payload := &RRsets{}
+		for _, rrset := range state.pending {
+			payload.Sets = append(payload.Sets, rrset)
+		}
+		sort.Slice(payload.Sets, func(i, j int) bool {
+			return *payload.Sets[i].Name < *payload.Sets[j].Name
+		})
+		patches = append(patches, ptrPatch{zone: zoneName, rrSets: payload})
+	}
+
+	return patches, nil
+}
+
+// apply sends the PTR changes computed by plan
+func (m *PTRManager) apply(ctx context.Context, patches []ptrPatch) error {
+	for _, patch := range patches {
+		if err := m.client.Records.sendRRSets(ctx, patch.zone, patch.rrSets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvePTRName follows an RFC 2317 CNAME from the parent reverse zone into the classless child zone
+func resolvePTRName(ptrName string, stateFor func(string) (*reverseZoneState, error)) (string, *reverseZoneState, error) {
+	state, err := stateFor(ptrName)
+	if err != nil || state == nil {
+		return ptrName, state, err
+	}
+
+	cname := findRRSet(state.zone.RRsets, ptrName, RRTypeCNAME)
+	if cname == nil || len(cname.Records) == 0 {
+		return ptrName, state, nil
+	}
+
+	target := makeDomainCanonical(StringValue(cname.Records[0].Content))
+	targetState, err := stateFor(target)
+	return target, targetState, err
+}
+
+func (m *PTRManager) removePTR(change ptrChange, address string, stateFor func(string) (*reverseZoneState, error)) error {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil
+	}
+
+	ptrName, state, err := resolvePTRName(PTRName(addr), stateFor)
+	if err != nil || state == nil {
+		return err
+	}
+
+	existing := state.ptrRRSet(ptrName)
+	if existing == nil {
+		return nil
+	}
+
+	records := make([]Record, 0, len(existing.Records))
+	for _, record := range existing.Records {
+		if !strings.EqualFold(makeDomainCanonical(StringValue(record.Content)), change.name) {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == len(existing.Records) {
+		return nil
+	}
+
+	if len(records) == 0 {
+		state.pending[ptrName] = RRset{
+			Name:       String(ptrName),
+			Type:       RRTypePtr(RRTypePTR),
+			ChangeType: ChangeTypePtr(ChangeTypeDelete),
+		}
+		return nil
+	}
+
+	state.pending[ptrName] = RRset{
+		Name:       String(ptrName),
+		Type:       RRTypePtr(RRTypePTR),
+		TTL:        existing.TTL,
+		ChangeType: ChangeTypePtr(ChangeTypeReplace),
+		Records:    records,
+	}
+	return nil
+}
+
+func (m *PTRManager) addPTR(change ptrChange, address string, stateFor func(string) (*reverseZoneState, error)) error {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil
+	}
+
+	ptrName, state, err := resolvePTRName(PTRName(addr), stateFor)
+	if err != nil || state == nil {
+		return err
+	}
+
+	own := Record{Content: String(change.name), Disabled: Bool(false)}
+	rrset := RRset{
+		Name:       String(ptrName),
+		Type:       RRTypePtr(RRTypePTR),
+		TTL:        Uint32(change.ttl),
+		ChangeType: ChangeTypePtr(ChangeTypeReplace),
+		Records:    []Record{own},
+	}
+
+	existing := state.ptrRRSet(ptrName)
+	if existing == nil || existing.ChangeType != nil && *existing.ChangeType == ChangeTypeDelete {
+		state.pending[ptrName] = rrset
+		return nil
+	}
+
+	owners := make([]string, 0, len(existing.Records))
+	for _, record := range existing.Records {
+		owner := makeDomainCanonical(StringValue(record.Content))
+		if strings.EqualFold(owner, change.name) {
+			return nil
+		}
+		owners = append(owners, owner)
+	}
+
+	if len(owners) == 0 {
+		state.pending[ptrName] = rrset
+		return nil
+	}
+
+	switch m.ConflictPolicy {
+	case PTRConflictOverwrite:
+		state.pending[ptrName] = rrset
+	case PTRConflictAppend:
+		rrset.TTL = existing.TTL
+		rrset.Records = append(append([]Record{}, existing.Records...), own)
+		state.pending[ptrName] = rrset
+	case PTRConflictFail:
+		return &PTRConflictError{PTRName: ptrName, Name: change.name, Owners: owners}
+	}
+
+	return nil
+}
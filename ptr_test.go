@@ -0,0 +1,195 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestPTRName(t *testing.T) {
+	testCases := []struct {
+		addr    string
+		wantPTR string
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa."},
+		{"::ffff:192.0.2.1", "1.2.0.192.in-addr.arpa."},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			if ptr := PTRName(netip.MustParseAddr(tc.addr)); ptr != tc.wantPTR {
+				t.Errorf("%s != %s", ptr, tc.wantPTR)
+			}
+		})
+	}
+}
+
+func TestPTRManagerChange(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{
+		"example.com.": {
+			{Name: String("www.example.com."), Type: RRTypePtr(RRTypeA), TTL: Uint32(300), Records: []Record{{Content: String("192.0.2.1")}, {Content: String("192.0.2.2")}}},
+		},
+		"2.0.192.in-addr.arpa.": {
+			{Name: String("1.2.0.192.in-addr.arpa."), Type: RRTypePtr(RRTypePTR), TTL: Uint32(300), Records: []Record{{Content: String("www.example.com.")}}},
+			{Name: String("2.2.0.192.in-addr.arpa."), Type: RRTypePtr(RRTypePTR), TTL: Uint32(300), Records: []Record{{Content: String("www.example.com.")}, {Content: String("alias.example.com.")}}},
+			{Name: String("3.2.0.192.in-addr.arpa."), Type: RRTypePtr(RRTypePTR), TTL: Uint32(300), Records: []Record{{Content: String("mail.example.com.")}}},
+		},
+	}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	p.EnablePTRManagement(PTRConflictSkip)
+
+	if err := p.Records.Change(context.Background(), "example.com", "www.example.com", RRTypeA, 600, []string{"192.0.2.3", "192.0.2.4"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	reverse := patched["2.0.192.in-addr.arpa."]
+	if len(reverse) != 3 {
+		t.Fatalf("Invalid reverse patch: %+v", reverse)
+	}
+
+	want := map[string]ChangeType{
+		"1.2.0.192.in-addr.arpa.": ChangeTypeDelete,
+		"2.2.0.192.in-addr.arpa.": ChangeTypeReplace,
+		"4.2.0.192.in-addr.arpa.": ChangeTypeReplace,
+	}
+	for _, rrset := range reverse {
+		if want[*rrset.Name] != *rrset.ChangeType {
+			t.Errorf("Unexpected change for %s: %s", *rrset.Name, *rrset.ChangeType)
+		}
+		if *rrset.Name == "2.2.0.192.in-addr.arpa." && (len(rrset.Records) != 1 || *rrset.Records[0].Content != "alias.example.com.") {
+			t.Errorf("Foreign PTR has not been kept: %+v", rrset.Records)
+		}
+		if *rrset.Name == "4.2.0.192.in-addr.arpa." && *rrset.TTL != 600 {
+			t.Errorf("PTR has invalid TTL: %d", *rrset.TTL)
+		}
+	}
+}
+
+func TestPTRManagerConflictPolicies(t *testing.T) {
+	testCases := []struct {
+		policy      PTRConflictPolicy
+		wantRecords int
+		wantErr     bool
+	}{
+		{PTRConflictSkip, -1, false},
+		{PTRConflictOverwrite, 1, false},
+		{PTRConflictAppend, 2, false},
+		{PTRConflictFail, -1, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			zones := map[string][]RRset{
+				"example.com.": {},
+				"2.0.192.in-addr.arpa.": {
+					{Name: String("3.2.0.192.in-addr.arpa."), Type: RRTypePtr(RRTypePTR), TTL: Uint32(300), Records: []Record{{Content: String("mail.example.com.")}}},
+				},
+			}
+			patched := make(map[string][]RRset)
+			registerZonesStateMockResponder(zones, patched)
+
+			p := initialisePowerDNSTestClient()
+			p.EnablePTRManagement(tc.policy)
+
+			err := p.Records.Add(context.Background(), "example.com", "www.example.com", RRTypeA, 300, []string{"192.0.2.3"})
+			var conflictErr *PTRConflictError
+			if tc.wantErr != errors.As(err, &conflictErr) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if forward := patched["example.com."]; tc.wantErr && len(forward) != 0 {
+				t.Errorf("Forward zone has been patched despite the conflict: %+v", forward)
+			}
+
+			reverse := patched["2.0.192.in-addr.arpa."]
+			if tc.wantRecords < 0 {
+				if len(reverse) != 0 {
+					t.Errorf("Unexpected reverse patch: %+v", reverse)
+				}
+				return
+			}
+			if len(reverse) != 1 || len(reverse[0].Records) != tc.wantRecords {
+				t.Errorf("Invalid reverse patch: %+v", reverse)
+			}
+		})
+	}
+}
+
+func TestPTRManagerDelete(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{
+		"example.com.": {
+			{Name: String("www.example.com."), Type: RRTypePtr(RRTypeAAAA), TTL: Uint32(300), Records: []Record{{Content: String("2001:db8::1")}}},
+		},
+		"8.b.d.0.1.0.0.2.ip6.arpa.": {
+			{Name: String(PTRName(netip.MustParseAddr("2001:db8::1"))), Type: RRTypePtr(RRTypePTR), TTL: Uint32(300), Records: []Record{{Content: String("www.example.com.")}}},
+		},
+	}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	p.EnablePTRManagement(PTRConflictSkip)
+
+	if err := p.Records.Delete(context.Background(), "example.com", "www.example.com", RRTypeAAAA); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	reverse := patched["8.b.d.0.1.0.0.2.ip6.arpa."]
+	if len(reverse) != 1 || *reverse[0].ChangeType != ChangeTypeDelete {
+		t.Errorf("Invalid reverse patch: %+v", reverse)
+	}
+
+	p.DisablePTRManagement()
+	if err := p.Records.Delete(context.Background(), "example.com", "www.example.com", RRTypeAAAA); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(patched["8.b.d.0.1.0.0.2.ip6.arpa."]) != 1 {
+		t.Error("PTR records are managed after disabling")
+	}
+}
+
+func TestPTRManagerClassless(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{
+		"example.com.": {},
+		"2.0.192.in-addr.arpa.": {
+			{Name: String("65.2.0.192.in-addr.arpa."), Type: RRTypePtr(RRTypeCNAME), TTL: Uint32(300), Records: []Record{{Content: String("65.64/26.2.0.192.in-addr.arpa.")}}},
+		},
+		"64/26.2.0.192.in-addr.arpa.": {},
+	}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	p.EnablePTRManagement(PTRConflictSkip)
+
+	if err := p.Records.Add(context.Background(), "example.com", "www.example.com", RRTypeA, 300, []string{"192.0.2.65"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched["2.0.192.in-addr.arpa."]) != 0 {
+		t.Errorf("Parent zone has been patched: %+v", patched["2.0.192.in-addr.arpa."])
+	}
+	child := patched["64/26.2.0.192.in-addr.arpa."]
+	if len(child) != 1 || *child[0].Name != "65.64/26.2.0.192.in-addr.arpa." {
+		t.Errorf("Invalid classless patch: %+v", child)
+	}
+}
@@ -3,6 +3,9 @@ package powerdns
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 )
 
 // RecordsService handles communication with the records related methods of the Client API
@@ -15,7 +18,10 @@ type RRset struct {
 	TTL        *uint32     `json:"ttl,omitempty"`
 	ChangeType *ChangeType `json:"changetype,omitempty"`
 	Records    []Record    `json:"records"`
-	Comments   []Comment   `json:"comments,omitempty"`
+
+	// Comments is sent as null if it is nil, which leaves existing comments untouched.
+	// An empty, non-nil slice removes all comments of the RRset.
+	Comments []Comment `json:"comments"`
 }
 
 // Record structure with JSON API metadata
@@ -32,6 +38,18 @@ type Comment struct {
 	ModifiedAt *uint64 `json:"modified_at,omitempty"`
 }
 
+// timeNow makes time.Now testable
+var timeNow = time.Now
+
+// NewComment returns a Comment for a given content and account, stamped with the current time
+func NewComment(content, account string) Comment {
+	return Comment{
+		Content:    String(content),
+		Account:    String(account),
+		ModifiedAt: Uint64(uint64(timeNow().Unix())),
+	}
+}
+
 // RRsets structure with JSON API metadata
 type RRsets struct {
 	Sets []RRset `json:"rrsets,omitempty"`
@@ -161,6 +179,8 @@ const (
 	RRTypeURI RRType = "URI"
 	// RRTypeWKS represents the WKS resource record type
 	RRTypeWKS RRType = "WKS"
+	// RRTypeZONEMD represents the ZONEMD resource record type
+	RRTypeZONEMD RRType = "ZONEMD"
 )
 
 // Add creates a new resource record
@@ -168,8 +188,44 @@ func (r *RecordsService) Add(ctx context.Context, domain string, name string, re
 	return r.Change(ctx, domain, name, recordType, ttl, content)
 }
 
-// Change replaces an existing resource record
+// Change replaces an existing resource record, keeping its comments
 func (r *RecordsService) Change(ctx context.Context, domain string, name string, recordType RRType, ttl uint32, content []string) error {
+	payload, err := r.changePayload(domain, name, recordType, ttl, content)
+	if err != nil {
+		return err
+	}
+	return r.patchRRSet(ctx, domain, payload)
+}
+
+// ChangeKeepingDisabled replaces an existing resource record like Change, but keeps content values disabled which are disabled already.
+// The RRset is read before it is replaced, so records disabled in between are enabled again.
+func (r *RecordsService) ChangeKeepingDisabled(ctx context.Context, domain string, name string, recordType RRType, ttl uint32, content []string) error {
+	existing, err := r.getRRSet(ctx, domain, name, recordType)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	payload, err := r.changePayload(domain, name, recordType, ttl, content)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		disabled := make(map[string]bool, len(existing.Records))
+		for _, record := range existing.Records {
+			if BoolValue(record.Disabled) {
+				disabled[recordContentKey(recordType, StringValue(record.Content))] = true
+			}
+		}
+		for i := range payload.Sets[0].Records {
+			record := &payload.Sets[0].Records[i]
+			record.Disabled = Bool(disabled[recordContentKey(recordType, StringValue(record.Content))])
+		}
+	}
+	return r.patchRRSet(ctx, domain, payload)
+}
+
+func (r *RecordsService) changePayload(domain string, name string, recordType RRType, ttl uint32, content []string) (*RRsets, error) {
 	rrset := new(RRset)
 	rrset.Name = &name
 	rrset.Type = &recordType
@@ -182,8 +238,7 @@ func (r *RecordsService) Change(ctx context.Context, domain string, name string,
 		rrset.Records = append(rrset.Records, r)
 	}
 
-	payload := r.prepareRRSet(rrset)
-	return r.patchRRSet(ctx, domain, payload)
+	return r.prepareRRSet(domain, rrset)
 }
 
 // Delete removes an existing resource record
@@ -193,14 +248,202 @@ func (r *RecordsService) Delete(ctx context.Context, domain string, name string,
 	rrset.Type = &recordType
 	rrset.ChangeType = ChangeTypePtr(ChangeTypeDelete)
 
-	payload := r.prepareRRSet(rrset)
+	payload, err := r.prepareRRSet(domain, rrset)
+	if err != nil {
+		return err
+	}
+	return r.patchRRSet(ctx, domain, payload)
+}
+
+// SetComments replaces all comments of an existing resource record set
+func (r *RecordsService) SetComments(ctx context.Context, domain string, name string, recordType RRType, comments []Comment) error {
+	rrset := new(RRset)
+	rrset.Name = &name
+	rrset.Type = &recordType
+	rrset.ChangeType = ChangeTypePtr(ChangeTypeReplace)
+	rrset.Comments = make([]Comment, 0, len(comments))
+
+	for _, c := range comments {
+		if c.ModifiedAt == nil {
+			c.ModifiedAt = Uint64(uint64(timeNow().Unix()))
+		}
+		rrset.Comments = append(rrset.Comments, c)
+	}
+
+	payload, err := r.prepareRRSet(domain, rrset)
+	if err != nil {
+		return err
+	}
 	return r.patchRRSet(ctx, domain, payload)
 }
 
+// AddComment appends a comment to an existing resource record set, keeping the existing comments
+func (r *RecordsService) AddComment(ctx context.Context, domain string, name string, recordType RRType, comment Comment) error {
+	rrset, err := r.getRRSet(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	comments := append(rrset.Comments, comment)
+	return r.SetComments(ctx, domain, name, recordType, comments)
+}
+
+// ClearComments removes all comments of an existing resource record set
+func (r *RecordsService) ClearComments(ctx context.Context, domain string, name string, recordType RRType) error {
+	return r.SetComments(ctx, domain, name, recordType, []Comment{})
+}
+
+// Disable marks the given content values of an existing resource record set as disabled, keeping the other records
+func (r *RecordsService) Disable(ctx context.Context, domain string, name string, recordType RRType, content []string) error {
+	return r.setDisabled(ctx, domain, name, recordType, content, true)
+}
+
+// Enable marks the given content values of an existing resource record set as enabled, keeping the other records
+func (r *RecordsService) Enable(ctx context.Context, domain string, name string, recordType RRType, content []string) error {
+	return r.setDisabled(ctx, domain, name, recordType, content, false)
+}
+
+// ListDisabled retrieves all resource record sets of a zone which contain disabled records, reduced to those records
+func (r *RecordsService) ListDisabled(ctx context.Context, domain string) ([]RRset, error) {
+	zone, err := (*ZonesService)(r).get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	rrsets := make([]RRset, 0)
+	for _, rrset := range zone.RRsets {
+		records := make([]Record, 0)
+		for _, record := range rrset.Records {
+			if BoolValue(record.Disabled) {
+				records = append(records, record)
+			}
+		}
+
+		if len(records) > 0 {
+			rrset.Records = records
+			rrsets = append(rrsets, rrset)
+		}
+	}
+
+	if r.client.UnicodeResponses {
+		zoneToUnicode(&Zone{RRsets: rrsets})
+	}
+	return rrsets, nil
+}
+
+func (r *RecordsService) setDisabled(ctx context.Context, domain string, name string, recordType RRType, content []string, disabled bool) error {
+	rrset, err := r.getRRSet(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	matched := make(map[string]bool, len(content))
+	for _, c := range content {
+		matched[recordContentKey(recordType, c)] = false
+	}
+
+	for i := range rrset.Records {
+		key := recordContentKey(recordType, StringValue(rrset.Records[i].Content))
+		if _, ok := matched[key]; ok {
+			rrset.Records[i].Disabled = Bool(disabled)
+			matched[key] = true
+		}
+	}
+
+	for _, c := range content {
+		if !matched[recordContentKey(recordType, c)] {
+			return fmt.Errorf("record %q not found in RRset %s %s", c, StringValue(rrset.Name), recordType)
+		}
+	}
+
+	rrset.ChangeType = ChangeTypePtr(ChangeTypeReplace)
+	rrset.Comments = nil
+
+	payload, err := r.prepareRRSet(domain, rrset)
+	if err != nil {
+		return err
+	}
+	return r.patchRRSet(ctx, domain, payload)
+}
+
+// recordContentKey identifies a record by its canonical RDATA, so that spellings like "host" and "host." match.
+// Content which cannot be converted is compared literally.
+func recordContentKey(recordType RRType, content string) string {
+	if rdata, err := PackCanonicalRDATA(recordType, content); err == nil {
+		return string(rdata)
+	}
+	return content
+}
+
+// AddByFQDN creates a new resource record in the zone which encloses the name
+func (r *RecordsService) AddByFQDN(ctx context.Context, name string, recordType RRType, ttl uint32, content []string) error {
+	return r.ChangeByFQDN(ctx, name, recordType, ttl, content)
+}
+
+// ChangeByFQDN replaces an existing resource record in the zone which encloses the name
+func (r *RecordsService) ChangeByFQDN(ctx context.Context, name string, recordType RRType, ttl uint32, content []string) error {
+	domain, err := r.enclosingDomain(ctx, name)
+	if err != nil {
+		return err
+	}
+	return r.Change(ctx, domain, makeDomainCanonical(name), recordType, ttl, content)
+}
+
+// DeleteByFQDN removes an existing resource record from the zone which encloses the name
+func (r *RecordsService) DeleteByFQDN(ctx context.Context, name string, recordType RRType) error {
+	domain, err := r.enclosingDomain(ctx, name)
+	if err != nil {
+		return err
+	}
+	return r.Delete(ctx, domain, makeDomainCanonical(name), recordType)
+}
+
+// DisableByFQDN disables records in the zone which encloses the name, see Disable
+func (r *RecordsService) DisableByFQDN(ctx context.Context, name string, recordType RRType, content []string) error {
+	domain, err := r.enclosingDomain(ctx, name)
+	if err != nil {
+		return err
+	}
+	return r.Disable(ctx, domain, makeDomainCanonical(name), recordType, content)
+}
+
+// EnableByFQDN enables records in the zone which encloses the name, see Enable
+func (r *RecordsService) EnableByFQDN(ctx context.Context, name string, recordType RRType, content []string) error {
+	domain, err := r.enclosingDomain(ctx, name)
+	if err != nil {
+		return err
+	}
+	return r.Enable(ctx, domain, makeDomainCanonical(name), recordType, content)
+}
+
+// enclosingDomain returns the zone which encloses a name, which is always treated as absolute by the *ByFQDN methods
+func (r *RecordsService) enclosingDomain(ctx context.Context, name string) (string, error) {
+	zone, err := r.client.Zones.findEnclosing(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return StringValue(zone.Name), nil
+}
+
 // Patch method makes patch of already prepared rrsets
 func (r *RecordsService) Patch(ctx context.Context, domain string, rrSets *RRsets) error {
 	for i := range rrSets.Sets {
-		fixRRSet(&rrSets.Sets[i])
+		if r.client.RelativeNames {
+			name, err := r.qualifyName(domain, StringValue(rrSets.Sets[i].Name))
+			if err != nil {
+				return err
+			}
+			rrSets.Sets[i].Name = String(name)
+		} else if rrSets.Sets[i].Name != nil {
+			name, err := IDNToASCII(*rrSets.Sets[i].Name)
+			if err != nil {
+				return err
+			}
+			rrSets.Sets[i].Name = String(name)
+		}
+		if err := fixRRSetContent(&rrSets.Sets[i]); err != nil {
+			return err
+		}
 	}
 	return r.patchRRSet(ctx, domain, rrSets)
 }
@@ -218,19 +461,135 @@ func fixRRSet(rrset *RRset) {
 	canonicalResourceRecordValues(rrset.Records)
 }
 
-func (r *RecordsService) prepareRRSet(rrSet *RRset) *RRsets {
-	rrSet.Name = String(makeDomainCanonical(*rrSet.Name))
+// fixRRSetContent makes names within the content canonical and converts them to their ASCII form
+func fixRRSetContent(rrset *RRset) error {
+	fixRRSet(rrset)
 
-	fixRRSet(rrSet)
+	for i := range rrset.Records {
+		if rrset.Records[i].Content == nil {
+			continue
+		}
+
+		content, err := convertContentNames(*rrset.Type, *rrset.Records[i].Content, IDNToASCII)
+		if err != nil {
+			return err
+		}
+		rrset.Records[i].Content = String(content)
+	}
+
+	return nil
+}
+
+func (r *RecordsService) prepareRRSet(domain string, rrSet *RRset) (*RRsets, error) {
+	name, err := r.qualifyName(domain, *rrSet.Name)
+	if err != nil {
+		return nil, err
+	}
+	rrSet.Name = String(name)
+
+	if err := fixRRSetContent(rrSet); err != nil {
+		return nil, err
+	}
 
 	payload := RRsets{}
 	payload.Sets = append(payload.Sets, *rrSet)
-	return &payload
+	return &payload, nil
+}
+
+// qualifyName returns the canonical name of a record.
+// If RelativeNames is enabled, "@" refers to the zone apex, names without a trailing dot are relative to the zone,
+// and absolute names outside of the zone are rejected.
+func (r *RecordsService) qualifyName(domain string, name string) (string, error) {
+	if !r.client.RelativeNames {
+		return IDNToASCII(makeDomainCanonical(name))
+	}
+
+	zone := makeDomainCanonical(domain)
+	switch {
+	case name == "@" || name == "":
+		return IDNToASCII(zone)
+	case !strings.HasSuffix(name, "."):
+		return IDNToASCII(name + "." + zone)
+	case isSubdomain(name, zone):
+		return IDNToASCII(name)
+	}
+
+	return "", fmt.Errorf("%w: %s is not part of zone %s", ErrNameOutsideZone, name, zone)
+}
+
+// isSubdomain reports whether a canonical name equals or is below a canonical zone name
+func isSubdomain(name string, zone string) bool {
+	name = strings.ToLower(name)
+	zone = strings.ToLower(zone)
+	return zone == "." || name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+func findRRSet(rrsets []RRset, name string, recordType RRType) *RRset {
+	for i := range rrsets {
+		rrset := &rrsets[i]
+		if strings.EqualFold(StringValue(rrset.Name), name) && rrset.Type != nil && *rrset.Type == recordType {
+			return rrset
+		}
+	}
+	return nil
+}
+
+func (r *RecordsService) getRRSet(ctx context.Context, domain string, name string, recordType RRType) (*RRset, error) {
+	name, err := r.qualifyName(domain, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers which do not support filtering return all RRsets
+	query := url.Values{}
+	query.Set("rrset_name", name)
+	query.Set("rrset_type", string(recordType))
+	req, err := r.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s", r.client.VHost, escapeZoneID(domain)), &query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	zone := &Zone{}
+	if _, err := r.client.do(req, &zone); err != nil {
+		return nil, err
+	}
+
+	if rrset := findRRSet(zone.RRsets, name, recordType); rrset != nil {
+		return rrset, nil
+	}
+
+	return nil, newNotFoundError(fmt.Sprintf("RRset %s %s not found in zone %s", name, recordType, makeDomainCanonical(domain)))
 }
 
 func (r *RecordsService) patchRRSet(ctx context.Context, domain string, rrSets *RRsets) error {
+	ptrManager := r.client.ptrManager
+	if ptrManager == nil {
+		return r.sendRRSets(ctx, domain, rrSets)
+	}
+
+	changes, err := ptrManager.collectPTRChanges(ctx, domain, rrSets.Sets)
+	if err != nil {
+		return err
+	}
+
+	patches, err := ptrManager.plan(ctx, changes)
+	if err != nil {
+		return err
+	}
+
+	if err := r.sendRRSets(ctx, domain, rrSets); err != nil {
+		return err
+	}
+
+	if err := ptrManager.apply(ctx, patches); err != nil {
+		return fmt.Errorf("records of zone %s have been changed, but PTR records could not be synchronised: %w", makeDomainCanonical(domain), err)
+	}
+	return nil
+}
+
+func (r *RecordsService) sendRRSets(ctx context.Context, domain string, rrSets *RRsets) error {
 
-	req, err := r.client.newRequest(ctx, "PATCH", fmt.Sprintf("servers/%s/zones/%s", r.client.VHost, trimDomain(domain)), nil, &rrSets)
+	req, err := r.client.newRequest(ctx, "PATCH", fmt.Sprintf("servers/%s/zones/%s", r.client.VHost, escapeZoneID(domain)), nil, &rrSets)
 	if err != nil {
 		return err
 	}
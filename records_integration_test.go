@@ -42,3 +42,12 @@ func ExampleRecordsService_Change() {
 		log.Fatalf("%v", err)
 	}
 }
+
+func ExampleRecordsService_AddTXT() {
+	pdns := powerdns.NewClient("http://localhost:8080", "localhost", map[string]string{"X-API-Key": "apipw"}, nil)
+	ctx := context.Background()
+
+	if err := pdns.Records.AddTXT(ctx, "example.com.", "www.example.com.", 1337, []string{"v=spf1 -all"}); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
@@ -3,12 +3,14 @@ package powerdns
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -258,3 +260,565 @@ func TestPatchRRSets(t *testing.T) {
 		t.Errorf("%s", err)
 	}
 }
+
+func registerRRSetMockResponder(testDomain string, rrsets []RRset, patched *[]RRset) {
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			zoneMock := Zone{
+				ID:     String(makeDomainCanonical(testDomain)),
+				Name:   String(makeDomainCanonical(testDomain)),
+				Kind:   ZoneKindPtr(NativeZoneKind),
+				RRsets: rrsets,
+			}
+			if name := req.URL.Query().Get("rrset_name"); name != "" {
+				zoneMock.RRsets = make([]RRset, 0)
+				if rrset := findRRSet(rrsets, name, RRType(req.URL.Query().Get("rrset_type"))); rrset != nil {
+					zoneMock.RRsets = append(zoneMock.RRsets, *rrset)
+				}
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, zoneMock)
+		},
+	)
+
+	httpmock.RegisterResponder("PATCH", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var payload RRsets
+			if json.NewDecoder(req.Body).Decode(&payload) != nil {
+				log.Print("Cannot decode request body")
+				return httpmock.NewBytesResponse(http.StatusBadRequest, []byte{}), nil
+			}
+
+			*patched = append(*patched, payload.Sets...)
+			return httpmock.NewBytesResponse(http.StatusNoContent, []byte{}), nil
+		},
+	)
+}
+
+func TestNewComment(t *testing.T) {
+	originalTimeNow := timeNow
+	defer func() {
+		timeNow = originalTimeNow
+	}()
+	timeNow = func() time.Time {
+		return time.Unix(1337, 0)
+	}
+
+	comment := NewComment("foo", "bar")
+	if *comment.Content != "foo" || *comment.Account != "bar" || *comment.ModifiedAt != 1337 {
+		t.Errorf("Invalid comment: %+v", comment)
+	}
+}
+
+func TestChangeRecordKeepsComments(t *testing.T) {
+	rrsets := RRsets{Sets: []RRset{{Name: String("foo.example.com."), Type: RRTypePtr(RRTypeA), Records: []Record{{Content: String("127.0.0.1")}}}}}
+	b, err := json.Marshal(rrsets)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !strings.Contains(string(b), `"comments":null`) {
+		t.Errorf("Comments are not sent as null: %s", b)
+	}
+}
+
+func TestSetComments(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, true)
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, nil, &patched)
+
+	comments := []Comment{{Content: String("foo"), Account: String("bar")}}
+	if err := p.Records.SetComments(context.Background(), testDomain, testRecordName, RRTypeTXT, comments); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched) != 1 || len(patched[0].Comments) != 1 {
+		t.Fatalf("Invalid patch: %+v", patched)
+	}
+	if patched[0].Records != nil {
+		t.Error("Records are not left untouched")
+	}
+	if patched[0].Comments[0].ModifiedAt == nil || *patched[0].Comments[0].Account != "bar" {
+		t.Errorf("Invalid comment: %+v", patched[0].Comments[0])
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, true)
+	existing := []RRset{
+		{
+			Name:     String(makeDomainCanonical(testRecordName)),
+			Type:     RRTypePtr(RRTypeTXT),
+			TTL:      Uint32(300),
+			Records:  []Record{{Content: String("\"Testing...\"")}},
+			Comments: []Comment{{Content: String("existing"), Account: String("other"), ModifiedAt: Uint64(1)}},
+		},
+	}
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, existing, &patched)
+
+	if err := p.Records.AddComment(context.Background(), testDomain, testRecordName, RRTypeTXT, NewComment("new", "me")); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched) != 1 || len(patched[0].Comments) != 2 {
+		t.Fatalf("Invalid patch: %+v", patched)
+	}
+	if *patched[0].Comments[0].Content != "existing" || *patched[0].Comments[1].Content != "new" {
+		t.Errorf("Invalid comments: %+v", patched[0].Comments)
+	}
+
+	if err := p.Records.AddComment(context.Background(), testDomain, "doesnt-exist."+testDomain, RRTypeTXT, NewComment("new", "me")); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestClearComments(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, true)
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, nil, &patched)
+
+	if err := p.Records.ClearComments(context.Background(), testDomain, testRecordName, RRTypeTXT); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched) != 1 || patched[0].Comments == nil || len(patched[0].Comments) != 0 {
+		t.Errorf("Invalid patch: %+v", patched)
+	}
+}
+
+func TestDisableRecord(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, false)
+	existing := []RRset{
+		{
+			Name: String(makeDomainCanonical(testRecordName)),
+			Type: RRTypePtr(RRTypeA),
+			TTL:  Uint32(300),
+			Records: []Record{
+				{Content: String("127.0.0.1"), Disabled: Bool(false)},
+				{Content: String("127.0.0.2"), Disabled: Bool(false)},
+			},
+			Comments: []Comment{{Content: String("existing")}},
+		},
+	}
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, existing, &patched)
+
+	if err := p.Records.Disable(context.Background(), testDomain, testRecordName, RRTypeA, []string{"127.0.0.2"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched) != 1 || len(patched[0].Records) != 2 {
+		t.Fatalf("Invalid patch: %+v", patched)
+	}
+	if *patched[0].TTL != 300 || patched[0].Comments != nil {
+		t.Errorf("RRset attributes are not kept: %+v", patched[0])
+	}
+	if *patched[0].Records[0].Disabled || !*patched[0].Records[1].Disabled {
+		t.Errorf("Invalid records: %+v", patched[0].Records)
+	}
+
+	if err := p.Records.Disable(context.Background(), testDomain, testRecordName, RRTypeA, []string{"127.0.0.3"}); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestDisableRecordCanonicalContent(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, false)
+	existing := []RRset{
+		{
+			Name:    String(makeDomainCanonical(testRecordName)),
+			Type:    RRTypePtr(RRTypeAAAA),
+			TTL:     Uint32(300),
+			Records: []Record{{Content: String("2001:db8::1"), Disabled: Bool(false)}, {Content: String("2001:db8::2"), Disabled: Bool(false)}},
+		},
+		{
+			Name:    String(makeDomainCanonical(testRecordName)),
+			Type:    RRTypePtr(RRTypeMX),
+			TTL:     Uint32(300),
+			Records: []Record{{Content: String("10 mx1.example.com."), Disabled: Bool(false)}},
+		},
+	}
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, existing, &patched)
+
+	if err := p.Records.Disable(context.Background(), testDomain, testRecordName, RRTypeAAAA, []string{"2001:DB8:0::2"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.Disable(context.Background(), testDomain, testRecordName, RRTypeMX, []string{"10 MX1.example.com"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched) != 2 || *patched[0].Records[0].Disabled || !*patched[0].Records[1].Disabled || !*patched[1].Records[0].Disabled {
+		t.Errorf("Invalid patch: %+v", patched)
+	}
+}
+
+func TestChangeRecordKeepingDisabled(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, false)
+	existing := []RRset{
+		{
+			Name:    String(makeDomainCanonical(testRecordName)),
+			Type:    RRTypePtr(RRTypeA),
+			TTL:     Uint32(300),
+			Records: []Record{{Content: String("127.0.0.1"), Disabled: Bool(true)}, {Content: String("127.0.0.2"), Disabled: Bool(false)}},
+		},
+	}
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, existing, &patched)
+
+	if err := p.Records.ChangeKeepingDisabled(context.Background(), testDomain, testRecordName, RRTypeA, 600, []string{"127.0.0.1", "127.0.0.3"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched) != 1 || len(patched[0].Records) != 2 {
+		t.Fatalf("Invalid patch: %+v", patched)
+	}
+	if !*patched[0].Records[0].Disabled || *patched[0].Records[1].Disabled {
+		t.Errorf("Disabled state is not kept: %+v", patched[0].Records)
+	}
+
+	// Change is a single request which enables all records
+	gets := httpmock.GetCallCountInfo()["GET "+generateTestAPIVHostURL()+"/zones/"+testDomain]
+	if err := p.Records.Change(context.Background(), testDomain, testRecordName, RRTypeA, 600, []string{"127.0.0.1"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if calls := httpmock.GetCallCountInfo()["GET "+generateTestAPIVHostURL()+"/zones/"+testDomain]; calls != gets {
+		t.Errorf("Unexpected zone requests: %d", calls-gets)
+	}
+	if len(patched) != 2 || *patched[1].Records[0].Disabled {
+		t.Errorf("Invalid patch: %+v", patched)
+	}
+}
+
+func TestGetRRSetFiltered(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, false)
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			query := req.URL.Query()
+			if query.Get("rrset_name") != makeDomainCanonical(testRecordName) || query.Get("rrset_type") != string(RRTypeTXT) {
+				return httpmock.NewBytesResponse(http.StatusBadRequest, []byte{}), nil
+			}
+
+			rrset := RRset{Name: String(makeDomainCanonical(testRecordName)), Type: RRTypePtr(RRTypeTXT), TTL: Uint32(300), Records: []Record{{Content: String("\"foo\"")}}}
+			return httpmock.NewJsonResponse(http.StatusOK, Zone{Name: String(makeDomainCanonical(testDomain)), RRsets: []RRset{rrset}})
+		},
+	)
+
+	values, err := p.Records.GetTXT(context.Background(), testDomain, testRecordName)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(values) != 1 || values[0] != "foo" {
+		t.Errorf("Invalid values: %v", values)
+	}
+}
+
+func TestEnableRecord(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	testRecordName := generateTestRecord(p, testDomain, false)
+	existing := []RRset{
+		{
+			Name:    String(makeDomainCanonical(testRecordName)),
+			Type:    RRTypePtr(RRTypeA),
+			TTL:     Uint32(300),
+			Records: []Record{{Content: String("127.0.0.1"), Disabled: Bool(true)}},
+		},
+	}
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, existing, &patched)
+
+	if err := p.Records.Enable(context.Background(), testDomain, testRecordName, RRTypeA, []string{"127.0.0.1"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched) != 1 || *patched[0].Records[0].Disabled {
+		t.Errorf("Invalid patch: %+v", patched)
+	}
+}
+
+func TestListDisabledRecords(t *testing.T) {
+	testDomain := generateTestZone(true)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	p := initialisePowerDNSTestClient()
+	existing := []RRset{
+		{
+			Name:    String("a." + makeDomainCanonical(testDomain)),
+			Type:    RRTypePtr(RRTypeA),
+			Records: []Record{{Content: String("127.0.0.1"), Disabled: Bool(true)}, {Content: String("127.0.0.2"), Disabled: Bool(false)}},
+		},
+		{
+			Name:    String("b." + makeDomainCanonical(testDomain)),
+			Type:    RRTypePtr(RRTypeA),
+			Records: []Record{{Content: String("127.0.0.3"), Disabled: Bool(false)}},
+		},
+	}
+	var patched []RRset
+	registerRRSetMockResponder(testDomain, existing, &patched)
+
+	rrsets, err := p.Records.ListDisabled(context.Background(), testDomain)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(rrsets) != 1 || len(rrsets[0].Records) != 1 || *rrsets[0].Records[0].Content != "127.0.0.1" {
+		t.Errorf("Invalid disabled records: %+v", rrsets)
+	}
+}
+
+// registerZonesStateMockResponder mocks a server hosting the given zones. PATCH requests are applied to the zones and recorded in patched.
+func registerZonesStateMockResponder(zones map[string][]RRset, patched map[string][]RRset) {
+	var mu sync.Mutex
+
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			zonesMock := make([]Zone, 0, len(zones))
+			for name := range zones {
+				zonesMock = append(zonesMock, Zone{ID: String(escapeZoneID(name) + "."), Name: String(name)})
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, zonesMock)
+		},
+	)
+
+	for name := range zones {
+		name := name
+		httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones/"+escapeZoneID(name),
+			func(req *http.Request) (*http.Response, error) {
+				if res := verifyAPIKey(req); res != nil {
+					return res, nil
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				rrsets := append([]RRset{}, zones[name]...)
+				return httpmock.NewJsonResponse(http.StatusOK, Zone{ID: String(escapeZoneID(name) + "."), Name: String(name), RRsets: rrsets})
+			},
+		)
+
+		httpmock.RegisterResponder("PATCH", generateTestAPIVHostURL()+"/zones/"+escapeZoneID(name),
+			func(req *http.Request) (*http.Response, error) {
+				if res := verifyAPIKey(req); res != nil {
+					return res, nil
+				}
+
+				var payload RRsets
+				if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+					return httpmock.NewBytesResponse(http.StatusBadRequest, []byte{}), nil
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				patched[name] = append(patched[name], payload.Sets...)
+				for _, set := range payload.Sets {
+					rrsets := make([]RRset, 0, len(zones[name]))
+					var existing *RRset
+					for _, rrset := range zones[name] {
+						if *rrset.Name == *set.Name && *rrset.Type == *set.Type {
+							existing = &rrset
+							continue
+						}
+						rrsets = append(rrsets, rrset)
+					}
+					if *set.ChangeType == ChangeTypeReplace {
+						set.ChangeType = nil
+						if set.Records == nil && existing != nil {
+							set.Records = existing.Records
+						}
+						if set.Comments == nil && existing != nil {
+							set.Comments = existing.Comments
+						}
+						rrsets = append(rrsets, set)
+					}
+					zones[name] = rrsets
+				}
+				return httpmock.NewBytesResponse(http.StatusNoContent, []byte{}), nil
+			},
+		)
+	}
+}
+
+func TestRecordsByFQDN(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.com.": {}, "sub.example.com.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if err := p.Records.AddByFQDN(ctx, "www.sub.example.com", RRTypeA, 300, []string{"192.0.2.1", "192.0.2.2"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.ChangeByFQDN(ctx, "www.example.com", RRTypeA, 300, []string{"192.0.2.1"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.DisableByFQDN(ctx, "www.sub.example.com", RRTypeA, []string{"192.0.2.2"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.EnableByFQDN(ctx, "www.sub.example.com", RRTypeA, []string{"192.0.2.2"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.DeleteByFQDN(ctx, "www.example.com", RRTypeA); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patched["sub.example.com."]) != 3 || len(patched["example.com."]) != 2 {
+		t.Errorf("Invalid patches: %+v", patched)
+	}
+
+	if err := p.Records.AddByFQDN(ctx, "www.example.org", RRTypeA, 300, []string{"192.0.2.1"}); !isNotFound(err) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestQualifyName(t *testing.T) {
+	testCases := []struct {
+		relativeNames bool
+		name          string
+		wantName      string
+		wantErr       bool
+	}{
+		{false, "www.example.com", "www.example.com.", false},
+		{false, "www", "www.", false},
+		{true, "www", "www.example.com.", false},
+		{true, "a.b", "a.b.example.com.", false},
+		{true, "@", "example.com.", false},
+		{true, "www.example.com.", "www.example.com.", false},
+		{true, "WWW.Example.COM.", "WWW.Example.COM.", false},
+		{true, "example.com.", "example.com.", false},
+		{true, "www.example.org.", "", true},
+		{true, "www.notexample.com.", "", true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("TestCase%d", i), func(t *testing.T) {
+			p := initialisePowerDNSTestClient()
+			p.RelativeNames = tc.relativeNames
+
+			name, err := p.Records.qualifyName("example.com", tc.name)
+			if tc.wantErr != errors.Is(err, ErrNameOutsideZone) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != tc.wantName {
+				t.Errorf("%s != %s", name, tc.wantName)
+			}
+		})
+	}
+}
+
+func TestRelativeNames(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.com.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	p.RelativeNames = true
+	ctx := context.Background()
+
+	if err := p.Records.Add(ctx, "example.com", "www", RRTypeA, 300, []string{"192.0.2.1"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.Add(ctx, "example.com", "@", RRTypeMX, 300, []string{"10 mx.example.com."}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.Disable(ctx, "example.com", "www", RRTypeA, []string{"192.0.2.1"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.AddByFQDN(ctx, "ftp.example.com", RRTypeA, 300, []string{"192.0.2.2"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.Records.Patch(ctx, "example.com", &RRsets{Sets: []RRset{{Name: String("mail"), Type: RRTypePtr(RRTypeA), ChangeType: ChangeTypePtr(ChangeTypeDelete)}}}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	wantNames := []string{"www.example.com.", "example.com.", "www.example.com.", "ftp.example.com.", "mail.example.com."}
+	if len(patched["example.com."]) != len(wantNames) {
+		t.Fatalf("Invalid patches: %+v", patched)
+	}
+	for i, rrset := range patched["example.com."] {
+		if *rrset.Name != wantNames[i] {
+			t.Errorf("%s != %s", *rrset.Name, wantNames[i])
+		}
+	}
+
+	if err := p.Records.Add(ctx, "example.com", "www.example.org.", RRTypeA, 300, []string{"192.0.2.1"}); !errors.Is(err, ErrNameOutsideZone) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	b := p.Records.NewBatch(0)
+	b.Delete("example.com", "www.example.org.", RRTypeA).Delete("example.com", "www", RRTypeA)
+	if _, err := b.Flush(ctx); !errors.Is(err, ErrNameOutsideZone) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(patched["example.com."]) != len(wantNames) {
+		t.Error("Request has been sent despite invalid name")
+	}
+}
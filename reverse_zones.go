@@ -0,0 +1,189 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ReverseZoneNames returns the in-addr.arpa or ip6.arpa zone names which cover a given prefix.
+//
+// IPv4 prefixes which are not aligned to an octet boundary are expanded to the next longer boundary,
+// and IPv6 prefixes which are not aligned to a nibble boundary are expanded to the next longer nibble.
+// IPv4 prefixes longer than /24 result in a single RFC 2317 zone named like "0/26.2.0.192.in-addr.arpa.".
+func ReverseZoneNames(prefix netip.Prefix) ([]string, error) {
+	if !prefix.IsValid() {
+		return nil, errors.New("invalid prefix")
+	}
+	if prefix.Bits() == 0 {
+		return nil, fmt.Errorf("prefix %s is too short for a reverse zone", prefix)
+	}
+
+	prefix = prefix.Masked()
+	if prefix.Addr().Is4() {
+		return reverseZoneNames4(prefix), nil
+	}
+	return reverseZoneNames6(prefix), nil
+}
+
+func reverseZoneNames4(prefix netip.Prefix) []string {
+	bits := prefix.Bits()
+	octets := prefix.Addr().As4()
+
+	if bits > 24 {
+		return []string{classlessZoneName(prefix)}
+	}
+
+	boundary := (bits + 7) / 8 * 8
+	count := 1 << (boundary - bits)
+	names := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		labels := make([]string, 0, boundary/8)
+		for j := 0; j < boundary/8; j++ {
+			octet := int(octets[j])
+			if j == boundary/8-1 {
+				octet += i
+			}
+			labels = append(labels, strconv.Itoa(octet))
+		}
+		reverseLabels(labels)
+		names = append(names, strings.Join(labels, ".")+".in-addr.arpa.")
+	}
+
+	return names
+}
+
+func reverseZoneNames6(prefix netip.Prefix) []string {
+	bits := prefix.Bits()
+	bytes := prefix.Addr().As16()
+
+	nibbles := make([]int, 0, 32)
+	for _, b := range bytes {
+		nibbles = append(nibbles, int(b>>4), int(b&0x0f))
+	}
+
+	boundary := (bits + 3) / 4
+	count := 1 << (boundary*4 - bits)
+	names := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		labels := make([]string, 0, boundary)
+		for j := 0; j < boundary; j++ {
+			nibble := nibbles[j]
+			if j == boundary-1 {
+				nibble += i
+			}
+			labels = append(labels, strconv.FormatInt(int64(nibble), 16))
+		}
+		reverseLabels(labels)
+		names = append(names, strings.Join(labels, ".")+".ip6.arpa.")
+	}
+
+	return names
+}
+
+// isClassless reports whether a prefix needs an RFC 2317 classless delegation
+func isClassless(prefix netip.Prefix) bool {
+	return prefix.Addr().Is4() && prefix.Bits() > 24
+}
+
+func classlessZoneName(prefix netip.Prefix) string {
+	octets := prefix.Masked().Addr().As4()
+	return fmt.Sprintf("%d/%d.%d.%d.%d.in-addr.arpa.", octets[3], prefix.Bits(), octets[2], octets[1], octets[0])
+}
+
+// ClasslessDelegation returns the name of the parent zone and the RRsets which delegate an IPv4 prefix longer than /24 according to RFC 2317.
+// The RRsets consist of an NS RRset for the classless zone and one CNAME RRset per address.
+func ClasslessDelegation(prefix netip.Prefix, nameservers []string, ttl uint32) (string, []RRset, error) {
+	if !prefix.IsValid() || !isClassless(prefix) {
+		return "", nil, fmt.Errorf("prefix %s is not an IPv4 prefix longer than /24", prefix)
+	}
+	if len(nameservers) == 0 {
+		return "", nil, errors.New("classless delegation requires at least one nameserver")
+	}
+
+	prefix = prefix.Masked()
+	octets := prefix.Addr().As4()
+	parent := fmt.Sprintf("%d.%d.%d.in-addr.arpa.", octets[2], octets[1], octets[0])
+	child := classlessZoneName(prefix)
+
+	nsRecords := make([]Record, 0, len(nameservers))
+	for _, ns := range nameservers {
+		nsRecords = append(nsRecords, Record{Content: String(makeDomainCanonical(ns)), Disabled: Bool(false)})
+	}
+
+	rrsets := []RRset{
+		{
+			Name:       String(child),
+			Type:       RRTypePtr(RRTypeNS),
+			TTL:        Uint32(ttl),
+			ChangeType: ChangeTypePtr(ChangeTypeReplace),
+			Records:    nsRecords,
+		},
+	}
+
+	first := int(octets[3])
+	size := 1 << (32 - prefix.Bits())
+	for host := first; host < first+size; host++ {
+		rrsets = append(rrsets, RRset{
+			Name:       String(fmt.Sprintf("%d.%s", host, parent)),
+			Type:       RRTypePtr(RRTypeCNAME),
+			TTL:        Uint32(ttl),
+			ChangeType: ChangeTypePtr(ChangeTypeReplace),
+			Records:    []Record{{Content: String(fmt.Sprintf("%d.%s", host, child)), Disabled: Bool(false)}},
+		})
+	}
+
+	return parent, rrsets, nil
+}
+
+// AddReverse creates the reverse zones for a given prefix, using the zone as a template for all other attributes.
+// For IPv4 prefixes longer than /24, the RFC 2317 delegation is written into the enclosing parent zone hosted on the server.
+func (z *ZonesService) AddReverse(ctx context.Context, prefix netip.Prefix, template *Zone, ttl uint32) ([]*Zone, error) {
+	if template == nil {
+		return nil, errors.New("zone template is nil")
+	}
+
+	names, err := ReverseZoneNames(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var delegation []RRset
+	var parent *Zone
+	if isClassless(prefix) {
+		var parentName string
+		parentName, delegation, err = ClasslessDelegation(prefix, template.Nameservers, ttl)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent, err = z.findEnclosing(ctx, parentName); err != nil {
+			return nil, err
+		}
+	}
+
+	created := make([]*Zone, 0, len(names))
+	for _, name := range names {
+		zone := *template
+		zone.Name = String(name)
+
+		createdZone, err := z.Add(ctx, &zone)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, createdZone)
+	}
+
+	if parent != nil {
+		if err := (*RecordsService)(z).Patch(ctx, StringValue(parent.Name), &RRsets{Sets: delegation}); err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
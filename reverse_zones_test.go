@@ -0,0 +1,140 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestReverseZoneNames(t *testing.T) {
+	testCases := []struct {
+		prefix    string
+		wantNames []string
+		wantErr   bool
+	}{
+		{"192.0.2.0/24", []string{"2.0.192.in-addr.arpa."}, false},
+		{"10.0.0.0/8", []string{"10.in-addr.arpa."}, false},
+		{"198.51.100.0/22", []string{"100.51.198.in-addr.arpa.", "101.51.198.in-addr.arpa.", "102.51.198.in-addr.arpa.", "103.51.198.in-addr.arpa."}, false},
+		{"192.0.2.64/26", []string{"64/26.2.0.192.in-addr.arpa."}, false},
+		{"192.0.2.77/26", []string{"64/26.2.0.192.in-addr.arpa."}, false},
+		{"2001:db8::/32", []string{"8.b.d.0.1.0.0.2.ip6.arpa."}, false},
+		{"2001:db8::/31", []string{"8.b.d.0.1.0.0.2.ip6.arpa.", "9.b.d.0.1.0.0.2.ip6.arpa."}, false},
+		{"0.0.0.0/0", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.prefix, func(t *testing.T) {
+			names, err := ReverseZoneNames(netip.MustParsePrefix(tc.prefix))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(names, tc.wantNames) {
+				t.Errorf("%v != %v", names, tc.wantNames)
+			}
+		})
+	}
+}
+
+func TestClasslessDelegation(t *testing.T) {
+	parent, rrsets, err := ClasslessDelegation(netip.MustParsePrefix("192.0.2.64/30"), []string{"ns1.example.com"}, 3600)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if parent != "2.0.192.in-addr.arpa." {
+		t.Errorf("Invalid parent: %s", parent)
+	}
+	if len(rrsets) != 5 {
+		t.Fatalf("Invalid amount of RRsets: %d", len(rrsets))
+	}
+	if *rrsets[0].Type != RRTypeNS || *rrsets[0].Name != "64/30.2.0.192.in-addr.arpa." || *rrsets[0].Records[0].Content != "ns1.example.com." {
+		t.Errorf("Invalid NS RRset: %+v", rrsets[0])
+	}
+	if *rrsets[4].Name != "67.2.0.192.in-addr.arpa." || *rrsets[4].Records[0].Content != "67.64/30.2.0.192.in-addr.arpa." {
+		t.Errorf("Invalid CNAME RRset: %+v", rrsets[4])
+	}
+
+	if _, _, err := ClasslessDelegation(netip.MustParsePrefix("192.0.2.0/24"), []string{"ns1.example.com"}, 3600); err == nil {
+		t.Error("error is nil")
+	}
+	if _, _, err := ClasslessDelegation(netip.MustParsePrefix("192.0.2.0/25"), nil, 3600); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestEscapeZoneID(t *testing.T) {
+	testCases := []struct {
+		domain string
+		wantID string
+	}{
+		{"example.com.", "example.com"},
+		{"example.com", "example.com"},
+		{"0/26.2.0.192.in-addr.arpa.", "0=2F26.2.0.192.in-addr.arpa"},
+		{"_foo=bar.example.com.", "=5Ffoo=3Dbar.example.com"},
+		{".", "=2E"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.domain, func(t *testing.T) {
+			if id := escapeZoneID(tc.domain); id != tc.wantID {
+				t.Errorf("%s != %s", id, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestAddReverseZone(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var created []string
+	httpmock.RegisterResponder("POST", generateTestAPIVHostURL()+"/zones",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var zone Zone
+			if err := json.NewDecoder(req.Body).Decode(&zone); err != nil {
+				return httpmock.NewBytesResponse(http.StatusBadRequest, []byte{}), nil
+			}
+			created = append(created, *zone.Name)
+			zone.ID = String(escapeZoneID(*zone.Name) + ".")
+			return httpmock.NewJsonResponse(http.StatusCreated, zone)
+		},
+	)
+
+	zones := map[string][]RRset{"2.0.192.in-addr.arpa.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	template := &Zone{Kind: ZoneKindPtr(NativeZoneKind), Nameservers: []string{"ns1.example.com."}}
+
+	result, err := p.Zones.AddReverse(context.Background(), netip.MustParsePrefix("192.0.2.128/25"), template, 3600)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(result) != 1 || *result[0].ID != "128=2F25.2.0.192.in-addr.arpa." {
+		t.Errorf("Invalid created zones: %+v", result)
+	}
+	if !reflect.DeepEqual(created, []string{"128/25.2.0.192.in-addr.arpa."}) {
+		t.Errorf("Invalid created zones: %v", created)
+	}
+	if len(patched["2.0.192.in-addr.arpa."]) != 129 {
+		t.Errorf("Invalid delegation: %d RRsets", len(patched["2.0.192.in-addr.arpa."]))
+	}
+	if template.Name != nil {
+		t.Error("Template has been modified")
+	}
+
+	if _, err := p.Zones.AddReverse(context.Background(), netip.MustParsePrefix("198.51.100.0/25"), template, 3600); err == nil {
+		t.Error("error is nil")
+	}
+}
@@ -0,0 +1,548 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RolloverType represents a DNSSEC key rollover method
+type RolloverType string
+
+const (
+	// RolloverZSKPrePublish replaces a ZSK using the pre-publish method (RFC 6781, section 4.1.1.1)
+	RolloverZSKPrePublish RolloverType = "zsk-pre-publish"
+	// RolloverKSKDoubleDS replaces a KSK using the double-DS method (RFC 6781, section 4.1.2)
+	RolloverKSKDoubleDS RolloverType = "ksk-double-ds"
+)
+
+// RolloverPhase represents the state of a key rollover
+type RolloverPhase string
+
+const (
+	// RolloverPhaseCreating creates the new key
+	RolloverPhaseCreating RolloverPhase = "creating"
+	// RolloverPhasePublished waits until the DNSKEY of the new ZSK is known to all resolvers
+	RolloverPhasePublished RolloverPhase = "published"
+	// RolloverPhaseWaitingForDS waits until the DS of the new KSK is present at the parent
+	RolloverPhaseWaitingForDS RolloverPhase = "waiting-for-ds"
+	// RolloverPhaseDSPublished waits until the DS of the new KSK is known to all resolvers
+	RolloverPhaseDSPublished RolloverPhase = "ds-published"
+	// RolloverPhaseRetiring waits until the old key is not used by any resolver anymore
+	RolloverPhaseRetiring RolloverPhase = "retiring"
+	// RolloverPhaseWaitingForDSRemoval waits until the DS of the old KSK is removed from the parent
+	RolloverPhaseWaitingForDSRemoval RolloverPhase = "waiting-for-ds-removal"
+	// RolloverPhaseDone marks a finished rollover
+	RolloverPhaseDone RolloverPhase = "done"
+)
+
+// ErrRolloverInProgress is returned if a rollover is started for a zone which has an unfinished rollover
+var ErrRolloverInProgress = errors.New("rollover in progress")
+
+// RolloverState is the persisted state of a key rollover
+type RolloverState struct {
+	Zone         string        `json:"zone"`
+	Type         RolloverType  `json:"type"`
+	Phase        RolloverPhase `json:"phase"`
+	OldKeyID     uint64        `json:"old_key_id"`
+	NewKeyID     uint64        `json:"new_key_id,omitempty"`
+	KnownKeyIDs  []uint64      `json:"known_key_ids"`
+	NewDS        []string      `json:"new_ds,omitempty"`
+	OldDS        []string      `json:"old_ds,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	NextActionAt time.Time     `json:"next_action_at"`
+}
+
+// Done reports whether the rollover has finished
+func (s *RolloverState) Done() bool {
+	return s.Phase == RolloverPhaseDone
+}
+
+// RolloverStore persists the state of rollovers, so that they can be resumed after a restart
+type RolloverStore interface {
+	// Load returns the state of a zone, or nil if no rollover is known
+	Load(ctx context.Context, zone string) (*RolloverState, error)
+	// Save persists the state of a zone
+	Save(ctx context.Context, state *RolloverState) error
+}
+
+// Clock provides the current time to the rollover engine
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return timeNow()
+}
+
+// Rollover performs DNSSEC key rollovers as a resumable state machine.
+// Step has to be called periodically, each call advances the rollover as far as possible at that point in time.
+type Rollover struct {
+	client *Client
+	store  RolloverStore
+
+	// Clock defines the current time, it can be replaced for testing
+	Clock Clock
+	// DSPresent reports whether the given DS records are published at the parent.
+	// It is called with the DS of the new KSK, and with each DS of the old KSK before a KSK rollover finishes.
+	DSPresent func(ctx context.Context, zone string, ds []string) (bool, error)
+	// DSPollInterval defines the delay between two DSPresent checks
+	DSPollInterval time.Duration
+	// PropagationDelay is the time until changes reach all authoritative servers. If zero, the SOA refresh interval is used.
+	PropagationDelay time.Duration
+	// ParentDSTTL is the TTL of the DS records at the parent. If zero, the DNSKEY TTL of the zone is used.
+	ParentDSTTL time.Duration
+}
+
+// NewRollover creates a rollover engine which persists its state in a given store
+func NewRollover(client *Client, store RolloverStore) *Rollover {
+	return &Rollover{
+		client:         client,
+		store:          store,
+		Clock:          systemClock{},
+		DSPollInterval: time.Hour,
+	}
+}
+
+// rolloverTimings contains the durations derived from a zone
+type rolloverTimings struct {
+	dnskeyTTL   time.Duration
+	maxTTL      time.Duration
+	propagation time.Duration
+}
+
+// Start begins a new rollover of the active key of a given type. The new key is created by the first call of Step.
+func (r *Rollover) Start(ctx context.Context, domain string, rolloverType RolloverType) (*RolloverState, error) {
+	zone := strings.ToLower(makeDomainCanonical(domain))
+
+	existing, err := r.store.Load(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !existing.Done() {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrRolloverInProgress, zone, existing.Phase)
+	}
+
+	keyType, err := rolloverKeyType(rolloverType)
+	if err != nil {
+		return nil, err
+	}
+
+	cryptokeys, err := r.client.Cryptokeys.List(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var old *Cryptokey
+	knownKeyIDs := make([]uint64, 0, len(cryptokeys))
+	for i := range cryptokeys {
+		knownKeyIDs = append(knownKeyIDs, Uint64Value(cryptokeys[i].ID))
+		if StringValue(cryptokeys[i].KeyType) != string(keyType) || !BoolValue(cryptokeys[i].Active) {
+			continue
+		}
+		if old != nil {
+			return nil, fmt.Errorf("zone %s has more than one active %s", zone, keyType)
+		}
+		old = &cryptokeys[i]
+	}
+	if old == nil {
+		return nil, fmt.Errorf("zone %s has no active %s", zone, keyType)
+	}
+
+	now := r.Clock.Now()
+	state := &RolloverState{
+		Zone:         zone,
+		Type:         rolloverType,
+		Phase:        RolloverPhaseCreating,
+		OldKeyID:     Uint64Value(old.ID),
+		KnownKeyIDs:  knownKeyIDs,
+		OldDS:        old.DS,
+		StartedAt:    now,
+		UpdatedAt:    now,
+		NextActionAt: now,
+	}
+	if err := r.store.Save(ctx, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Step advances the rollover of a zone as far as possible and returns its current state.
+// All actions are idempotent, so Step can safely be repeated after a crash.
+func (r *Rollover) Step(ctx context.Context, domain string) (*RolloverState, error) {
+	zone := strings.ToLower(makeDomainCanonical(domain))
+
+	state, err := r.store.Load(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, newNotFoundError(fmt.Sprintf("no rollover for zone %s", zone))
+	}
+
+	for !state.Done() && !r.Clock.Now().Before(state.NextActionAt) {
+		phase := state.Phase
+		if err := r.advance(ctx, state); err != nil {
+			return state, err
+		}
+		state.UpdatedAt = r.Clock.Now()
+		if err := r.store.Save(ctx, state); err != nil {
+			return state, err
+		}
+		if state.Phase == phase {
+			break
+		}
+	}
+
+	return state, nil
+}
+
+func (r *Rollover) advance(ctx context.Context, state *RolloverState) error {
+	switch state.Phase {
+	case RolloverPhaseCreating:
+		return r.createKey(ctx, state)
+	case RolloverPhasePublished:
+		return r.activateZSK(ctx, state)
+	case RolloverPhaseWaitingForDS:
+		return r.checkDS(ctx, state)
+	case RolloverPhaseDSPublished:
+		return r.swapKSK(ctx, state)
+	case RolloverPhaseRetiring:
+		return r.removeOldKey(ctx, state)
+	case RolloverPhaseWaitingForDSRemoval:
+		return r.checkDSRemoval(ctx, state)
+	default:
+		return fmt.Errorf("unknown rollover phase %q", state.Phase)
+	}
+}
+
+func (r *Rollover) createKey(ctx context.Context, state *RolloverState) error {
+	keyType, err := rolloverKeyType(state.Type)
+	if err != nil {
+		return err
+	}
+
+	old, err := r.client.Cryptokeys.Get(ctx, state.Zone, state.OldKeyID)
+	if err != nil {
+		return err
+	}
+
+	// A previous attempt might have created the key without persisting its ID
+	newKey, err := r.findCreatedKey(ctx, state, keyType)
+	if err != nil {
+		return err
+	}
+	if newKey == nil {
+		spec := CryptokeySpec{KeyType: keyType, Algorithm: StringValue(old.Algorithm), Bits: Uint64Value(old.Bits), Active: Bool(false)}
+		// A KSK is only published after its DS is present at the parent
+		spec.Published = Bool(state.Type == RolloverZSKPrePublish)
+		if newKey, err = r.client.Cryptokeys.Create(ctx, state.Zone, spec); err != nil {
+			return err
+		}
+	}
+
+	timings, err := r.timings(ctx, state.Zone)
+	if err != nil {
+		return err
+	}
+
+	state.NewKeyID = Uint64Value(newKey.ID)
+	state.NewDS = newKey.DS
+	if state.Type == RolloverZSKPrePublish {
+		state.Phase = RolloverPhasePublished
+		state.NextActionAt = r.Clock.Now().Add(timings.dnskeyTTL + timings.propagation)
+		return nil
+	}
+
+	state.Phase = RolloverPhaseWaitingForDS
+	state.NextActionAt = r.Clock.Now()
+	return nil
+}
+
+func (r *Rollover) findCreatedKey(ctx context.Context, state *RolloverState, keyType CryptokeyType) (*Cryptokey, error) {
+	cryptokeys, err := r.client.Cryptokeys.List(ctx, state.Zone)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[uint64]bool, len(state.KnownKeyIDs))
+	for _, id := range state.KnownKeyIDs {
+		known[id] = true
+	}
+
+	for i := range cryptokeys {
+		if StringValue(cryptokeys[i].KeyType) == string(keyType) && !known[Uint64Value(cryptokeys[i].ID)] {
+			return &cryptokeys[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *Rollover) activateZSK(ctx context.Context, state *RolloverState) error {
+	if err := r.client.Cryptokeys.Activate(ctx, state.Zone, state.NewKeyID); err != nil {
+		return err
+	}
+	// The old ZSK stays published until all signatures made with it have expired from caches
+	if err := r.client.Cryptokeys.Deactivate(ctx, state.Zone, state.OldKeyID); err != nil {
+		return err
+	}
+
+	timings, err := r.timings(ctx, state.Zone)
+	if err != nil {
+		return err
+	}
+
+	state.Phase = RolloverPhaseRetiring
+	state.NextActionAt = r.Clock.Now().Add(timings.maxTTL + timings.propagation)
+	return nil
+}
+
+func (r *Rollover) checkDS(ctx context.Context, state *RolloverState) error {
+	if r.DSPresent == nil {
+		return errors.New("no DSPresent check configured")
+	}
+
+	present, err := r.DSPresent(ctx, state.Zone, state.NewDS)
+	if err != nil {
+		return err
+	}
+	if !present {
+		state.NextActionAt = r.Clock.Now().Add(r.DSPollInterval)
+		return nil
+	}
+
+	timings, err := r.timings(ctx, state.Zone)
+	if err != nil {
+		return err
+	}
+
+	dsTTL := r.ParentDSTTL
+	if dsTTL == 0 {
+		dsTTL = timings.dnskeyTTL
+	}
+
+	state.Phase = RolloverPhaseDSPublished
+	state.NextActionAt = r.Clock.Now().Add(dsTTL + timings.propagation)
+	return nil
+}
+
+func (r *Rollover) swapKSK(ctx context.Context, state *RolloverState) error {
+	for _, change := range []func(context.Context, string, uint64) error{r.client.Cryptokeys.Publish, r.client.Cryptokeys.Activate} {
+		if err := change(ctx, state.Zone, state.NewKeyID); err != nil {
+			return err
+		}
+	}
+	for _, change := range []func(context.Context, string, uint64) error{r.client.Cryptokeys.Deactivate, r.client.Cryptokeys.Unpublish} {
+		if err := change(ctx, state.Zone, state.OldKeyID); err != nil {
+			return err
+		}
+	}
+
+	timings, err := r.timings(ctx, state.Zone)
+	if err != nil {
+		return err
+	}
+
+	state.Phase = RolloverPhaseRetiring
+	state.NextActionAt = r.Clock.Now().Add(timings.dnskeyTTL + timings.propagation)
+	return nil
+}
+
+func (r *Rollover) removeOldKey(ctx context.Context, state *RolloverState) error {
+	if err := r.client.Cryptokeys.Delete(ctx, state.Zone, state.OldKeyID); err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if state.Type == RolloverKSKDoubleDS && len(state.OldDS) > 0 {
+		state.Phase = RolloverPhaseWaitingForDSRemoval
+		state.NextActionAt = r.Clock.Now()
+		return nil
+	}
+
+	state.Phase = RolloverPhaseDone
+	return nil
+}
+
+// checkDSRemoval finishes a KSK rollover once no DS of the old KSK is left at the parent
+func (r *Rollover) checkDSRemoval(ctx context.Context, state *RolloverState) error {
+	if r.DSPresent == nil {
+		return errors.New("no DSPresent check configured")
+	}
+
+	for _, ds := range state.OldDS {
+		present, err := r.DSPresent(ctx, state.Zone, []string{ds})
+		if err != nil {
+			return err
+		}
+		if present {
+			state.NextActionAt = r.Clock.Now().Add(r.DSPollInterval)
+			return nil
+		}
+	}
+
+	state.Phase = RolloverPhaseDone
+	return nil
+}
+
+// timings derives the DNSKEY TTL, the maximum TTL and the propagation delay of a zone.
+// PowerDNS serves DNSKEY records with the SOA minimum as TTL.
+func (r *Rollover) timings(ctx context.Context, domain string) (*rolloverTimings, error) {
+	zone, err := r.client.Zones.get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	soa := findRRSet(zone.RRsets, StringValue(zone.Name), RRTypeSOA)
+	if soa == nil || len(soa.Records) == 0 {
+		return nil, fmt.Errorf("zone %s has no SOA record", domain)
+	}
+
+	fields := strings.Fields(StringValue(soa.Records[0].Content))
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("invalid SOA record in zone %s", domain)
+	}
+	refresh, err := strconv.ParseUint(fields[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA refresh in zone %s: %v", domain, err)
+	}
+	minimum, err := strconv.ParseUint(fields[6], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA minimum in zone %s: %v", domain, err)
+	}
+
+	timings := &rolloverTimings{
+		dnskeyTTL:   time.Duration(minimum) * time.Second,
+		propagation: r.PropagationDelay,
+	}
+	if timings.propagation == 0 {
+		timings.propagation = time.Duration(refresh) * time.Second
+	}
+
+	maxTTL := uint32(minimum)
+	for _, rrset := range zone.RRsets {
+		if ttl := Uint32Value(rrset.TTL); ttl > maxTTL {
+			maxTTL = ttl
+		}
+	}
+	if dnskey := findRRSet(zone.RRsets, StringValue(zone.Name), RRTypeDNSKEY); dnskey != nil && dnskey.TTL != nil {
+		timings.dnskeyTTL = time.Duration(*dnskey.TTL) * time.Second
+	}
+	timings.maxTTL = time.Duration(maxTTL) * time.Second
+
+	return timings, nil
+}
+
+func rolloverKeyType(rolloverType RolloverType) (CryptokeyType, error) {
+	switch rolloverType {
+	case RolloverZSKPrePublish:
+		return CryptokeyTypeZSK, nil
+	case RolloverKSKDoubleDS:
+		return CryptokeyTypeKSK, nil
+	default:
+		return "", fmt.Errorf("unknown rollover type %q", rolloverType)
+	}
+}
+
+// MemoryRolloverStore keeps rollover states in memory, which is mainly useful for testing
+type MemoryRolloverStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+// NewMemoryRolloverStore creates an empty MemoryRolloverStore
+func NewMemoryRolloverStore() *MemoryRolloverStore {
+	return &MemoryRolloverStore{states: make(map[string][]byte)}
+}
+
+// Load returns a copy of the state of a zone
+func (m *MemoryRolloverStore) Load(_ context.Context, zone string) (*RolloverState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.states[zone]
+	if !ok {
+		return nil, nil
+	}
+	state := new(RolloverState)
+	return state, json.Unmarshal(data, state)
+}
+
+// Save stores a copy of the state of a zone
+func (m *MemoryRolloverStore) Save(_ context.Context, state *RolloverState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[state.Zone] = data
+	return nil
+}
+
+// FileRolloverStore keeps one JSON file per zone in a directory
+type FileRolloverStore struct {
+	Dir string
+}
+
+// NewFileRolloverStore creates a FileRolloverStore for a given directory
+func NewFileRolloverStore(dir string) *FileRolloverStore {
+	return &FileRolloverStore{Dir: dir}
+}
+
+func (f *FileRolloverStore) path(zone string) string {
+	return filepath.Join(f.Dir, escapeZoneID(zone)+".json")
+}
+
+// Load reads the state of a zone
+func (f *FileRolloverStore) Load(_ context.Context, zone string) (*RolloverState, error) {
+	data, err := os.ReadFile(f.path(zone))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(RolloverState)
+	return state, json.Unmarshal(data, state)
+}
+
+// Save writes the state of a zone atomically
+func (f *FileRolloverStore) Save(_ context.Context, state *RolloverState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.Dir, ".rollover-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(state.Zone))
+}
@@ -0,0 +1,375 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+// cryptokeysState is a stateful mock of the cryptokeys of a single zone
+type cryptokeysState struct {
+	mu      sync.Mutex
+	keys    map[uint64]*Cryptokey
+	nextID  uint64
+	created int
+}
+
+func (s *cryptokeysState) list() []Cryptokey {
+	keys := make([]Cryptokey, 0, len(s.keys))
+	for id := uint64(0); id < s.nextID; id++ {
+		if key, ok := s.keys[id]; ok {
+			keys = append(keys, *key)
+		}
+	}
+	return keys
+}
+
+func (s *cryptokeysState) add(key Cryptokey) *Cryptokey {
+	key.Type = String("Cryptokey")
+	key.ID = Uint64(s.nextID)
+	if key.Active == nil {
+		key.Active = Bool(false)
+	}
+	if key.Published == nil {
+		key.Published = Bool(true)
+	}
+	key.DS = []string{strconv.FormatUint(s.nextID, 10) + " 13 2 abcdef"}
+	s.keys[s.nextID] = &key
+	s.nextID++
+	return &key
+}
+
+func registerRolloverMockResponder(testDomain string, soaContent string, state *cryptokeysState) {
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			zoneMock := Zone{
+				Name: String(testDomain + "."),
+				RRsets: []RRset{
+					{Name: String(testDomain + "."), Type: RRTypePtr(RRTypeSOA), TTL: Uint32(3600), Records: []Record{{Content: String(soaContent)}}},
+					{Name: String("www." + testDomain + "."), Type: RRTypePtr(RRTypeA), TTL: Uint32(86400), Records: []Record{{Content: String("192.0.2.1")}}},
+				},
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, zoneMock)
+		},
+	)
+
+	baseURL := generateTestAPIVHostURL() + "/zones/" + testDomain + "/cryptokeys"
+	keyID := func(req *http.Request) uint64 {
+		id, _ := strconv.ParseUint(req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:], 10, 64)
+		return id
+	}
+
+	httpmock.RegisterResponder("GET", baseURL,
+		func(req *http.Request) (*http.Response, error) {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			return httpmock.NewJsonResponse(http.StatusOK, state.list())
+		},
+	)
+
+	httpmock.RegisterResponder("POST", baseURL,
+		func(req *http.Request) (*http.Response, error) {
+			var key Cryptokey
+			if json.NewDecoder(req.Body).Decode(&key) != nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			state.created++
+			return httpmock.NewJsonResponse(http.StatusCreated, state.add(key))
+		},
+	)
+
+	httpmock.RegisterResponder("GET", `=~^`+baseURL+`/\d+\z`,
+		func(req *http.Request) (*http.Response, error) {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			key, ok := state.keys[keyID(req)]
+			if !ok {
+				return httpmock.NewJsonResponse(http.StatusNotFound, Error{Message: "Not Found"})
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, key)
+		},
+	)
+
+	httpmock.RegisterResponder("PUT", `=~^`+baseURL+`/\d+\z`,
+		func(req *http.Request) (*http.Response, error) {
+			// Like the server, the active flag is required and the published flag defaults to true
+			var change Cryptokey
+			if json.NewDecoder(req.Body).Decode(&change) != nil || change.Active == nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+			if change.Published == nil {
+				change.Published = Bool(true)
+			}
+
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			key, ok := state.keys[keyID(req)]
+			if !ok {
+				return httpmock.NewJsonResponse(http.StatusNotFound, Error{Message: "Not Found"})
+			}
+			key.Active = change.Active
+			key.Published = change.Published
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+
+	httpmock.RegisterResponder("DELETE", `=~^`+baseURL+`/\d+\z`,
+		func(req *http.Request) (*http.Response, error) {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			if _, ok := state.keys[keyID(req)]; !ok {
+				return httpmock.NewJsonResponse(http.StatusNotFound, Error{Message: "Not Found"})
+			}
+			delete(state.keys, keyID(req))
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+}
+
+func newCryptokeysState() *cryptokeysState {
+	state := &cryptokeysState{keys: make(map[uint64]*Cryptokey)}
+	state.add(Cryptokey{KeyType: String("ksk"), Active: Bool(true), Algorithm: String("ECDSAP256SHA256"), Bits: Uint64(256)})
+	state.add(Cryptokey{KeyType: String("zsk"), Active: Bool(true), Algorithm: String("ECDSAP256SHA256"), Bits: Uint64(256)})
+	return state
+}
+
+func TestRolloverZSKPrePublish(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	keys := newCryptokeysState()
+	registerRolloverMockResponder(testDomain, "ns.example.com. hostmaster.example.com. 1 600 300 604800 3600", keys)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rollover := NewRollover(initialisePowerDNSTestClient(), NewMemoryRolloverStore())
+	rollover.Clock = clock
+	ctx := context.Background()
+
+	state, err := rollover.Start(ctx, testDomain, RolloverZSKPrePublish)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if state.OldKeyID != 1 {
+		t.Errorf("Invalid old key ID %d", state.OldKeyID)
+	}
+	if _, err := rollover.Start(ctx, testDomain, RolloverZSKPrePublish); !errors.Is(err, ErrRolloverInProgress) {
+		t.Errorf("Expected ErrRolloverInProgress, got %v", err)
+	}
+
+	state, err = rollover.Step(ctx, testDomain)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if state.Phase != RolloverPhasePublished || state.NewKeyID != 2 {
+		t.Fatalf("Invalid state after creation: %+v", state)
+	}
+	if !*keys.keys[2].Published || *keys.keys[2].Active {
+		t.Errorf("New ZSK must be published and inactive: %+v", keys.keys[2])
+	}
+	// DNSKEY TTL (SOA minimum) plus propagation delay (SOA refresh)
+	if want := clock.now.Add(time.Hour + 10*time.Minute); !state.NextActionAt.Equal(want) {
+		t.Errorf("Invalid next action %s, want %s", state.NextActionAt, want)
+	}
+
+	clock.Advance(time.Hour)
+	if state, _ = rollover.Step(ctx, testDomain); state.Phase != RolloverPhasePublished {
+		t.Fatalf("Rollover advanced too early: %+v", state)
+	}
+
+	clock.Advance(10 * time.Minute)
+	if state, err = rollover.Step(ctx, testDomain); err != nil || state.Phase != RolloverPhaseRetiring {
+		t.Fatalf("Invalid state after activation: %+v, %v", state, err)
+	}
+	if !*keys.keys[2].Active || *keys.keys[1].Active || !*keys.keys[1].Published {
+		t.Errorf("Invalid keys after activation: %+v %+v", keys.keys[1], keys.keys[2])
+	}
+	// Maximum TTL of the zone plus propagation delay
+	if want := clock.now.Add(24*time.Hour + 10*time.Minute); !state.NextActionAt.Equal(want) {
+		t.Errorf("Invalid next action %s, want %s", state.NextActionAt, want)
+	}
+
+	clock.Advance(25 * time.Hour)
+	if state, err = rollover.Step(ctx, testDomain); err != nil || !state.Done() {
+		t.Fatalf("Invalid state after retirement: %+v, %v", state, err)
+	}
+	if _, ok := keys.keys[1]; ok {
+		t.Error("Old ZSK has not been removed")
+	}
+
+	if _, err := rollover.Start(ctx, testDomain, RolloverZSKPrePublish); err != nil {
+		t.Errorf("Finished rollover prevents a new one: %v", err)
+	}
+}
+
+func TestRolloverKSKDoubleDS(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	keys := newCryptokeysState()
+	registerRolloverMockResponder(testDomain, "ns.example.com. hostmaster.example.com. 1 600 300 604800 3600", keys)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rollover := NewRollover(initialisePowerDNSTestClient(), NewMemoryRolloverStore())
+	rollover.Clock = clock
+	rollover.PropagationDelay = time.Minute
+	rollover.ParentDSTTL = 2 * time.Hour
+
+	dsPresent := false
+	var checkedDS []string
+	rollover.DSPresent = func(ctx context.Context, zone string, ds []string) (bool, error) {
+		checkedDS = ds
+		return dsPresent, nil
+	}
+	ctx := context.Background()
+
+	if _, err := rollover.Start(ctx, testDomain, RolloverKSKDoubleDS); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	state, err := rollover.Step(ctx, testDomain)
+	if err != nil || state.Phase != RolloverPhaseWaitingForDS {
+		t.Fatalf("Invalid state after creation: %+v, %v", state, err)
+	}
+	if *keys.keys[2].Published || *keys.keys[2].Active {
+		t.Errorf("New KSK must be unpublished and inactive: %+v", keys.keys[2])
+	}
+	if len(checkedDS) != 1 || checkedDS[0] != "2 13 2 abcdef" {
+		t.Errorf("Invalid DS checked: %v", checkedDS)
+	}
+	if len(state.OldDS) != 1 || state.OldDS[0] != "0 13 2 abcdef" {
+		t.Errorf("Invalid old DS: %v", state.OldDS)
+	}
+
+	clock.Advance(time.Hour)
+	dsPresent = true
+	if state, err = rollover.Step(ctx, testDomain); err != nil || state.Phase != RolloverPhaseDSPublished {
+		t.Fatalf("Invalid state after DS publication: %+v, %v", state, err)
+	}
+	if want := clock.now.Add(2*time.Hour + time.Minute); !state.NextActionAt.Equal(want) {
+		t.Errorf("Invalid next action %s, want %s", state.NextActionAt, want)
+	}
+
+	clock.Advance(3 * time.Hour)
+	if state, err = rollover.Step(ctx, testDomain); err != nil || state.Phase != RolloverPhaseRetiring {
+		t.Fatalf("Invalid state after swap: %+v, %v", state, err)
+	}
+	if !*keys.keys[2].Published || !*keys.keys[2].Active || *keys.keys[0].Published || *keys.keys[0].Active {
+		t.Errorf("Invalid keys after swap: %+v %+v", keys.keys[0], keys.keys[2])
+	}
+
+	clock.Advance(2 * time.Hour)
+	if state, err = rollover.Step(ctx, testDomain); err != nil || state.Phase != RolloverPhaseWaitingForDSRemoval {
+		t.Fatalf("Invalid state after retirement: %+v, %v", state, err)
+	}
+	if _, ok := keys.keys[0]; ok {
+		t.Error("Old KSK has not been removed")
+	}
+	if len(checkedDS) != 1 || checkedDS[0] != "0 13 2 abcdef" {
+		t.Errorf("Invalid DS checked: %v", checkedDS)
+	}
+
+	dsPresent = false
+	if state, _ = rollover.Step(ctx, testDomain); state.Phase != RolloverPhaseWaitingForDSRemoval {
+		t.Fatalf("Poll interval has not been respected: %+v", state)
+	}
+	clock.Advance(time.Hour)
+	if state, err = rollover.Step(ctx, testDomain); err != nil || !state.Done() {
+		t.Fatalf("Invalid state after DS removal: %+v, %v", state, err)
+	}
+}
+
+func TestRolloverResume(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	keys := newCryptokeysState()
+	registerRolloverMockResponder(testDomain, "ns.example.com. hostmaster.example.com. 1 600 300 604800 3600", keys)
+
+	store := NewFileRolloverStore(t.TempDir())
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rollover := NewRollover(initialisePowerDNSTestClient(), store)
+	rollover.Clock = clock
+	ctx := context.Background()
+
+	if _, err := rollover.Start(ctx, testDomain, RolloverZSKPrePublish); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Simulate a crash after the key has been created, but before the state has been saved
+	keys.add(Cryptokey{KeyType: String("zsk"), Active: Bool(false), Algorithm: String("ECDSAP256SHA256")})
+
+	resumed := NewRollover(initialisePowerDNSTestClient(), NewFileRolloverStore(store.Dir))
+	resumed.Clock = clock
+	state, err := resumed.Step(ctx, testDomain)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if keys.created != 0 || state.NewKeyID != 2 {
+		t.Errorf("Created key has not been adopted: %d keys created, state %+v", keys.created, state)
+	}
+
+	loaded, err := store.Load(ctx, testDomain+".")
+	if err != nil || loaded.Phase != RolloverPhasePublished || loaded.NewKeyID != 2 {
+		t.Errorf("Invalid persisted state: %+v, %v", loaded, err)
+	}
+}
+
+func TestRolloverErrors(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	keys := newCryptokeysState()
+	keys.add(Cryptokey{KeyType: String("zsk"), Active: Bool(true)})
+	registerRolloverMockResponder(testDomain, "invalid", keys)
+
+	rollover := NewRollover(initialisePowerDNSTestClient(), NewMemoryRolloverStore())
+	ctx := context.Background()
+
+	if _, err := rollover.Step(ctx, testDomain); !isNotFound(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if _, err := rollover.Start(ctx, testDomain, "unknown"); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := rollover.Start(ctx, testDomain, RolloverZSKPrePublish); err == nil || !strings.Contains(err.Error(), "more than one") {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if _, err := rollover.Start(ctx, testDomain, RolloverKSKDoubleDS); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := rollover.Step(ctx, testDomain); err == nil || !strings.Contains(err.Error(), "SOA") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
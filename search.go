@@ -0,0 +1,126 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// SearchService handles communication with the search related methods of the Client API
+type SearchService service
+
+// DefaultSearchMax is the maximum amount of search results if no other limit is given
+const DefaultSearchMax = 100
+
+// SearchObjectType represents a string-valued object type of search results
+type SearchObjectType string
+
+// SearchObjectTypePtr is a helper function that allocates a new SearchObjectType value to store v and returns a pointer to it.
+func SearchObjectTypePtr(v SearchObjectType) *SearchObjectType {
+	return &v
+}
+
+const (
+	// SearchObjectTypeAll searches in zones, records and comments
+	SearchObjectTypeAll SearchObjectType = "all"
+	// SearchObjectTypeZone searches in zones
+	SearchObjectTypeZone SearchObjectType = "zone"
+	// SearchObjectTypeRecord searches in records
+	SearchObjectTypeRecord SearchObjectType = "record"
+	// SearchObjectTypeComment searches in comments
+	SearchObjectTypeComment SearchObjectType = "comment"
+)
+
+// SearchResult structure with JSON API metadata
+type SearchResult struct {
+	Name       *string           `json:"name,omitempty"`
+	ObjectType *SearchObjectType `json:"object_type,omitempty"`
+	ZoneID     *string           `json:"zone_id,omitempty"`
+	Zone       *string           `json:"zone,omitempty"`
+	Type       *RRType           `json:"type,omitempty"`
+	TTL        *uint32           `json:"ttl,omitempty"`
+	Content    *string           `json:"content,omitempty"`
+	Disabled   *bool             `json:"disabled,omitempty"`
+}
+
+// SearchResults contains the results of a search and whether they have been cut off at the maximum
+type SearchResults struct {
+	Results   []SearchResult
+	Truncated bool
+}
+
+// SearchPrefix returns a query which matches all values starting with s
+func SearchPrefix(s string) string {
+	return s + "*"
+}
+
+// SearchSuffix returns a query which matches all values ending with s
+func SearchSuffix(s string) string {
+	return "*" + s
+}
+
+// SearchContains returns a query which matches all values containing s
+func SearchContains(s string) string {
+	return "*" + s + "*"
+}
+
+// Search looks for zones, records and comments matching a query.
+// The query supports "*" for any amount of characters and "?" for a single character.
+// At most max results are returned, or DefaultSearchMax if max is zero or negative.
+func (s *SearchService) Search(ctx context.Context, query string, max int, objectType SearchObjectType) (*SearchResults, error) {
+	if max <= 0 {
+		max = DefaultSearchMax
+	}
+	if objectType == "" {
+		objectType = SearchObjectTypeAll
+	}
+
+	// Request one additional result to find out whether the results have been truncated
+	values := url.Values{}
+	values.Add("q", query)
+	values.Add("max", strconv.Itoa(max+1))
+	values.Add("object_type", string(objectType))
+
+	req, err := s.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/search-data", s.client.VHost), &values, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0)
+	if _, err := s.client.do(req, &results); err != nil {
+		return nil, err
+	}
+
+	searchResults := &SearchResults{Results: results}
+	if len(results) > max {
+		searchResults.Results = results[:max]
+		searchResults.Truncated = true
+	}
+	return searchResults, nil
+}
+
+// Zones returns all zone results
+func (r *SearchResults) Zones() []SearchResult {
+	return r.filter(SearchObjectTypeZone)
+}
+
+// Records returns all record results
+func (r *SearchResults) Records() []SearchResult {
+	return r.filter(SearchObjectTypeRecord)
+}
+
+// Comments returns all comment results
+func (r *SearchResults) Comments() []SearchResult {
+	return r.filter(SearchObjectTypeComment)
+}
+
+func (r *SearchResults) filter(objectType SearchObjectType) []SearchResult {
+	filtered := make([]SearchResult, 0)
+	for _, result := range r.Results {
+		if result.ObjectType != nil && *result.ObjectType == objectType {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,91 @@
+package powerdns
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func registerSearchMockResponder() {
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/search-data",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			query := req.URL.Query()
+			if query.Get("q") == "" || query.Get("object_type") == "" {
+				return httpmock.NewStringResponse(http.StatusUnprocessableEntity, "Unprocessable Entity"), nil
+			}
+			max, err := strconv.Atoi(query.Get("max"))
+			if err != nil {
+				return httpmock.NewStringResponse(http.StatusUnprocessableEntity, "Unprocessable Entity"), nil
+			}
+
+			resultsMock := []SearchResult{
+				{Name: String("example.com."), ObjectType: SearchObjectTypePtr(SearchObjectTypeZone), ZoneID: String("example.com.")},
+				{Name: String("www.example.com."), ObjectType: SearchObjectTypePtr(SearchObjectTypeRecord), Zone: String("example.com."), ZoneID: String("example.com."), Type: RRTypePtr(RRTypeA), TTL: Uint32(300), Content: String("10.1.2.3"), Disabled: Bool(false)},
+				{Name: String("www.example.com."), ObjectType: SearchObjectTypePtr(SearchObjectTypeComment), Zone: String("example.com."), ZoneID: String("example.com."), Type: RRTypePtr(RRTypeA), Content: String("web server")},
+			}
+			if max < len(resultsMock) {
+				resultsMock = resultsMock[:max]
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, resultsMock)
+		},
+	)
+}
+
+func TestSearchQueryHelpers(t *testing.T) {
+	if SearchPrefix("www") != "www*" || SearchSuffix("example.com.") != "*example.com." || SearchContains("10.1.") != "*10.1.*" {
+		t.Error("Invalid search query")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerSearchMockResponder()
+
+	p := initialisePowerDNSTestClient()
+	results, err := p.Search.Search(context.Background(), SearchContains("example"), 0, "")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if results.Truncated || len(results.Results) != 3 {
+		t.Fatalf("Invalid results: %+v", results)
+	}
+	if len(results.Zones()) != 1 || len(results.Records()) != 1 || len(results.Comments()) != 1 {
+		t.Errorf("Invalid results: %+v", results)
+	}
+	if record := results.Records()[0]; *record.Content != "10.1.2.3" || *record.Type != RRTypeA {
+		t.Errorf("Invalid record result: %+v", record)
+	}
+}
+
+func TestSearchTruncated(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerSearchMockResponder()
+
+	p := initialisePowerDNSTestClient()
+	results, err := p.Search.Search(context.Background(), "10.1.2.3", 2, SearchObjectTypeRecord)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !results.Truncated || len(results.Results) != 2 {
+		t.Errorf("Invalid results: %+v", results)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	p := initialisePowerDNSTestClient()
+	p.Port = "x"
+	if _, err := p.Search.Search(context.Background(), "foo", 0, SearchObjectTypeAll); err == nil {
+		t.Error("error is nil")
+	}
+}
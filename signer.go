@@ -0,0 +1,579 @@
+package powerdns
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSignatureValidity is the validity period of signatures created by a Signer
+const DefaultSignatureValidity = 14 * 24 * time.Hour
+
+// signatureBackdate is subtracted from the inception time to tolerate clock skew of validators
+const signatureBackdate = time.Hour
+
+// Signer signs zone data offline, for example in an air-gapped environment.
+// The result is uploaded into a presigned zone using ZonesService.UploadSigned.
+type Signer struct {
+	// Zone is the name of the signed zone
+	Zone string
+	// Keys signs the zone. The DNSKEY, CDS and CDNSKEY RRsets are signed by keys with the SEP flag (KSKs),
+	// all other RRsets by the other keys (ZSKs). If there are only keys of one kind, they sign everything.
+	Keys []*SigningKey
+	// Inception and Expiration define the validity period of the signatures
+	Inception  time.Time
+	Expiration time.Time
+	// NSEC3Param enables NSEC3, otherwise NSEC is used
+	NSEC3Param *NSEC3Param
+}
+
+// NewSigner creates a Signer whose signatures are valid for DefaultSignatureValidity, starting one hour ago
+func NewSigner(zone string, keys ...*SigningKey) *Signer {
+	now := timeNow()
+	return &Signer{
+		Zone:       zone,
+		Keys:       keys,
+		Inception:  now.Add(-signatureBackdate),
+		Expiration: now.Add(DefaultSignatureValidity),
+	}
+}
+
+// signerRRset is an RRset in canonical form
+type signerRRset struct {
+	name     string
+	wireName []byte
+	rrType   RRType
+	typeCode uint16
+	ttl      uint32
+	contents []string
+	rdata    [][]byte
+}
+
+func (r *signerRRset) add(content string, rdata []byte) {
+	for _, existing := range r.rdata {
+		if bytes.Equal(existing, rdata) {
+			return
+		}
+	}
+	r.contents = append(r.contents, content)
+	r.rdata = append(r.rdata, rdata)
+}
+
+// signerName collects the RRsets of an owner name
+type signerName struct {
+	name     string
+	wireName []byte
+	rrsets   map[uint16]*signerRRset
+	// delegation is set for names with NS records below the apex, occluded for names below a delegation
+	delegation bool
+	occluded   bool
+}
+
+// signerZone is the zone data prepared for signing
+type signerZone struct {
+	apex     string
+	apexWire []byte
+	names    map[string]*signerName
+	soa      *signerRRset
+	// negativeTTL is the TTL of NSEC and NSEC3 records (RFC 9077)
+	negativeTTL uint32
+}
+
+func (z *signerZone) rrset(name string, rrType RRType, ttl uint32) (*signerRRset, error) {
+	typeCode, err := rrType.Code()
+	if err != nil {
+		return nil, err
+	}
+
+	owner, ok := z.names[name]
+	if !ok {
+		wireName, err := PackCanonicalName(name)
+		if err != nil {
+			return nil, err
+		}
+		owner = &signerName{name: name, wireName: wireName, rrsets: make(map[uint16]*signerRRset)}
+		z.names[name] = owner
+	}
+
+	rrset, ok := owner.rrsets[typeCode]
+	if !ok {
+		rrset = &signerRRset{name: name, wireName: owner.wireName, rrType: rrType, typeCode: typeCode, ttl: ttl}
+		owner.rrsets[typeCode] = rrset
+	}
+	return rrset, nil
+}
+
+// Sign signs the RRsets of a zone. It returns the RRsets together with the DNSKEY RRset, the NSEC or NSEC3 chain and the RRSIG RRsets.
+// Existing DNSSEC records except for DNSKEY records are replaced, disabled records are ignored.
+func (s *Signer) Sign(rrsets []RRset) ([]RRset, error) {
+	if len(s.Keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	if !s.Expiration.After(s.Inception) {
+		return nil, errors.New("signature expiration is not after its inception")
+	}
+	if s.NSEC3Param != nil && s.NSEC3Param.HashAlgorithm != NSEC3HashSHA1 {
+		return nil, fmt.Errorf("unsupported NSEC3 hash algorithm %d", s.NSEC3Param.HashAlgorithm)
+	}
+	for _, key := range s.Keys {
+		if _, err := signatureHash(key.DNSKEY.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	zone, err := s.prepare(rrsets)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.NSEC3Param != nil {
+		err = s.addNSEC3Chain(zone)
+	} else {
+		err = s.addNSECChain(zone)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signZone(zone)
+}
+
+// prepare converts the RRsets into canonical form and adds the DNSKEY RRset
+func (s *Signer) prepare(rrsets []RRset) (*signerZone, error) {
+	zone, err := loadSignerZone(s.Zone, rrsets, false)
+	if err != nil {
+		return nil, err
+	}
+
+	dnskeyTTL := zone.soa.ttl
+	if existing := zone.names[zone.apex].rrsets[rrTypeCodes[RRTypeDNSKEY]]; existing != nil {
+		dnskeyTTL = existing.ttl
+	}
+	dnskeys, err := zone.rrset(zone.apex, RRTypeDNSKEY, dnskeyTTL)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range s.Keys {
+		dnskeys.add(key.DNSKEY.String(), key.DNSKEY.rdata())
+	}
+	return zone, nil
+}
+
+// loadSignerZone converts the RRsets of a zone into canonical form and classifies delegations and glue.
+// Disabled records are ignored, and so are RRSIG, NSEC, NSEC3 and NSEC3PARAM records unless dnssec is set.
+func loadSignerZone(zoneName string, rrsets []RRset, dnssec bool) (*signerZone, error) {
+	apex := strings.ToLower(makeDomainCanonical(idnToASCIIOrRaw(zoneName)))
+	apexWire, err := PackCanonicalName(apex)
+	if err != nil {
+		return nil, err
+	}
+	zone := &signerZone{apex: apex, apexWire: apexWire, names: make(map[string]*signerName)}
+
+	for _, rrset := range rrsets {
+		if rrset.Type == nil {
+			continue
+		}
+		switch *rrset.Type {
+		case RRTypeRRSIG, RRTypeNSEC, RRTypeNSEC3, RRTypeNSEC3PARAM:
+			if !dnssec {
+				continue
+			}
+		}
+
+		name := strings.ToLower(makeDomainCanonical(idnToASCIIOrRaw(StringValue(rrset.Name))))
+		if !isSubdomain(name, apex) {
+			return nil, fmt.Errorf("%w: %s is not part of zone %s", ErrNameOutsideZone, name, apex)
+		}
+
+		target, err := zone.rrset(name, *rrset.Type, Uint32Value(rrset.TTL))
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range rrset.Records {
+			if BoolValue(record.Disabled) {
+				continue
+			}
+			content := StringValue(record.Content)
+			rdata, err := PackCanonicalRDATA(*rrset.Type, content)
+			if err != nil {
+				return nil, err
+			}
+			target.add(content, rdata)
+		}
+	}
+
+	for name, owner := range zone.names {
+		for typeCode, rrset := range owner.rrsets {
+			if len(rrset.rdata) == 0 {
+				delete(owner.rrsets, typeCode)
+			}
+		}
+		if len(owner.rrsets) == 0 {
+			delete(zone.names, name)
+		}
+	}
+
+	if owner, ok := zone.names[apex]; ok {
+		zone.soa = owner.rrsets[rrTypeCodes[RRTypeSOA]]
+	}
+	if zone.soa == nil || len(zone.soa.rdata) != 1 {
+		return nil, fmt.Errorf("zone %s has no SOA record", apex)
+	}
+	// The SOA minimum is the last field of the SOA RDATA
+	soaRDATA := zone.soa.rdata[0]
+	minimum := uint32(soaRDATA[len(soaRDATA)-4])<<24 | uint32(soaRDATA[len(soaRDATA)-3])<<16 | uint32(soaRDATA[len(soaRDATA)-2])<<8 | uint32(soaRDATA[len(soaRDATA)-1])
+	zone.negativeTTL = minimum
+	if zone.soa.ttl < minimum {
+		zone.negativeTTL = zone.soa.ttl
+	}
+
+	for name, owner := range zone.names {
+		if name == apex {
+			continue
+		}
+		if _, ok := owner.rrsets[rrTypeCodes[RRTypeNS]]; ok {
+			owner.delegation = true
+		}
+		for parent := parentName(name); parent != apex && isSubdomain(parent, apex); parent = parentName(parent) {
+			if ancestor, ok := zone.names[parent]; ok && ancestor.rrsets[rrTypeCodes[RRTypeNS]] != nil {
+				owner.occluded = true
+				break
+			}
+		}
+	}
+	for _, owner := range zone.names {
+		if owner.occluded {
+			owner.delegation = false
+		}
+	}
+
+	return zone, nil
+}
+
+// sortedNames returns the authoritative names and delegation points of a zone in canonical order
+func (z *signerZone) sortedNames() []*signerName {
+	names := make([]*signerName, 0, len(z.names))
+	for _, owner := range z.names {
+		if !owner.occluded && len(owner.rrsets) > 0 {
+			names = append(names, owner)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return compareCanonicalNames(names[i].wireName, names[j].wireName) < 0 })
+	return names
+}
+
+// signed reports whether an RRset is signed: delegation NS RRsets and glue are not (RFC 4035, section 2.2)
+func (n *signerName) signed(typeCode uint16) bool {
+	if n.occluded {
+		return false
+	}
+	return !n.delegation || typeCode == rrTypeCodes[RRTypeDS] || typeCode == rrTypeCodes[RRTypeNSEC]
+}
+
+// onlyDNSSEC reports whether a name only owns NSEC3 and RRSIG records
+func (n *signerName) onlyDNSSEC() bool {
+	for typeCode := range n.rrsets {
+		if typeCode != rrTypeCodes[RRTypeNSEC3] && typeCode != rrTypeCodes[RRTypeRRSIG] {
+			return false
+		}
+	}
+	return true
+}
+
+// types returns the types present at a name, including the RRSIG type if any RRset is signed
+func (n *signerName) types() []uint16 {
+	types := make([]uint16, 0, len(n.rrsets)+1)
+	signed := false
+	for typeCode := range n.rrsets {
+		types = append(types, typeCode)
+		signed = signed || n.signed(typeCode)
+	}
+	if signed {
+		types = append(types, rrTypeCodes[RRTypeRRSIG])
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func typeMnemonics(types []uint16) string {
+	mnemonics := make([]string, 0, len(types))
+	for _, typeCode := range types {
+		mnemonics = append(mnemonics, string(RRTypeFromCode(typeCode)))
+	}
+	return strings.Join(mnemonics, " ")
+}
+
+// addNSECChain adds NSEC records to all authoritative names and delegation points (RFC 4034, section 4)
+func (s *Signer) addNSECChain(zone *signerZone) error {
+	names := zone.sortedNames()
+	// The NSEC RRsets are created first, so that their type is part of every type bit map
+	for _, owner := range names {
+		if _, err := zone.rrset(owner.name, RRTypeNSEC, zone.negativeTTL); err != nil {
+			return err
+		}
+	}
+
+	for i, owner := range names {
+		next := names[(i+1)%len(names)]
+		content := strings.TrimSpace(next.name + " " + typeMnemonics(owner.types()))
+		rdata, err := PackCanonicalRDATA(RRTypeNSEC, content)
+		if err != nil {
+			return err
+		}
+		owner.rrsets[rrTypeCodes[RRTypeNSEC]].add(content, rdata)
+	}
+	return nil
+}
+
+// nsec3Hash computes the hashed owner name of NSEC3 records (RFC 5155, section 5)
+func nsec3Hash(wireName []byte, param *NSEC3Param) []byte {
+	h := sha1.New()
+	h.Write(wireName)
+	h.Write(param.Salt)
+	hash := h.Sum(nil)
+	for i := uint16(0); i < param.Iterations; i++ {
+		h.Reset()
+		h.Write(hash)
+		h.Write(param.Salt)
+		hash = h.Sum(hash[:0])
+	}
+	return hash
+}
+
+// nsec3Names returns the names of the NSEC3 chain. Empty non-terminals are included with a nil value,
+// unsigned delegations are excluded if opt-out is enabled (RFC 5155, section 7.1).
+func (z *signerZone) nsec3Names(optOut bool) map[string]*signerName {
+	names := make(map[string]*signerName)
+	for _, owner := range z.sortedNames() {
+		if owner.delegation && optOut && owner.rrsets[rrTypeCodes[RRTypeDS]] == nil {
+			continue
+		}
+		if owner.onlyDNSSEC() {
+			// Owner names of NSEC3 records are not part of the chain themselves
+			continue
+		}
+		names[owner.name] = owner
+		for parent := parentName(owner.name); parent != z.apex && isSubdomain(parent, z.apex); parent = parentName(parent) {
+			if _, ok := names[parent]; ok {
+				break
+			}
+			if ancestor, ok := z.names[parent]; ok && len(ancestor.rrsets) > 0 {
+				continue
+			}
+			names[parent] = nil
+		}
+	}
+	return names
+}
+
+// addNSEC3Chain adds the NSEC3PARAM record and the NSEC3 chain (RFC 5155, section 7.1).
+// Empty non-terminals are part of the chain, unsigned delegations are excluded if opt-out is enabled.
+func (s *Signer) addNSEC3Chain(zone *signerZone) error {
+	param := s.NSEC3Param
+	paramContent := (&NSEC3Param{HashAlgorithm: param.HashAlgorithm, Iterations: param.Iterations, Salt: param.Salt}).String()
+	rdata, err := PackCanonicalRDATA(RRTypeNSEC3PARAM, paramContent)
+	if err != nil {
+		return err
+	}
+	nsec3Param, err := zone.rrset(zone.apex, RRTypeNSEC3PARAM, 0)
+	if err != nil {
+		return err
+	}
+	nsec3Param.add(paramContent, rdata)
+
+	type hashedName struct {
+		hash  []byte
+		types []uint16
+	}
+	chain := make(map[string]*hashedName)
+	for name, owner := range zone.nsec3Names(param.OptOut()) {
+		entry := &hashedName{types: []uint16{}}
+		if owner != nil {
+			entry.types = owner.types()
+		}
+		chain[name] = entry
+	}
+
+	hashed := make([]*hashedName, 0, len(chain))
+	for name, entry := range chain {
+		wireName, err := PackCanonicalName(name)
+		if err != nil {
+			return err
+		}
+		entry.hash = nsec3Hash(wireName, param)
+		hashed = append(hashed, entry)
+	}
+	sort.Slice(hashed, func(i, j int) bool { return bytes.Compare(hashed[i].hash, hashed[j].hash) < 0 })
+
+	salt := "-"
+	if len(param.Salt) > 0 {
+		salt = strings.Fields(param.String())[3]
+	}
+	for i, entry := range hashed {
+		if i > 0 && bytes.Equal(hashed[i-1].hash, entry.hash) {
+			return errors.New("NSEC3 hash collision, use a different salt")
+		}
+		next := hashed[(i+1)%len(hashed)]
+		content := strings.TrimSpace(fmt.Sprintf("%d %d %d %s %s %s", param.HashAlgorithm, param.Flags&NSEC3FlagOptOut, param.Iterations, salt,
+			strings.ToLower(nsec3Encoding.EncodeToString(next.hash)), typeMnemonics(entry.types)))
+		rdata, err := PackCanonicalRDATA(RRTypeNSEC3, content)
+		if err != nil {
+			return err
+		}
+
+		owner := strings.ToLower(nsec3Encoding.EncodeToString(entry.hash)) + "." + zone.apex
+		nsec3, err := zone.rrset(owner, RRTypeNSEC3, zone.negativeTTL)
+		if err != nil {
+			return err
+		}
+		nsec3.add(content, rdata)
+	}
+	return nil
+}
+
+// signZone signs all authoritative RRsets and returns the zone data including the RRSIG RRsets
+func (s *Signer) signZone(zone *signerZone) ([]RRset, error) {
+	ksks := make([]*SigningKey, 0, len(s.Keys))
+	zsks := make([]*SigningKey, 0, len(s.Keys))
+	for _, key := range s.Keys {
+		if key.DNSKEY.IsSEP() {
+			ksks = append(ksks, key)
+		} else {
+			zsks = append(zsks, key)
+		}
+	}
+	if len(ksks) == 0 {
+		ksks = zsks
+	}
+	if len(zsks) == 0 {
+		zsks = ksks
+	}
+
+	owners := make([]*signerName, 0, len(zone.names))
+	for _, owner := range zone.names {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return compareCanonicalNames(owners[i].wireName, owners[j].wireName) < 0 })
+
+	signed := make([]RRset, 0)
+	for _, owner := range owners {
+		typeCodes := make([]uint16, 0, len(owner.rrsets))
+		for typeCode := range owner.rrsets {
+			typeCodes = append(typeCodes, typeCode)
+		}
+		sort.Slice(typeCodes, func(i, j int) bool { return typeCodes[i] < typeCodes[j] })
+
+		var signatures *RRset
+		for _, typeCode := range typeCodes {
+			rrset := owner.rrsets[typeCode]
+			signed = append(signed, rrset.toRRset())
+			if !owner.signed(typeCode) {
+				continue
+			}
+
+			keys := zsks
+			switch rrset.rrType {
+			case RRTypeDNSKEY, RRTypeCDS, RRTypeCDNSKEY:
+				keys = ksks
+			}
+			for _, key := range keys {
+				content, err := s.rrsig(zone, rrset, key)
+				if err != nil {
+					return nil, err
+				}
+				if signatures == nil {
+					signatures = &RRset{Name: String(owner.name), Type: RRTypePtr(RRTypeRRSIG), TTL: Uint32(rrset.ttl)}
+				}
+				if rrset.ttl < *signatures.TTL {
+					signatures.TTL = Uint32(rrset.ttl)
+				}
+				signatures.Records = append(signatures.Records, Record{Content: String(content), Disabled: Bool(false)})
+			}
+		}
+		if signatures != nil {
+			signed = append(signed, *signatures)
+		}
+	}
+	return signed, nil
+}
+
+func (r *signerRRset) toRRset() RRset {
+	rrset := RRset{Name: String(r.name), Type: RRTypePtr(r.rrType), TTL: Uint32(r.ttl), Records: make([]Record, 0, len(r.contents))}
+	for _, content := range r.contents {
+		rrset.Records = append(rrset.Records, Record{Content: String(content), Disabled: Bool(false)})
+	}
+	return rrset
+}
+
+// signatureLabels returns the labels field of an RRSIG record, which does not count a leading wildcard label (RFC 4034, section 3.1.3)
+func signatureLabels(wireName []byte) uint8 {
+	labels := nameLabels(wireName)
+	if len(labels) > 0 && string(labels[0]) == "*" {
+		return uint8(len(labels) - 1)
+	}
+	return uint8(len(labels))
+}
+
+// rrsig creates the content of an RRSIG record (RFC 4034, section 3.1.8.1)
+func (s *Signer) rrsig(zone *signerZone, rrset *signerRRset, key *SigningKey) (string, error) {
+	labels := signatureLabels(rrset.wireName)
+	expiration := uint32(s.Expiration.Unix())
+	inception := uint32(s.Inception.Unix())
+	keyTag := key.DNSKEY.KeyTag()
+
+	data := appendUint16(nil, rrset.typeCode)
+	data = append(data, uint8(key.DNSKEY.Algorithm), labels)
+	data = appendUint32(data, rrset.ttl)
+	data = appendUint32(data, expiration)
+	data = appendUint32(data, inception)
+	data = appendUint16(data, keyTag)
+	data = append(data, zone.apexWire...)
+	data = appendCanonicalRRset(data, rrset.wireName, rrset.typeCode, rrset.ttl, rrset.rdata)
+
+	signature, err := key.sign(data)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s %d %d %d %s %s %d %s %s", rrset.rrType, key.DNSKEY.Algorithm, labels, rrset.ttl,
+		formatSignatureTime(s.Expiration), formatSignatureTime(s.Inception), keyTag, zone.apex,
+		base64.StdEncoding.EncodeToString(signature)), nil
+}
+
+// UploadSigned replaces the content of a presigned zone with RRsets signed by a Signer.
+// RRsets of the zone which are not part of the signed data are removed within the same request.
+func (z *ZonesService) UploadSigned(ctx context.Context, domain string, rrsets []RRset) error {
+	zone, err := z.get(ctx, domain)
+	if err != nil {
+		return err
+	}
+	if !BoolValue(zone.Presigned) {
+		return fmt.Errorf("zone %s is not presigned", StringValue(zone.Name))
+	}
+
+	key := func(rrset *RRset) string {
+		return strings.ToLower(makeDomainCanonical(idnToASCIIOrRaw(StringValue(rrset.Name)))) + " " + strings.ToUpper(string(*rrset.Type))
+	}
+
+	patch := make([]RRset, 0, len(rrsets)+len(zone.RRsets))
+	uploaded := make(map[string]bool, len(rrsets))
+	for i := range rrsets {
+		content := rrsetContents(&rrsets[i])
+		patch = append(patch, delegationRRSet(StringValue(rrsets[i].Name), *rrsets[i].Type, Uint32Value(rrsets[i].TTL), content))
+		uploaded[key(&rrsets[i])] = true
+	}
+	for i := range zone.RRsets {
+		if zone.RRsets[i].Type == nil || uploaded[key(&zone.RRsets[i])] {
+			continue
+		}
+		patch = append(patch, delegationRRSet(StringValue(zone.RRsets[i].Name), *zone.RRsets[i].Type, 0, nil))
+	}
+
+	return z.client.Records.Patch(ctx, domain, &RRsets{Sets: patch})
+}
@@ -0,0 +1,381 @@
+package powerdns
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func generateTestSigningKeys(t *testing.T) (*SigningKey, *SigningKey) {
+	t.Helper()
+	ksk, err := ParseBINDPrivateKey(257, []byte(testBINDPrivateKeyED25519))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	zsk, err := NewSigningKey(256, AlgorithmED25519, private)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return ksk, zsk
+}
+
+func generateTestSignerRRsets() []RRset {
+	rrset := func(name string, recordType RRType, content ...string) RRset {
+		records := make([]Record, 0, len(content))
+		for _, c := range content {
+			records = append(records, Record{Content: String(c)})
+		}
+		return RRset{Name: String(name), Type: RRTypePtr(recordType), TTL: Uint32(3600), Records: records}
+	}
+	return []RRset{
+		rrset("example.com.", RRTypeSOA, "ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300"),
+		rrset("example.com.", RRTypeNS, "ns1.example.com."),
+		rrset("example.com.", RRTypeMX, "10 mail.example.com."),
+		rrset("ns1.example.com.", RRTypeA, "192.0.2.1"),
+		rrset("www.example.com.", RRTypeA, "192.0.2.2", "192.0.2.3"),
+		rrset("host.deep.example.com.", RRTypeTXT, `"empty non-terminal above"`),
+		rrset("secure.example.com.", RRTypeNS, "ns.secure.example.com."),
+		rrset("secure.example.com.", RRTypeDS, "3613 15 2 3aa5ab37efce57f737fc1627013fee07bdf241bd10f3b1964ab55c78e79a304b"),
+		rrset("ns.secure.example.com.", RRTypeA, "192.0.2.4"),
+		rrset("insecure.example.com.", RRTypeNS, "ns.other.net."),
+		rrset("disabled.example.com.", RRTypeA, "192.0.2.5"),
+		{Name: String("old.example.com."), Type: RRTypePtr(RRTypeRRSIG), TTL: Uint32(3600), Records: []Record{{Content: String("A 15 3 3600 20200101000000 20191201000000 1 example.com. AA==")}}},
+	}
+}
+
+// verifyTestSignatures checks every RRSIG record of signed zone data and returns the signed RRsets as "name type"
+func verifyTestSignatures(t *testing.T, zone string, rrsets []RRset, keys ...*SigningKey) []string {
+	t.Helper()
+	covered := make([]string, 0)
+	for _, rrsig := range rrsets {
+		if *rrsig.Type != RRTypeRRSIG {
+			continue
+		}
+		for _, record := range rrsig.Records {
+			fields := strings.Fields(*record.Content)
+			rrset := findRRSet(rrsets, *rrsig.Name, RRType(fields[0]))
+			if rrset == nil {
+				t.Fatalf("RRSIG %s covers a missing RRset", *record.Content)
+			}
+
+			var key *SigningKey
+			for _, k := range keys {
+				if k.DNSKEY.KeyTag() == parseTestUint16(t, fields[6]) {
+					key = k
+				}
+			}
+			if key == nil {
+				t.Fatalf("RRSIG %s uses an unknown key", *record.Content)
+			}
+
+			rdata, err := PackCanonicalRDATA(RRTypeRRSIG, *record.Content)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			signature, _ := base64.StdEncoding.DecodeString(fields[8])
+			signerWire, _ := PackCanonicalName(zone)
+			data := rdata[:18+len(signerWire)]
+
+			wireName, _ := PackCanonicalName(strings.ToLower(*rrset.Name))
+			typeCode, _ := rrset.Type.Code()
+			contents := make([][]byte, 0)
+			for _, r := range rrset.Records {
+				c, err := PackCanonicalRDATA(*rrset.Type, *r.Content)
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+				contents = append(contents, c)
+			}
+			data = appendCanonicalRRset(append([]byte(nil), data...), wireName, typeCode, *rrset.TTL, contents)
+
+			if !ed25519.Verify(ed25519.PublicKey(key.DNSKEY.PublicKey), data, signature) {
+				t.Errorf("Invalid signature %s", *record.Content)
+			}
+			covered = append(covered, strings.ToLower(*rrsig.Name)+" "+fields[0])
+		}
+	}
+	sort.Strings(covered)
+	return covered
+}
+
+func parseTestUint16(t *testing.T, s string) uint16 {
+	t.Helper()
+	rdata, err := packField(nil, fieldUint16, rdataToken{Value: s}, true)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return uint16(rdata[0])<<8 | uint16(rdata[1])
+}
+
+func contentsOf(rrsets []RRset, name string, recordType RRType) []string {
+	return rrsetContents(findRRSet(rrsets, name, recordType))
+}
+
+func TestSignerRRSIG(t *testing.T) {
+	// RFC 8080, section 6.1
+	key, err := ParseBINDPrivateKey(257, []byte(testBINDPrivateKeyED25519))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	signer := &Signer{Zone: "example.com", Keys: []*SigningKey{key}, Inception: time.Unix(1438207200, 0), Expiration: time.Unix(1440021600, 0)}
+
+	zone, err := signer.prepare([]RRset{
+		{Name: String("example.com."), Type: RRTypePtr(RRTypeSOA), TTL: Uint32(3600), Records: []Record{{Content: String("ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600")}}},
+		{Name: String("example.com."), Type: RRTypePtr(RRTypeMX), TTL: Uint32(3600), Records: []Record{{Content: String("10 mail.example.com.")}}},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	content, err := signer.rrsig(zone, zone.names["example.com."].rrsets[15], key)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	want := "MX 15 2 3600 20150819220000 20150729220000 3613 example.com. oL9krJun7xfBOIWcGHi7mag5/hdZrKWw15jPGrHpjQeRAvTdszaPD+QLs3fx8A4M3e23mRZ9VrbpMngwcrqNAg=="
+	if content != want {
+		t.Errorf("Invalid RRSIG:\n got %s\nwant %s", content, want)
+	}
+}
+
+func TestSignerNSEC(t *testing.T) {
+	ksk, zsk := generateTestSigningKeys(t)
+	input := generateTestSignerRRsets()
+	input[10].Records[0].Disabled = Bool(true)
+
+	signer := NewSigner("example.com", ksk, zsk)
+	signed, err := signer.Sign(input)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if dnskeys := contentsOf(signed, "example.com.", RRTypeDNSKEY); len(dnskeys) != 2 {
+		t.Errorf("Invalid DNSKEY RRset: %v", dnskeys)
+	}
+	if findRRSet(signed, "disabled.example.com.", RRTypeA) != nil || findRRSet(signed, "old.example.com.", RRTypeRRSIG) != nil {
+		t.Error("Disabled records and old signatures must be removed")
+	}
+
+	nsec := map[string]string{
+		"example.com.":           "host.deep.example.com. NS SOA MX RRSIG NSEC DNSKEY",
+		"host.deep.example.com.": "insecure.example.com. TXT RRSIG NSEC",
+		"insecure.example.com.":  "ns1.example.com. NS RRSIG NSEC",
+		"ns1.example.com.":       "secure.example.com. A RRSIG NSEC",
+		"secure.example.com.":    "www.example.com. NS DS RRSIG NSEC",
+		"www.example.com.":       "example.com. A RRSIG NSEC",
+	}
+	for name, want := range nsec {
+		if got := contentsOf(signed, name, RRTypeNSEC); len(got) != 1 || got[0] != want {
+			t.Errorf("Invalid NSEC of %s: %v, want %s", name, got, want)
+		}
+	}
+	if findRRSet(signed, "ns.secure.example.com.", RRTypeNSEC) != nil {
+		t.Error("Glue must not be part of the NSEC chain")
+	}
+
+	covered := verifyTestSignatures(t, "example.com.", signed, ksk, zsk)
+	want := []string{
+		"example.com. DNSKEY", "example.com. MX", "example.com. NS", "example.com. NSEC", "example.com. SOA",
+		"host.deep.example.com. NSEC", "host.deep.example.com. TXT",
+		"insecure.example.com. NSEC",
+		"ns1.example.com. A", "ns1.example.com. NSEC",
+		"secure.example.com. DS", "secure.example.com. NSEC",
+		"www.example.com. A", "www.example.com. NSEC",
+	}
+	if strings.Join(covered, ",") != strings.Join(want, ",") {
+		t.Errorf("Invalid signed RRsets:\n got %v\nwant %v", covered, want)
+	}
+
+	// The DNSKEY RRset is signed by the KSK only, everything else by the ZSK only
+	for _, record := range findRRSet(signed, "example.com.", RRTypeRRSIG).Records {
+		fields := strings.Fields(*record.Content)
+		isKSK := parseTestUint16(t, fields[6]) == ksk.DNSKEY.KeyTag()
+		if (fields[0] == "DNSKEY") != isKSK {
+			t.Errorf("RRSIG %s uses the wrong key", *record.Content)
+		}
+	}
+}
+
+func TestSignerNSEC3(t *testing.T) {
+	ksk, zsk := generateTestSigningKeys(t)
+	param := &NSEC3Param{HashAlgorithm: NSEC3HashSHA1, Flags: NSEC3FlagOptOut}
+
+	signer := NewSigner("example.com.", ksk, zsk)
+	signer.NSEC3Param = param
+	signed, err := signer.Sign(generateTestSignerRRsets())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if got := contentsOf(signed, "example.com.", RRTypeNSEC3PARAM); len(got) != 1 || got[0] != "1 0 0 -" {
+		t.Errorf("Invalid NSEC3PARAM: %v", got)
+	}
+
+	hashed := func(name string) string {
+		wire, _ := PackCanonicalName(name)
+		return strings.ToLower(nsec3Encoding.EncodeToString(nsec3Hash(wire, param))) + ".example.com."
+	}
+	types := map[string]string{
+		"example.com.":           "NS SOA MX RRSIG DNSKEY NSEC3PARAM",
+		"deep.example.com.":      "",
+		"disabled.example.com.":  "A RRSIG",
+		"host.deep.example.com.": "TXT RRSIG",
+		"ns1.example.com.":       "A RRSIG",
+		"secure.example.com.":    "NS DS RRSIG",
+		"www.example.com.":       "A RRSIG",
+	}
+	count := 0
+	for _, rrset := range signed {
+		if *rrset.Type == RRTypeNSEC3 {
+			count++
+		}
+	}
+	if count != len(types) {
+		t.Errorf("Invalid NSEC3 chain length %d", count)
+	}
+	for name, want := range types {
+		got := contentsOf(signed, hashed(name), RRTypeNSEC3)
+		if len(got) != 1 || !strings.HasPrefix(got[0], "1 1 0 - ") || strings.TrimSpace(strings.Join(strings.Fields(got[0])[5:], " ")) != want {
+			t.Errorf("Invalid NSEC3 of %s: %v, want types %q", name, got, want)
+		}
+	}
+	if findRRSet(signed, hashed("insecure.example.com."), RRTypeNSEC3) != nil {
+		t.Error("Unsigned delegations must be opted out")
+	}
+	if findRRSet(signed, "example.com.", RRTypeNSEC) != nil {
+		t.Error("NSEC records must not be generated for NSEC3 zones")
+	}
+
+	verifyTestSignatures(t, "example.com.", signed, ksk, zsk)
+}
+
+func TestNSEC3Hash(t *testing.T) {
+	// RFC 5155, appendix A
+	param := &NSEC3Param{HashAlgorithm: NSEC3HashSHA1, Iterations: 12, Salt: []byte{0xaa, 0xbb, 0xcc, 0xdd}}
+	testCases := map[string]string{
+		"example.":   "0p9mhaveqvm6t7vbl5lop2u3t2rp3tom",
+		"a.example.": "35mthgpgcu1qg68fab165klnsnk3dpvl",
+	}
+	for name, want := range testCases {
+		wire, _ := PackCanonicalName(name)
+		if got := strings.ToLower(nsec3Encoding.EncodeToString(nsec3Hash(wire, param))); got != want {
+			t.Errorf("Invalid NSEC3 hash of %s: %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestSignerInvalid(t *testing.T) {
+	ksk, _ := generateTestSigningKeys(t)
+	input := generateTestSignerRRsets()
+
+	if _, err := NewSigner("example.com.").Sign(input); err == nil {
+		t.Error("Expected error without keys")
+	}
+	if _, err := NewSigner("example.com.", ksk).Sign(input[1:]); err == nil {
+		t.Error("Expected error without SOA record")
+	}
+	if _, err := NewSigner("example.org.", ksk).Sign(input); err == nil {
+		t.Error("Expected error for names outside of the zone")
+	}
+	signer := NewSigner("example.com.", ksk)
+	signer.Expiration = signer.Inception
+	if _, err := signer.Sign(input); err == nil {
+		t.Error("Expected error for invalid validity period")
+	}
+}
+
+func registerUploadSignedMockResponder(testDomain string, presigned bool, patches *[]RRsets) {
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			zoneMock := Zone{
+				Name:      String(testDomain + "."),
+				Presigned: Bool(presigned),
+				RRsets: []RRset{
+					{Name: String(testDomain + "."), Type: RRTypePtr(RRTypeSOA), TTL: Uint32(3600), Records: []Record{{Content: String("ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300")}}},
+					{Name: String("stale." + testDomain + "."), Type: RRTypePtr(RRTypeA), TTL: Uint32(3600), Records: []Record{{Content: String("192.0.2.9")}}},
+				},
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, zoneMock)
+		},
+	)
+
+	httpmock.RegisterResponder("PATCH", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var rrsets RRsets
+			if json.NewDecoder(req.Body).Decode(&rrsets) != nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+			*patches = append(*patches, rrsets)
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+}
+
+func TestUploadSigned(t *testing.T) {
+	testDomain := generateTestZone(false)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	patches := make([]RRsets, 0)
+	registerUploadSignedMockResponder(testDomain, true, &patches)
+
+	ksk, zsk := generateTestSigningKeys(t)
+	signed, err := NewSigner(testDomain, ksk, zsk).Sign([]RRset{
+		{Name: String(testDomain + "."), Type: RRTypePtr(RRTypeSOA), TTL: Uint32(3600), Records: []Record{{Content: String("ns1.example.com. hostmaster.example.com. 2 7200 3600 1209600 300")}}},
+		{Name: String("www." + testDomain + "."), Type: RRTypePtr(RRTypeA), TTL: Uint32(3600), Records: []Record{{Content: String("192.0.2.1")}}},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	p := initialisePowerDNSTestClient()
+	if err := p.Zones.UploadSigned(context.Background(), testDomain, signed); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(patches) != 1 || len(patches[0].Sets) != len(signed)+1 {
+		t.Fatalf("Invalid patches: %+v", patches)
+	}
+	for _, rrset := range patches[0].Sets {
+		stale := *rrset.Name == "stale."+testDomain+"."
+		if stale != (*rrset.ChangeType == ChangeTypeDelete) {
+			t.Errorf("Invalid change %s of %s %s", *rrset.ChangeType, *rrset.Name, *rrset.Type)
+		}
+	}
+}
+
+func TestUploadSignedNotPresigned(t *testing.T) {
+	testDomain := generateTestZone(false)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	patches := make([]RRsets, 0)
+	registerUploadSignedMockResponder(testDomain, false, &patches)
+
+	p := initialisePowerDNSTestClient()
+	if err := p.Zones.UploadSigned(context.Background(), testDomain, nil); err == nil {
+		t.Error("Expected error for zones which are not presigned")
+	}
+	if len(patches) != 0 {
+		t.Errorf("Zone has been patched: %+v", patches)
+	}
+}
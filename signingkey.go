@@ -0,0 +1,268 @@
+package powerdns
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SigningKey is a DNSSEC key including its private key, as used for offline signing
+type SigningKey struct {
+	DNSKEY *DNSKEY
+	signer crypto.Signer
+}
+
+// NewSigningKey creates a SigningKey from a private key.
+// RSA keys are supported for RSASHA1, RSASHA1-NSEC3-SHA1, RSASHA256 and RSASHA512, ECDSA keys for ECDSAP256SHA256 and ECDSAP384SHA384, and Ed25519 keys for ED25519.
+func NewSigningKey(flags uint16, algorithm DNSSECAlgorithm, privateKey crypto.Signer) (*SigningKey, error) {
+	publicKey, err := dnskeyPublicKey(algorithm, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		DNSKEY: &DNSKEY{Flags: flags, Protocol: 3, Algorithm: algorithm, PublicKey: publicKey},
+		signer: privateKey,
+	}, nil
+}
+
+// dnskeyPublicKey returns the public key field of a DNSKEY (RFC 3110, RFC 6605 and RFC 8080)
+func dnskeyPublicKey(algorithm DNSSECAlgorithm, privateKey crypto.Signer) ([]byte, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		switch algorithm {
+		case AlgorithmRSASHA1, AlgorithmRSASHA1NSEC3SHA1, AlgorithmRSASHA256, AlgorithmRSASHA512:
+		default:
+			return nil, fmt.Errorf("RSA key cannot be used with algorithm %s", algorithm)
+		}
+		exponent := big.NewInt(int64(key.E)).Bytes()
+		publicKey := make([]byte, 0, 3+len(exponent)+key.Size())
+		if len(exponent) > 255 {
+			publicKey = append(publicKey, 0)
+			publicKey = appendUint16(publicKey, uint16(len(exponent)))
+		} else {
+			publicKey = append(publicKey, uint8(len(exponent)))
+		}
+		publicKey = append(publicKey, exponent...)
+		return append(publicKey, key.N.Bytes()...), nil
+	case *ecdsa.PrivateKey:
+		size := ecdsaKeySize(algorithm)
+		if size == 0 || key.Curve.Params().BitSize != size*8 {
+			return nil, fmt.Errorf("ECDSA key with curve %s cannot be used with algorithm %s", key.Curve.Params().Name, algorithm)
+		}
+		publicKey := make([]byte, 2*size)
+		key.X.FillBytes(publicKey[:size])
+		key.Y.FillBytes(publicKey[size:])
+		return publicKey, nil
+	case ed25519.PrivateKey:
+		if algorithm != AlgorithmED25519 {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with algorithm %s", algorithm)
+		}
+		return append([]byte(nil), key.Public().(ed25519.PublicKey)...), nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+}
+
+// ecdsaKeySize returns the size of an ECDSA coordinate in octets, or 0 if the algorithm does not use ECDSA
+func ecdsaKeySize(algorithm DNSSECAlgorithm) int {
+	switch algorithm {
+	case AlgorithmECDSAP256SHA256:
+		return 32
+	case AlgorithmECDSAP384SHA384:
+		return 48
+	}
+	return 0
+}
+
+// signatureHash returns the hash function of a signing algorithm. Ed25519 signs the data itself.
+func signatureHash(algorithm DNSSECAlgorithm) (crypto.Hash, error) {
+	switch algorithm {
+	case AlgorithmRSASHA1, AlgorithmRSASHA1NSEC3SHA1:
+		return crypto.SHA1, nil
+	case AlgorithmRSASHA256, AlgorithmECDSAP256SHA256:
+		return crypto.SHA256, nil
+	case AlgorithmECDSAP384SHA384:
+		return crypto.SHA384, nil
+	case AlgorithmRSASHA512:
+		return crypto.SHA512, nil
+	case AlgorithmED25519:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+}
+
+// sign returns the signature field of an RRSIG record for the given data (RFC 3110, RFC 6605 and RFC 8080)
+func (k *SigningKey) sign(data []byte) ([]byte, error) {
+	hash, err := signatureHash(k.DNSKEY.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if hash == 0 {
+		return k.signer.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	if key, ok := k.signer.(*ecdsa.PrivateKey); ok {
+		// DNSSEC uses the concatenation of r and s instead of the ASN.1 encoding
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := ecdsaKeySize(k.DNSKEY.Algorithm)
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	}
+	return k.signer.Sign(rand.Reader, digest, hash)
+}
+
+// ParseBINDPrivateKey parses a private key file in the format written by BIND's dnssec-keygen ("Private-key-format: v1.3").
+// PowerDNS exports private keys of cryptokeys in the same format. The flags are not part of the file, use 257 for KSKs and 256 for ZSKs.
+func ParseBINDPrivateKey(flags uint16, data []byte) (*SigningKey, error) {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q in private key file", line)
+		}
+		fields[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(fields["private-key-format"], "v1.") {
+		return nil, fmt.Errorf("unsupported private key format %q", fields["private-key-format"])
+	}
+	algorithmField := strings.Fields(fields["algorithm"])
+	if len(algorithmField) == 0 {
+		return nil, errors.New("private key file has no algorithm")
+	}
+	algorithm, err := ParseDNSSECAlgorithm(algorithmField[0])
+	if err != nil {
+		return nil, err
+	}
+
+	decode := func(name string) ([]byte, error) {
+		value, ok := fields[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("private key file has no %s", name)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in private key file", name)
+		}
+		return decoded, nil
+	}
+
+	var privateKey crypto.Signer
+	switch algorithm {
+	case AlgorithmRSASHA1, AlgorithmRSASHA1NSEC3SHA1, AlgorithmRSASHA256, AlgorithmRSASHA512:
+		values := make(map[string]*big.Int)
+		for _, name := range []string{"Modulus", "PublicExponent", "PrivateExponent", "Prime1", "Prime2"} {
+			decoded, err := decode(name)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = new(big.Int).SetBytes(decoded)
+		}
+		if !values["PublicExponent"].IsInt64() || values["PublicExponent"].Int64() > 1<<31-1 {
+			return nil, errors.New("RSA public exponent is too large")
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: values["Modulus"], E: int(values["PublicExponent"].Int64())},
+			D:         values["PrivateExponent"],
+			Primes:    []*big.Int{values["Prime1"], values["Prime2"]},
+		}
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %w", err)
+		}
+		key.Precompute()
+		privateKey = key
+	case AlgorithmECDSAP256SHA256, AlgorithmECDSAP384SHA384:
+		decoded, err := decode("PrivateKey")
+		if err != nil {
+			return nil, err
+		}
+		curve := elliptic.P256()
+		if algorithm == AlgorithmECDSAP384SHA384 {
+			curve = elliptic.P384()
+		}
+		d := new(big.Int).SetBytes(decoded)
+		if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+			return nil, errors.New("invalid ECDSA private key")
+		}
+		key := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: d}
+		key.X, key.Y = curve.ScalarBaseMult(decoded)
+		privateKey = key
+	case AlgorithmED25519:
+		decoded, err := decode("PrivateKey")
+		if err != nil {
+			return nil, err
+		}
+		if len(decoded) != ed25519.SeedSize {
+			return nil, errors.New("invalid Ed25519 private key")
+		}
+		privateKey = ed25519.NewKeyFromSeed(decoded)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+	}
+
+	return NewSigningKey(flags, algorithm, privateKey)
+}
+
+// ParseBINDKey parses a key pair written by BIND's dnssec-keygen: the public key file (K<zone>+<alg>+<tag>.key),
+// which contains the DNSKEY record, and the private key file (K<zone>+<alg>+<tag>.private)
+func ParseBINDKey(publicKey []byte, privateKey []byte) (*SigningKey, error) {
+	var dnskey *DNSKEY
+	scanner := bufio.NewScanner(bytes.NewReader(publicKey))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if strings.EqualFold(field, string(RRTypeDNSKEY)) {
+				var err error
+				if dnskey, err = ParseDNSKEY(strings.Join(fields[i+1:], " ")); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if dnskey == nil {
+		return nil, errors.New("public key file contains no DNSKEY record")
+	}
+
+	key, err := ParseBINDPrivateKey(dnskey.Flags, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if key.DNSKEY.Algorithm != dnskey.Algorithm || !bytes.Equal(key.DNSKEY.PublicKey, dnskey.PublicKey) {
+		return nil, errors.New("private key does not match the public key")
+	}
+	return key, nil
+}
@@ -0,0 +1,119 @@
+package powerdns
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// RFC 6605, section 6.1
+const (
+	testBINDPrivateKeyECDSAP256 = `Private-key-format: v1.2
+Algorithm: 13 (ECDSAP256SHA256)
+PrivateKey: GU6SnQ/Ou+xC5RumuIUIuJZteXT2z0O/ok1s38Et6mQ=
+`
+	testBINDPublicKeyECDSAP256 = `; This is a key-signing key, keyid 55648, for example.net.
+example.net. 3600 IN DNSKEY 257 3 13 GojIhhXUN/u4v54ZQqGSnyhWJwaubCvTmeexv7bR6edbkrSqQpF64cYbcB7wNcP+e+MAnLr+Wi9xMWyQLc8NAA==
+`
+)
+
+// RFC 8080, section 6.1
+const (
+	testBINDPrivateKeyED25519 = `Private-key-format: v1.2
+Algorithm: 15 (ED25519)
+PrivateKey: ODIyNjAzODQ2MjgwODAxMjI2NDUxOTAyMDQxNDIyNjI=
+`
+	testDNSKEYED25519 = "257 3 15 l02Woi0iS8Aa25FQkUd9RMzZHJpBoRQwAQEX1SxZJA4="
+)
+
+func TestParseBINDPrivateKeyECDSA(t *testing.T) {
+	key, err := ParseBINDPrivateKey(257, []byte(testBINDPrivateKeyECDSAP256))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if key.DNSKEY.String() != "257 3 13 GojIhhXUN/u4v54ZQqGSnyhWJwaubCvTmeexv7bR6edbkrSqQpF64cYbcB7wNcP+e+MAnLr+Wi9xMWyQLc8NAA==" {
+		t.Errorf("Invalid DNSKEY: %s", key.DNSKEY)
+	}
+	if key.DNSKEY.KeyTag() != 55648 {
+		t.Errorf("Invalid key tag %d", key.DNSKEY.KeyTag())
+	}
+}
+
+func TestParseBINDPrivateKeyED25519(t *testing.T) {
+	key, err := ParseBINDPrivateKey(257, []byte(testBINDPrivateKeyED25519))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if key.DNSKEY.String() != testDNSKEYED25519 {
+		t.Errorf("Invalid DNSKEY: %s", key.DNSKEY)
+	}
+	if key.DNSKEY.KeyTag() != 3613 {
+		t.Errorf("Invalid key tag %d", key.DNSKEY.KeyTag())
+	}
+}
+
+func TestParseBINDPrivateKeyRSA(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	encode := func(n *big.Int) string {
+		return base64.StdEncoding.EncodeToString(n.Bytes())
+	}
+	data := fmt.Sprintf("Private-key-format: v1.3\nAlgorithm: 8 (RSASHA256)\nModulus: %s\nPublicExponent: %s\nPrivateExponent: %s\nPrime1: %s\nPrime2: %s\nExponent1: %s\nExponent2: %s\nCoefficient: %s\n",
+		encode(rsaKey.N), encode(big.NewInt(int64(rsaKey.E))), encode(rsaKey.D), encode(rsaKey.Primes[0]), encode(rsaKey.Primes[1]),
+		encode(rsaKey.Precomputed.Dp), encode(rsaKey.Precomputed.Dq), encode(rsaKey.Precomputed.Qinv))
+
+	key, err := ParseBINDPrivateKey(256, []byte(data))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if key.DNSKEY.Algorithm != AlgorithmRSASHA256 || key.DNSKEY.IsSEP() {
+		t.Errorf("Invalid DNSKEY: %s", key.DNSKEY)
+	}
+	// RFC 3110, section 2: exponent length, exponent, modulus
+	if key.DNSKEY.PublicKey[0] != 3 || len(key.DNSKEY.PublicKey) != 4+128 {
+		t.Errorf("Invalid RSA public key: %x", key.DNSKEY.PublicKey)
+	}
+
+	if _, err := key.sign([]byte("data")); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestParseBINDPrivateKeyInvalid(t *testing.T) {
+	testCases := []string{
+		"",
+		"Private-key-format: v1.3\n",
+		"Private-key-format: v1.3\nAlgorithm: 16 (ED448)\nPrivateKey: AA==\n",
+		"Private-key-format: v1.3\nAlgorithm: 15 (ED25519)\nPrivateKey: AA==\n",
+		"Private-key-format: v1.3\nAlgorithm: 13 (ECDSAP256SHA256)\n",
+		"Private-key-format: v1.3\nAlgorithm: 8 (RSASHA256)\nModulus: AQAB\n",
+		"garbage",
+	}
+	for _, data := range testCases {
+		if _, err := ParseBINDPrivateKey(257, []byte(data)); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+}
+
+func TestParseBINDKey(t *testing.T) {
+	key, err := ParseBINDKey([]byte(testBINDPublicKeyECDSAP256), []byte(testBINDPrivateKeyECDSAP256))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !key.DNSKEY.IsSEP() || key.DNSKEY.KeyTag() != 55648 {
+		t.Errorf("Invalid DNSKEY: %s", key.DNSKEY)
+	}
+
+	if _, err := ParseBINDKey([]byte("example.com. IN DNSKEY "+testDNSKEYED25519), []byte(testBINDPrivateKeyECDSAP256)); err == nil {
+		t.Error("Expected error for mismatching key pair")
+	}
+	if _, err := ParseBINDKey([]byte("; no key"), []byte(testBINDPrivateKeyECDSAP256)); err == nil {
+		t.Error("Expected error for missing DNSKEY record")
+	}
+}
@@ -0,0 +1,233 @@
+package powerdns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// TSIGKeysService handles communication with the TSIG keys related methods of the Client API
+type TSIGKeysService service
+
+// TSIGKey structure with JSON API metadata
+type TSIGKey struct {
+	Type      *string        `json:"type,omitempty"`
+	ID        *string        `json:"id,omitempty"`
+	Name      *string        `json:"name,omitempty"`
+	Algorithm *TSIGAlgorithm `json:"algorithm,omitempty"`
+	Key       *string        `json:"key,omitempty"`
+}
+
+// TSIGAlgorithm represents a string-valued TSIG algorithm
+type TSIGAlgorithm string
+
+// TSIGAlgorithmPtr is a helper function that allocates a new TSIGAlgorithm value to store v and returns a pointer to it.
+func TSIGAlgorithmPtr(v TSIGAlgorithm) *TSIGAlgorithm {
+	return &v
+}
+
+const (
+	// TSIGAlgorithmHMACMD5 is HMAC-MD5, which should only be used for legacy peers
+	TSIGAlgorithmHMACMD5 TSIGAlgorithm = "hmac-md5"
+	// TSIGAlgorithmHMACSHA1 is HMAC-SHA1
+	TSIGAlgorithmHMACSHA1 TSIGAlgorithm = "hmac-sha1"
+	// TSIGAlgorithmHMACSHA224 is HMAC-SHA224
+	TSIGAlgorithmHMACSHA224 TSIGAlgorithm = "hmac-sha224"
+	// TSIGAlgorithmHMACSHA256 is HMAC-SHA256
+	TSIGAlgorithmHMACSHA256 TSIGAlgorithm = "hmac-sha256"
+	// TSIGAlgorithmHMACSHA384 is HMAC-SHA384
+	TSIGAlgorithmHMACSHA384 TSIGAlgorithm = "hmac-sha384"
+	// TSIGAlgorithmHMACSHA512 is HMAC-SHA512
+	TSIGAlgorithmHMACSHA512 TSIGAlgorithm = "hmac-sha512"
+)
+
+// tsigDigestSizes contains the output size of the hash functions in bytes, which is also the recommended secret size
+var tsigDigestSizes = map[TSIGAlgorithm]int{
+	TSIGAlgorithmHMACMD5:    16,
+	TSIGAlgorithmHMACSHA1:   20,
+	TSIGAlgorithmHMACSHA224: 28,
+	TSIGAlgorithmHMACSHA256: 32,
+	TSIGAlgorithmHMACSHA384: 48,
+	TSIGAlgorithmHMACSHA512: 64,
+}
+
+// TSIGKeyRole defines how a TSIG key is used by a zone
+type TSIGKeyRole string
+
+const (
+	// TSIGKeyRoleMaster authenticates outgoing zone transfers of a master zone (master_tsig_key_ids)
+	TSIGKeyRoleMaster TSIGKeyRole = "master_tsig_key_ids"
+	// TSIGKeyRoleSlave authenticates incoming zone transfers of a slave zone (slave_tsig_key_ids)
+	TSIGKeyRoleSlave TSIGKeyRole = "slave_tsig_key_ids"
+)
+
+// GenerateTSIGSecret creates a random base64-encoded secret with the digest size of an algorithm
+func GenerateTSIGSecret(algorithm TSIGAlgorithm) (string, error) {
+	size, ok := tsigDigestSizes[algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported TSIG algorithm %q", algorithm)
+	}
+
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+func validateTSIGKey(key *TSIGKey) error {
+	if key.Algorithm != nil {
+		if _, ok := tsigDigestSizes[*key.Algorithm]; !ok {
+			return fmt.Errorf("unsupported TSIG algorithm %q", *key.Algorithm)
+		}
+	}
+	if key.Key != nil && *key.Key != "" {
+		if _, err := base64.StdEncoding.DecodeString(*key.Key); err != nil {
+			return fmt.Errorf("TSIG secret is not base64-encoded: %v", err)
+		}
+	}
+	return nil
+}
+
+func tsigKeyPath(vHost string, id string) string {
+	path := fmt.Sprintf("servers/%s/tsigkeys", vHost)
+	if id != "" {
+		path += "/" + id
+	}
+	return path
+}
+
+// List retrieves all TSIG keys without their secrets
+func (t *TSIGKeysService) List(ctx context.Context) ([]TSIGKey, error) {
+	req, err := t.client.newRequest(ctx, "GET", tsigKeyPath(t.client.VHost, ""), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]TSIGKey, 0)
+	_, err = t.client.do(req, &keys)
+	return keys, err
+}
+
+// Get returns a certain TSIG key including its secret
+func (t *TSIGKeysService) Get(ctx context.Context, id string) (*TSIGKey, error) {
+	req, err := t.client.newRequest(ctx, "GET", tsigKeyPath(t.client.VHost, id), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	key := new(TSIGKey)
+	_, err = t.client.do(req, &key)
+	return key, err
+}
+
+// Create creates a new TSIG key. If the secret is empty, the server generates it.
+func (t *TSIGKeysService) Create(ctx context.Context, name string, algorithm TSIGAlgorithm, secret string) (*TSIGKey, error) {
+	key := &TSIGKey{
+		Name:      String(name),
+		Algorithm: &algorithm,
+	}
+	if secret != "" {
+		key.Key = String(secret)
+	}
+	if err := validateTSIGKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := t.client.newRequest(ctx, "POST", tsigKeyPath(t.client.VHost, ""), nil, key)
+	if err != nil {
+		return nil, err
+	}
+
+	createdKey := new(TSIGKey)
+	_, err = t.client.do(req, &createdKey)
+	return createdKey, err
+}
+
+// Change modifies the name, algorithm or secret of a TSIG key.
+// Renaming a key changes its ID, so the returned key should be used afterwards.
+func (t *TSIGKeysService) Change(ctx context.Context, id string, key *TSIGKey) (*TSIGKey, error) {
+	if err := validateTSIGKey(key); err != nil {
+		return nil, err
+	}
+
+	payload := *key
+	payload.ID = nil
+	payload.Type = nil
+
+	req, err := t.client.newRequest(ctx, "PUT", tsigKeyPath(t.client.VHost, id), nil, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	changedKey := new(TSIGKey)
+	_, err = t.client.do(req, &changedKey)
+	return changedKey, err
+}
+
+// Delete removes a TSIG key
+func (t *TSIGKeysService) Delete(ctx context.Context, id string) error {
+	req, err := t.client.newRequest(ctx, "DELETE", tsigKeyPath(t.client.VHost, id), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.do(req, nil)
+	return err
+}
+
+// AttachToZone adds a TSIG key to the transfer settings of a zone
+func (t *TSIGKeysService) AttachToZone(ctx context.Context, domain string, id string, role TSIGKeyRole) error {
+	return t.updateZoneKeyIDs(ctx, domain, role, func(ids []string) []string {
+		for _, existing := range ids {
+			if strings.EqualFold(existing, id) {
+				return ids
+			}
+		}
+		return append(ids, id)
+	})
+}
+
+// DetachFromZone removes a TSIG key from the transfer settings of a zone
+func (t *TSIGKeysService) DetachFromZone(ctx context.Context, domain string, id string, role TSIGKeyRole) error {
+	return t.updateZoneKeyIDs(ctx, domain, role, func(ids []string) []string {
+		remaining := make([]string, 0, len(ids))
+		for _, existing := range ids {
+			if !strings.EqualFold(existing, id) {
+				remaining = append(remaining, existing)
+			}
+		}
+		return remaining
+	})
+}
+
+func (t *TSIGKeysService) updateZoneKeyIDs(ctx context.Context, domain string, role TSIGKeyRole, update func([]string) []string) error {
+	zone, err := t.client.Zones.get(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	switch role {
+	case TSIGKeyRoleMaster:
+		ids = zone.MasterTSIGKeyIDs
+	case TSIGKeyRoleSlave:
+		ids = zone.SlaveTSIGKeyIDs
+	default:
+		return fmt.Errorf("unknown TSIG key role %q", role)
+	}
+
+	// Zone.Change omits empty lists, so the payload is built here to allow removing the last key
+	ids = update(append(make([]string, 0, len(ids)+1), ids...))
+	payload := map[string][]string{string(role): ids}
+
+	req, err := t.client.newRequest(ctx, "PUT", fmt.Sprintf("servers/%s/zones/%s", t.client.VHost, escapeZoneID(domain)), nil, payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.do(req, nil)
+	return err
+}
@@ -0,0 +1,262 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func registerTSIGKeysMockResponder() {
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/tsigkeys",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			keysMock := []TSIGKey{
+				{Type: String("TSIGKey"), ID: String("transfer."), Name: String("transfer"), Algorithm: TSIGAlgorithmPtr(TSIGAlgorithmHMACSHA256), Key: String("")},
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, keysMock)
+		},
+	)
+
+	httpmock.RegisterResponder("POST", generateTestAPIVHostURL()+"/tsigkeys",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var key TSIGKey
+			if json.NewDecoder(req.Body).Decode(&key) != nil || key.Name == nil || key.Algorithm == nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+			key.Type = String("TSIGKey")
+			key.ID = String(makeDomainCanonical(*key.Name))
+			if key.Key == nil {
+				key.Key = String("c2VydmVyLWdlbmVyYXRlZA==")
+			}
+			return httpmock.NewJsonResponse(http.StatusCreated, key)
+		},
+	)
+
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/tsigkeys/transfer.",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			keyMock := TSIGKey{Type: String("TSIGKey"), ID: String("transfer."), Name: String("transfer"), Algorithm: TSIGAlgorithmPtr(TSIGAlgorithmHMACSHA256), Key: String("c2VjcmV0")}
+			return httpmock.NewJsonResponse(http.StatusOK, keyMock)
+		},
+	)
+
+	httpmock.RegisterResponder("PUT", generateTestAPIVHostURL()+"/tsigkeys/transfer.",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var key TSIGKey
+			if json.NewDecoder(req.Body).Decode(&key) != nil || key.ID != nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+			if key.Name == nil {
+				key.Name = String("transfer")
+			}
+			key.Type = String("TSIGKey")
+			key.ID = String(makeDomainCanonical(*key.Name))
+			return httpmock.NewJsonResponse(http.StatusOK, key)
+		},
+	)
+
+	httpmock.RegisterResponder("DELETE", generateTestAPIVHostURL()+"/tsigkeys/transfer.",
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+}
+
+func registerZoneTSIGMockResponder(testDomain string, zone *Zone, payloads *[]map[string][]string) {
+	httpmock.RegisterResponder("GET", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, zone)
+		},
+	)
+
+	httpmock.RegisterResponder("PUT", generateTestAPIVHostURL()+"/zones/"+testDomain,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			payload := make(map[string][]string)
+			if json.NewDecoder(req.Body).Decode(&payload) != nil {
+				return httpmock.NewStringResponse(http.StatusBadRequest, "Bad Request"), nil
+			}
+			*payloads = append(*payloads, payload)
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		},
+	)
+}
+
+func TestGenerateTSIGSecret(t *testing.T) {
+	secret, err := GenerateTSIGSecret(TSIGAlgorithmHMACSHA512)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(decoded) != 64 {
+		t.Errorf("Invalid secret %q", secret)
+	}
+
+	if _, err := GenerateTSIGSecret("hmac-sha3"); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestTSIGKeys(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerTSIGKeysMockResponder()
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	keys, err := p.TSIGKeys.List(ctx)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(keys) != 1 || *keys[0].ID != "transfer." {
+		t.Errorf("Invalid keys: %+v", keys)
+	}
+
+	key, err := p.TSIGKeys.Get(ctx, "transfer.")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *key.Key != "c2VjcmV0" {
+		t.Errorf("Invalid key: %+v", key)
+	}
+
+	generated, err := p.TSIGKeys.Create(ctx, "generated", TSIGAlgorithmHMACSHA256, "")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *generated.Key != "c2VydmVyLWdlbmVyYXRlZA==" {
+		t.Errorf("Invalid server-generated key: %+v", generated)
+	}
+
+	supplied, err := p.TSIGKeys.Create(ctx, "supplied", TSIGAlgorithmHMACSHA1, "c2VjcmV0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *supplied.Key != "c2VjcmV0" || *supplied.Algorithm != TSIGAlgorithmHMACSHA1 {
+		t.Errorf("Invalid client-supplied key: %+v", supplied)
+	}
+
+	changed, err := p.TSIGKeys.Change(ctx, "transfer.", &TSIGKey{ID: String("transfer."), Name: String("renamed"), Key: String("bmV3")})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if *changed.ID != "renamed." || *changed.Key != "bmV3" {
+		t.Errorf("Invalid changed key: %+v", changed)
+	}
+
+	if err := p.TSIGKeys.Delete(ctx, "transfer."); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestTSIGKeyPath(t *testing.T) {
+	u := generateAPIURL("http", "localhost", "8080", tsigKeyPath("localhost", "transfer key."), nil)
+	if want := "http://localhost:8080/api/v1/servers/localhost/tsigkeys/transfer%20key."; u.String() != want {
+		t.Errorf("%s != %s", u.String(), want)
+	}
+}
+
+func TestCreateTSIGKeyInvalid(t *testing.T) {
+	p := initialisePowerDNSTestClient()
+	if _, err := p.TSIGKeys.Create(context.Background(), "key", "hmac-sha3", ""); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if _, err := p.TSIGKeys.Create(context.Background(), "key", TSIGAlgorithmHMACSHA256, "not base64!"); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.TSIGKeys.Change(context.Background(), "key", &TSIGKey{Algorithm: TSIGAlgorithmPtr("hmac-sha3")}); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestTSIGKeysError(t *testing.T) {
+	p := initialisePowerDNSTestClient()
+	p.Port = "x"
+	ctx := context.Background()
+	if _, err := p.TSIGKeys.List(ctx); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.TSIGKeys.Get(ctx, "transfer."); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.TSIGKeys.Create(ctx, "transfer", TSIGAlgorithmHMACMD5, ""); err == nil {
+		t.Error("error is nil")
+	}
+	if _, err := p.TSIGKeys.Change(ctx, "transfer.", &TSIGKey{}); err == nil {
+		t.Error("error is nil")
+	}
+	if err := p.TSIGKeys.Delete(ctx, "transfer."); err == nil {
+		t.Error("error is nil")
+	}
+	if err := p.TSIGKeys.AttachToZone(ctx, "example.com", "transfer.", TSIGKeyRoleMaster); err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestAttachTSIGKeyToZone(t *testing.T) {
+	testDomain := generateTestZone(true)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zone := &Zone{Name: String(testDomain + "."), MasterTSIGKeyIDs: []string{"existing."}, SlaveTSIGKeyIDs: []string{"transfer."}}
+	payloads := make([]map[string][]string, 0)
+	registerZoneTSIGMockResponder(testDomain, zone, &payloads)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if err := p.TSIGKeys.AttachToZone(ctx, testDomain, "transfer.", TSIGKeyRoleMaster); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.TSIGKeys.AttachToZone(ctx, testDomain, "transfer.", TSIGKeyRoleSlave); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.TSIGKeys.DetachFromZone(ctx, testDomain, "transfer.", TSIGKeyRoleSlave); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := p.TSIGKeys.AttachToZone(ctx, testDomain, "transfer.", "unknown"); err == nil {
+		t.Error("error is nil")
+	}
+
+	if len(payloads) != 3 {
+		t.Fatalf("Invalid amount of zone changes: %d", len(payloads))
+	}
+	if ids := payloads[0]["master_tsig_key_ids"]; len(ids) != 2 || ids[1] != "transfer." {
+		t.Errorf("Invalid master key IDs: %v", ids)
+	}
+	if ids := payloads[1]["slave_tsig_key_ids"]; len(ids) != 1 || ids[0] != "transfer." {
+		t.Errorf("Invalid slave key IDs: %v", ids)
+	}
+	if ids, ok := payloads[2]["slave_tsig_key_ids"]; !ok || len(ids) != 0 {
+		t.Errorf("Invalid slave key IDs: %v", ids)
+	}
+}
@@ -0,0 +1,163 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// txtMaxStringLength is the maximum length of a single character-string (RFC 1035, section 3.3)
+const txtMaxStringLength = 255
+
+// EncodeTXT converts a logical TXT value into the presentation format expected by PowerDNS.
+// The value is split into quoted character-strings of at most 255 bytes. Quotes and backslashes are escaped,
+// and non-printable bytes are written as \DDD.
+func EncodeTXT(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	chunks := make([]string, 0, len(value)/txtMaxStringLength+1)
+	for start := 0; start < len(value); start += txtMaxStringLength {
+		end := start + txtMaxStringLength
+		if end > len(value) {
+			end = len(value)
+		}
+		chunks = append(chunks, quoteCharacterString(value[start:end]))
+	}
+
+	return strings.Join(chunks, " ")
+}
+
+func quoteCharacterString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// DecodeTXTStrings parses the presentation format of TXT content into its character-strings
+func DecodeTXTStrings(content string) ([]string, error) {
+	stringsFound := make([]string, 0)
+	i := 0
+
+	for {
+		for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+			i++
+		}
+		if i >= len(content) {
+			return stringsFound, nil
+		}
+
+		quoted := content[i] == '"'
+		if quoted {
+			i++
+		}
+
+		var b strings.Builder
+		for {
+			if i >= len(content) {
+				if quoted {
+					return nil, errors.New("unterminated quoted TXT string")
+				}
+				break
+			}
+
+			c := content[i]
+			if quoted && c == '"' {
+				i++
+				break
+			}
+			if !quoted && (c == ' ' || c == '\t') {
+				break
+			}
+
+			if c != '\\' {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+
+			if i+1 >= len(content) {
+				return nil, errors.New("dangling escape in TXT string")
+			}
+			if isDigit(content[i+1]) {
+				if i+3 >= len(content) || !isDigit(content[i+2]) || !isDigit(content[i+3]) {
+					return nil, errors.New("invalid decimal escape in TXT string")
+				}
+				value := int(content[i+1]-'0')*100 + int(content[i+2]-'0')*10 + int(content[i+3]-'0')
+				if value > 255 {
+					return nil, errors.New("decimal escape out of range in TXT string")
+				}
+				b.WriteByte(byte(value))
+				i += 4
+				continue
+			}
+			b.WriteByte(content[i+1])
+			i += 2
+		}
+
+		if b.Len() > txtMaxStringLength {
+			return nil, fmt.Errorf("TXT character-string exceeds %d bytes", txtMaxStringLength)
+		}
+		stringsFound = append(stringsFound, b.String())
+	}
+}
+
+// DecodeTXT parses the presentation format of TXT content returned by PowerDNS into its logical value
+func DecodeTXT(content string) (string, error) {
+	parts, err := DecodeTXTStrings(content)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(parts, ""), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// AddTXT creates a new TXT resource record from logical values, see EncodeTXT
+func (r *RecordsService) AddTXT(ctx context.Context, domain string, name string, ttl uint32, values []string) error {
+	return r.ChangeTXT(ctx, domain, name, ttl, values)
+}
+
+// ChangeTXT replaces an existing TXT resource record with logical values, see EncodeTXT
+func (r *RecordsService) ChangeTXT(ctx context.Context, domain string, name string, ttl uint32, values []string) error {
+	content := make([]string, 0, len(values))
+	for _, value := range values {
+		content = append(content, EncodeTXT(value))
+	}
+	return r.Change(ctx, domain, name, RRTypeTXT, ttl, content)
+}
+
+// GetTXT returns the logical values of an existing TXT resource record, see DecodeTXT
+func (r *RecordsService) GetTXT(ctx context.Context, domain string, name string) ([]string, error) {
+	rrset, err := r.getRRSet(ctx, domain, name, RRTypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(rrset.Records))
+	for _, record := range rrset.Records {
+		value, err := DecodeTXT(StringValue(record.Content))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
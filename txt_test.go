@@ -0,0 +1,104 @@
+package powerdns
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+func TestEncodeTXT(t *testing.T) {
+	testCases := []struct {
+		value       string
+		wantContent string
+	}{
+		{"", `""`},
+		{"foo", `"foo"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\temp`, `"C:\\temp"`},
+		{"a\nb\x7f", `"a\010b\127"`},
+		{"ä", `"\195\164"`},
+		{strings.Repeat("a", 256), `"` + strings.Repeat("a", 255) + `" "a"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.wantContent, func(t *testing.T) {
+			if content := EncodeTXT(tc.value); content != tc.wantContent {
+				t.Errorf("%s != %s", content, tc.wantContent)
+			}
+		})
+	}
+}
+
+func TestDecodeTXT(t *testing.T) {
+	testCases := []struct {
+		content     string
+		wantStrings []string
+		wantErr     bool
+	}{
+		{`""`, []string{""}, false},
+		{`"foo"`, []string{"foo"}, false},
+		{`"foo" "bar"`, []string{"foo", "bar"}, false},
+		{`"say \"hi\""`, []string{`say "hi"`}, false},
+		{`"a\010b" "\195\164"`, []string{"a\nb", "ä"}, false},
+		{`foo bar`, []string{"foo", "bar"}, false},
+		{`"foo`, nil, true},
+		{`"foo\`, nil, true},
+		{`"\25"`, nil, true},
+		{`"\256"`, nil, true},
+		{`"` + strings.Repeat("a", 256) + `"`, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.content, func(t *testing.T) {
+			parts, err := DecodeTXTStrings(tc.content)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(parts, tc.wantStrings) {
+				t.Errorf("%q != %q", parts, tc.wantStrings)
+			}
+		})
+	}
+}
+
+func TestEncodeDecodeTXT(t *testing.T) {
+	value := "v=DKIM1; k=rsa; p=" + strings.Repeat("MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA\"\\", 20)
+	decoded, err := DecodeTXT(EncodeTXT(value))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if decoded != value {
+		t.Errorf("%s != %s", decoded, value)
+	}
+}
+
+func TestTXTRecords(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.com.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+	values := []string{`v=spf1 -all`, strings.Repeat("x", 300)}
+
+	if err := p.Records.AddTXT(ctx, "example.com", "example.com", 300, values); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if content := *patched["example.com."][0].Records[0].Content; content != `"v=spf1 -all"` {
+		t.Errorf("Invalid content: %s", content)
+	}
+
+	decoded, err := p.Records.GetTXT(ctx, "example.com", "example.com")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("%q != %q", decoded, values)
+	}
+}
@@ -0,0 +1,525 @@
+package powerdns
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationStatus represents the result of the validation of an RRset
+type ValidationStatus string
+
+const (
+	// ValidationSecure means that the RRset has at least one valid signature
+	ValidationSecure ValidationStatus = "secure"
+	// ValidationBogus means that the RRset has no valid signature or violates the signing rules
+	ValidationBogus ValidationStatus = "bogus"
+	// ValidationUnsigned is used for delegation NS RRsets and glue, which are not signed (RFC 4035, section 2.2)
+	ValidationUnsigned ValidationStatus = "unsigned"
+)
+
+// SignatureValidation describes the validation of a single RRSIG record
+type SignatureValidation struct {
+	KeyTag     uint16
+	Algorithm  DNSSECAlgorithm
+	Inception  time.Time
+	Expiration time.Time
+	Valid      bool
+	// Problem explains why the signature is invalid
+	Problem string
+}
+
+// RRsetValidation describes the validation of an RRset
+type RRsetValidation struct {
+	Name       string
+	Type       RRType
+	Status     ValidationStatus
+	Signatures []SignatureValidation
+	Problems   []string
+}
+
+// ValidationReport contains the validation results of a zone
+type ValidationReport struct {
+	Zone string
+	// Denial is the type of the authenticated denial of existence records, NSEC or NSEC3
+	Denial RRType
+	RRsets []RRsetValidation
+	// ChainProblems lists missing, superfluous and broken NSEC or NSEC3 records
+	ChainProblems []string
+}
+
+// Valid reports whether all RRsets are secure or unsigned, and the NSEC or NSEC3 chain is complete
+func (r *ValidationReport) Valid() bool {
+	return len(r.Bogus()) == 0 && len(r.ChainProblems) == 0
+}
+
+// Bogus returns the RRsets which failed validation
+func (r *ValidationReport) Bogus() []RRsetValidation {
+	bogus := make([]RRsetValidation, 0)
+	for _, rrset := range r.RRsets {
+		if rrset.Status == ValidationBogus {
+			bogus = append(bogus, rrset)
+		}
+	}
+	return bogus
+}
+
+// Validator checks the signatures and the NSEC or NSEC3 chain of zone data offline
+type Validator struct {
+	// Zone is the name of the validated zone
+	Zone string
+	// DNSKEYs are the trusted keys of the zone. If empty, the DNSKEY RRset of the zone data is used.
+	DNSKEYs []*DNSKEY
+	// Clock defines the current time for the validity period checks, it can be replaced for testing
+	Clock Clock
+}
+
+// NewValidator creates a Validator which trusts the published cryptokeys of a zone, as returned by CryptokeysService.List
+func NewValidator(zone string, cryptokeys []Cryptokey) (*Validator, error) {
+	validator := &Validator{Zone: zone, DNSKEYs: make([]*DNSKEY, 0, len(cryptokeys)), Clock: systemClock{}}
+	for i := range cryptokeys {
+		if cryptokeys[i].Published != nil && !*cryptokeys[i].Published {
+			continue
+		}
+		dnskey, err := cryptokeys[i].ParsedDNSKEY()
+		if err != nil {
+			return nil, err
+		}
+		validator.DNSKEYs = append(validator.DNSKEYs, dnskey)
+	}
+	return validator, nil
+}
+
+// Validate checks the RRSIG records of all RRsets and the completeness of the NSEC or NSEC3 chain.
+// The RRsets have to contain the complete zone including its DNSSEC records, e.g. as exported by AXFR or signed by a Signer.
+func (v *Validator) Validate(rrsets []RRset) (*ValidationReport, error) {
+	zone, err := loadSignerZone(v.Zone, rrsets, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timeNow()
+	if v.Clock != nil {
+		now = v.Clock.Now()
+	}
+
+	dnskeys := v.DNSKEYs
+	apexDNSKEYs := zone.names[zone.apex].rrsets[rrTypeCodes[RRTypeDNSKEY]]
+	if len(dnskeys) == 0 && apexDNSKEYs != nil {
+		for _, content := range apexDNSKEYs.contents {
+			dnskey, err := ParseDNSKEY(content)
+			if err != nil {
+				return nil, err
+			}
+			dnskeys = append(dnskeys, dnskey)
+		}
+	}
+	if len(dnskeys) == 0 {
+		return nil, fmt.Errorf("zone %s has no DNSKEYs", zone.apex)
+	}
+
+	report := &ValidationReport{Zone: zone.apex, RRsets: make([]RRsetValidation, 0), ChainProblems: make([]string, 0)}
+
+	owners := make([]*signerName, 0, len(zone.names))
+	for _, owner := range zone.names {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return compareCanonicalNames(owners[i].wireName, owners[j].wireName) < 0 })
+
+	for _, owner := range owners {
+		for _, typeCode := range owner.presentTypes() {
+			if typeCode == rrTypeCodes[RRTypeRRSIG] {
+				continue
+			}
+			result := validateRRset(zone, owner, owner.rrsets[typeCode], dnskeys, now)
+			if typeCode == rrTypeCodes[RRTypeDNSKEY] && owner.name == zone.apex {
+				result.Problems = append(result.Problems, missingDNSKEYs(owner.rrsets[typeCode], v.DNSKEYs)...)
+				if len(result.Problems) > 0 {
+					result.Status = ValidationBogus
+				}
+			}
+			report.RRsets = append(report.RRsets, result)
+		}
+		if orphaned := orphanedSignatures(owner); len(orphaned) > 0 {
+			report.RRsets = append(report.RRsets, RRsetValidation{Name: owner.name, Type: RRTypeRRSIG, Status: ValidationBogus, Signatures: make([]SignatureValidation, 0), Problems: orphaned})
+		}
+	}
+
+	apex := zone.names[zone.apex]
+	if apex.rrsets[rrTypeCodes[RRTypeNSEC3PARAM]] != nil || hasType(zone, RRTypeNSEC3) {
+		report.Denial = RRTypeNSEC3
+		report.ChainProblems = append(report.ChainProblems, validateNSEC3Chain(zone)...)
+	} else {
+		report.Denial = RRTypeNSEC
+		report.ChainProblems = append(report.ChainProblems, validateNSECChain(zone)...)
+	}
+
+	return report, nil
+}
+
+// presentTypes returns the types of the RRsets of a name in ascending order
+func (n *signerName) presentTypes() []uint16 {
+	types := make([]uint16, 0, len(n.rrsets))
+	for typeCode := range n.rrsets {
+		types = append(types, typeCode)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// orphanedSignatures reports RRSIG records which cover types without RRset
+func orphanedSignatures(owner *signerName) []string {
+	problems := make([]string, 0)
+	rrsigs := owner.rrsets[rrTypeCodes[RRTypeRRSIG]]
+	if rrsigs == nil {
+		return problems
+	}
+	for _, rdata := range rrsigs.rdata {
+		typeCode := uint16(rdata[0])<<8 | uint16(rdata[1])
+		if owner.rrsets[typeCode] == nil {
+			problems = append(problems, fmt.Sprintf("RRSIG covers the missing type %s", RRTypeFromCode(typeCode)))
+		}
+	}
+	return problems
+}
+
+func hasType(zone *signerZone, recordType RRType) bool {
+	for _, owner := range zone.names {
+		if owner.rrsets[rrTypeCodes[recordType]] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// missingDNSKEYs reports trusted keys which are not part of the DNSKEY RRset
+func missingDNSKEYs(rrset *signerRRset, trusted []*DNSKEY) []string {
+	problems := make([]string, 0)
+	for _, dnskey := range trusted {
+		found := false
+		for _, rdata := range rrset.rdata {
+			found = found || bytes.Equal(rdata, dnskey.rdata())
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("DNSKEY with key tag %d is not published", dnskey.KeyTag()))
+		}
+	}
+	return problems
+}
+
+func validateRRset(zone *signerZone, owner *signerName, rrset *signerRRset, dnskeys []*DNSKEY, now time.Time) RRsetValidation {
+	result := RRsetValidation{Name: owner.name, Type: rrset.rrType, Signatures: make([]SignatureValidation, 0), Problems: make([]string, 0)}
+
+	signatures := make([]string, 0)
+	if rrsigs := owner.rrsets[rrTypeCodes[RRTypeRRSIG]]; rrsigs != nil {
+		for _, content := range rrsigs.contents {
+			if fields := strings.Fields(content); len(fields) > 0 && strings.EqualFold(fields[0], string(rrset.rrType)) {
+				signatures = append(signatures, content)
+			}
+		}
+	}
+
+	if !owner.signed(rrset.typeCode) {
+		result.Status = ValidationUnsigned
+		if len(signatures) > 0 {
+			result.Status = ValidationBogus
+			result.Problems = append(result.Problems, "delegation NS RRsets and glue must not be signed")
+		}
+		return result
+	}
+
+	// Every algorithm of the DNSKEY RRset has to sign every RRset (RFC 4035, section 2.2)
+	algorithms := make(map[DNSSECAlgorithm]bool)
+	for _, dnskey := range dnskeys {
+		algorithms[dnskey.Algorithm] = false
+	}
+	for _, content := range signatures {
+		signature := validateSignature(zone, rrset, content, dnskeys, now)
+		if signature.Valid {
+			algorithms[signature.Algorithm] = true
+		}
+		result.Signatures = append(result.Signatures, signature)
+	}
+
+	result.Status = ValidationBogus
+	for algorithm, signed := range algorithms {
+		switch {
+		case signed:
+			result.Status = ValidationSecure
+		default:
+			result.Problems = append(result.Problems, fmt.Sprintf("no valid signature with algorithm %s", algorithm))
+		}
+	}
+	sort.Strings(result.Problems)
+	if len(result.Problems) > 0 {
+		result.Status = ValidationBogus
+	}
+	return result
+}
+
+// validateSignature checks an RRSIG record covering an RRset (RFC 4035, section 5.3)
+func validateSignature(zone *signerZone, rrset *signerRRset, content string, dnskeys []*DNSKEY, now time.Time) SignatureValidation {
+	result := SignatureValidation{}
+
+	rdata, err := PackCanonicalRDATA(RRTypeRRSIG, content)
+	if err != nil || len(rdata) < 19 {
+		result.Problem = fmt.Sprintf("invalid RRSIG: %v", err)
+		return result
+	}
+	result.Algorithm = DNSSECAlgorithm(rdata[2])
+	labels := rdata[3]
+	originalTTL := uint32(rdata[4])<<24 | uint32(rdata[5])<<16 | uint32(rdata[6])<<8 | uint32(rdata[7])
+	result.Expiration = serialTime(uint32(rdata[8])<<24|uint32(rdata[9])<<16|uint32(rdata[10])<<8|uint32(rdata[11]), now)
+	result.Inception = serialTime(uint32(rdata[12])<<24|uint32(rdata[13])<<16|uint32(rdata[14])<<8|uint32(rdata[15]), now)
+	result.KeyTag = uint16(rdata[16])<<8 | uint16(rdata[17])
+
+	signerLength := 0
+	for signerLength < len(rdata)-18 && rdata[18+signerLength] != 0 {
+		signerLength += int(rdata[18+signerLength]) + 1
+	}
+	signerLength++
+	if 18+signerLength > len(rdata) {
+		result.Problem = "invalid RRSIG signer name"
+		return result
+	}
+	signature := rdata[18+signerLength:]
+
+	ownerLabels := nameLabels(rrset.wireName)
+	switch {
+	case !bytes.Equal(rdata[18:18+signerLength], zone.apexWire):
+		result.Problem = "signer name does not match the zone"
+	case int(labels) > len(ownerLabels) || labels != signatureLabels(rrset.wireName):
+		result.Problem = fmt.Sprintf("invalid labels field %d", labels)
+	case originalTTL != rrset.ttl:
+		result.Problem = fmt.Sprintf("original TTL %d does not match the TTL %d", originalTTL, rrset.ttl)
+	case now.Before(result.Inception):
+		result.Problem = "signature is not yet valid"
+	case now.After(result.Expiration):
+		result.Problem = "signature has expired"
+	}
+	if result.Problem != "" {
+		return result
+	}
+	if _, err := signatureHash(result.Algorithm); err != nil {
+		result.Problem = err.Error()
+		return result
+	}
+
+	data := append([]byte(nil), rdata[:18+signerLength]...)
+	data = appendCanonicalRRset(data, rrset.wireName, rrset.typeCode, originalTTL, rrset.rdata)
+
+	matched := false
+	for _, dnskey := range dnskeys {
+		if dnskey.Algorithm != result.Algorithm || dnskey.KeyTag() != result.KeyTag || dnskey.Flags&DNSKEYFlagZone == 0 || dnskey.IsRevoked() {
+			continue
+		}
+		matched = true
+		if err := verifySignature(dnskey, data, signature); err == nil {
+			result.Valid = true
+			return result
+		}
+	}
+	if matched {
+		result.Problem = "signature verification failed"
+	} else {
+		result.Problem = fmt.Sprintf("no DNSKEY with key tag %d and algorithm %s", result.KeyTag, result.Algorithm)
+	}
+	return result
+}
+
+// serialTime converts an RRSIG timestamp to the time closest to now, following serial number arithmetic (RFC 4034, section 3.1.5)
+func serialTime(value uint32, now time.Time) time.Time {
+	base := now.Unix()
+	return time.Unix(base+int64(int32(value-uint32(base))), 0).UTC()
+}
+
+// verifySignature checks the signature of data with a DNSKEY (RFC 3110, RFC 6605 and RFC 8080)
+func verifySignature(dnskey *DNSKEY, data []byte, signature []byte) error {
+	hash, err := signatureHash(dnskey.Algorithm)
+	if err != nil {
+		return err
+	}
+	if dnskey.Algorithm == AlgorithmED25519 {
+		if len(dnskey.PublicKey) != ed25519.PublicKeySize || !ed25519.Verify(dnskey.PublicKey, data, signature) {
+			return errors.New("invalid Ed25519 signature")
+		}
+		return nil
+	}
+
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	if size := ecdsaKeySize(dnskey.Algorithm); size > 0 {
+		curve := elliptic.P256()
+		if dnskey.Algorithm == AlgorithmECDSAP384SHA384 {
+			curve = elliptic.P384()
+		}
+		if len(dnskey.PublicKey) != 2*size || len(signature) != 2*size {
+			return errors.New("invalid ECDSA key or signature length")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(dnskey.PublicKey[:size]), Y: new(big.Int).SetBytes(dnskey.PublicKey[size:])}
+		if !ecdsa.Verify(key, digest, new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	}
+
+	key, err := parseRSAPublicKey(dnskey.PublicKey)
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+}
+
+// parseRSAPublicKey parses the public key field of an RSA DNSKEY (RFC 3110, section 2)
+func parseRSAPublicKey(publicKey []byte) (*rsa.PublicKey, error) {
+	if len(publicKey) < 3 {
+		return nil, errors.New("invalid RSA public key")
+	}
+	exponentLength, offset := int(publicKey[0]), 1
+	if exponentLength == 0 {
+		exponentLength, offset = int(publicKey[1])<<8|int(publicKey[2]), 3
+	}
+	if exponentLength > 4 || offset+exponentLength >= len(publicKey) {
+		return nil, errors.New("unsupported RSA public key")
+	}
+	exponent := new(big.Int).SetBytes(publicKey[offset : offset+exponentLength])
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(publicKey[offset+exponentLength:]), E: int(exponent.Int64())}, nil
+}
+
+// typeBitmapOf returns the type bit maps field of the expected NSEC or NSEC3 record of a name
+func typeBitmapOf(owner *signerName) []byte {
+	if owner == nil {
+		return packTypeBitmap(nil)
+	}
+	return packTypeBitmap(owner.presentTypes())
+}
+
+// validateNSECChain checks that every authoritative name and delegation point has an NSEC record which links to the next name
+// and lists the types present (RFC 4035, section 2.3)
+func validateNSECChain(zone *signerZone) []string {
+	problems := make([]string, 0)
+	names := zone.sortedNames()
+	for i, owner := range names {
+		nsec := owner.rrsets[rrTypeCodes[RRTypeNSEC]]
+		if nsec == nil || len(nsec.rdata) != 1 {
+			problems = append(problems, fmt.Sprintf("%s has no NSEC record", owner.name))
+			continue
+		}
+		next := names[(i+1)%len(names)]
+		rdata := nsec.rdata[0]
+		if !bytes.HasPrefix(rdata, next.wireName) {
+			problems = append(problems, fmt.Sprintf("NSEC record of %s does not link to %s", owner.name, next.name))
+			continue
+		}
+		if !bytes.Equal(rdata[len(next.wireName):], typeBitmapOf(owner)) {
+			problems = append(problems, fmt.Sprintf("NSEC record of %s does not match the types present", owner.name))
+		}
+	}
+	for _, owner := range zone.names {
+		if owner.occluded && owner.rrsets[rrTypeCodes[RRTypeNSEC]] != nil {
+			problems = append(problems, fmt.Sprintf("NSEC record of %s is below a delegation", owner.name))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// validateNSEC3Chain checks that the hashes of all names of the chain have an NSEC3 record with the parameters of the zone,
+// which links to the next hash and lists the types present (RFC 5155, section 7.1)
+func validateNSEC3Chain(zone *signerZone) []string {
+	apex := zone.names[zone.apex]
+	var param *NSEC3Param
+	if nsec3Param := apex.rrsets[rrTypeCodes[RRTypeNSEC3PARAM]]; nsec3Param != nil && len(nsec3Param.contents) == 1 {
+		param, _ = ParseNSEC3Param(nsec3Param.contents[0])
+	}
+	if param == nil || param.HashAlgorithm != NSEC3HashSHA1 {
+		return []string{fmt.Sprintf("%s has no valid NSEC3PARAM record", zone.apex)}
+	}
+
+	type nsec3Record struct {
+		owner  string
+		hash   []byte
+		rdata  []byte
+		optOut bool
+	}
+	records := make(map[string]*nsec3Record)
+	hashes := make([][]byte, 0)
+	problems := make([]string, 0)
+	for name, owner := range zone.names {
+		nsec3 := owner.rrsets[rrTypeCodes[RRTypeNSEC3]]
+		if nsec3 == nil {
+			continue
+		}
+		label, parent, _ := strings.Cut(name, ".")
+		hash, err := nsec3Encoding.DecodeString(strings.ToUpper(label))
+		if err != nil || parent != zone.apex || len(nsec3.rdata) != 1 {
+			problems = append(problems, fmt.Sprintf("invalid NSEC3 record at %s", name))
+			continue
+		}
+		rdata := nsec3.rdata[0]
+		prefix, err := PackCanonicalRDATA(RRTypeNSEC3PARAM, param.String())
+		if err != nil || len(rdata) < len(prefix) || rdata[0] != prefix[0] || !bytes.Equal(rdata[2:len(prefix)], prefix[2:]) {
+			problems = append(problems, fmt.Sprintf("NSEC3 record at %s does not match the NSEC3PARAM record", name))
+			continue
+		}
+		records[string(hash)] = &nsec3Record{owner: name, hash: hash, rdata: rdata[len(prefix):], optOut: rdata[1]&NSEC3FlagOptOut != 0}
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i], hashes[j]) < 0 })
+
+	optOut := false
+	for _, record := range records {
+		optOut = optOut || record.optOut
+	}
+	expected := zone.nsec3Names(optOut)
+	for name, owner := range expected {
+		wireName, err := PackCanonicalName(name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		hash := nsec3Hash(wireName, param)
+		record, ok := records[string(hash)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s has no NSEC3 record", name))
+			continue
+		}
+		if !bytes.Equal(record.rdata[1+int(record.rdata[0]):], typeBitmapOf(owner)) {
+			problems = append(problems, fmt.Sprintf("NSEC3 record of %s does not match the types present", name))
+		}
+	}
+
+	for i, hash := range hashes {
+		record := records[string(hash)]
+		next := hashes[(i+1)%len(hashes)]
+		if int(record.rdata[0]) >= len(record.rdata) || !bytes.Equal(record.rdata[1:1+int(record.rdata[0])], next) {
+			problems = append(problems, fmt.Sprintf("NSEC3 record at %s does not link to the next hash", record.owner))
+		}
+	}
+
+	if len(records) > 0 {
+		hashedNames := make(map[string]bool, len(expected))
+		for name := range expected {
+			wireName, _ := PackCanonicalName(name)
+			hashedNames[string(nsec3Hash(wireName, param))] = true
+		}
+		for _, record := range records {
+			if !hashedNames[string(record.hash)] && !record.optOut {
+				problems = append(problems, fmt.Sprintf("NSEC3 record at %s matches no name of the zone", record.owner))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,186 @@
+package powerdns
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func signTestZone(t *testing.T, param *NSEC3Param) ([]RRset, *SigningKey, *SigningKey) {
+	t.Helper()
+	ksk, zsk := generateTestSigningKeys(t)
+	signer := NewSigner("example.com.", ksk, zsk)
+	signer.NSEC3Param = param
+	signed, err := signer.Sign(generateTestSignerRRsets())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return signed, ksk, zsk
+}
+
+func findValidation(report *ValidationReport, name string, recordType RRType) *RRsetValidation {
+	for i := range report.RRsets {
+		if report.RRsets[i].Name == name && report.RRsets[i].Type == recordType {
+			return &report.RRsets[i]
+		}
+	}
+	return nil
+}
+
+func removeTestRRset(rrsets []RRset, name string, recordType RRType) []RRset {
+	result := make([]RRset, 0, len(rrsets))
+	for _, rrset := range rrsets {
+		if *rrset.Name != name || *rrset.Type != recordType {
+			result = append(result, rrset)
+		}
+	}
+	return result
+}
+
+func TestValidatorNSEC(t *testing.T) {
+	signed, ksk, zsk := signTestZone(t, nil)
+
+	validator, err := NewValidator("example.com", []Cryptokey{
+		{KeyType: String(string(CryptokeyTypeKSK)), Active: Bool(true), Published: Bool(true), DNSkey: String(ksk.DNSKEY.String())},
+		{KeyType: String(string(CryptokeyTypeZSK)), Active: Bool(true), DNSkey: String(zsk.DNSKEY.String())},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	report, err := validator.Validate(signed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !report.Valid() || report.Denial != RRTypeNSEC {
+		t.Fatalf("Invalid report: %+v", report)
+	}
+	if result := findValidation(report, "www.example.com.", RRTypeA); result == nil || result.Status != ValidationSecure || len(result.Signatures) != 1 || result.Signatures[0].KeyTag != zsk.DNSKEY.KeyTag() {
+		t.Errorf("Invalid validation of www.example.com. A: %+v", result)
+	}
+	if result := findValidation(report, "secure.example.com.", RRTypeNS); result == nil || result.Status != ValidationUnsigned {
+		t.Errorf("Invalid validation of the delegation: %+v", result)
+	}
+	if result := findValidation(report, "ns.secure.example.com.", RRTypeA); result == nil || result.Status != ValidationUnsigned {
+		t.Errorf("Invalid validation of glue: %+v", result)
+	}
+}
+
+func TestValidatorNSEC3(t *testing.T) {
+	signed, _, _ := signTestZone(t, &NSEC3Param{HashAlgorithm: NSEC3HashSHA1, Flags: NSEC3FlagOptOut})
+
+	// Without keys, the DNSKEY RRset of the zone is trusted
+	report, err := (&Validator{Zone: "example.com."}).Validate(signed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !report.Valid() || report.Denial != RRTypeNSEC3 {
+		t.Fatalf("Invalid report: %+v", report)
+	}
+
+	for _, rrset := range signed {
+		if *rrset.Type == RRTypeNSEC3 {
+			report, err = (&Validator{Zone: "example.com."}).Validate(removeTestRRset(signed, *rrset.Name, RRTypeNSEC3))
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if report.Valid() || len(report.ChainProblems) != 2 {
+		t.Errorf("Expected a missing NSEC3 record and a broken link: %v", report.ChainProblems)
+	}
+	if bogus := report.Bogus(); len(bogus) != 1 || bogus[0].Type != RRTypeRRSIG || bogus[0].Problems[0] != "RRSIG covers the missing type NSEC3" {
+		t.Errorf("Expected an orphaned RRSIG record: %+v", bogus)
+	}
+}
+
+func TestValidatorBrokenNSECChain(t *testing.T) {
+	signed, _, _ := signTestZone(t, nil)
+
+	report, err := (&Validator{Zone: "example.com."}).Validate(removeTestRRset(signed, "www.example.com.", RRTypeNSEC))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if report.Valid() || len(report.ChainProblems) != 1 || !strings.Contains(report.ChainProblems[0], "www.example.com. has no NSEC record") {
+		t.Errorf("Invalid chain problems: %v", report.ChainProblems)
+	}
+	if result := findValidation(report, "www.example.com.", RRTypeNSEC); result != nil {
+		t.Errorf("Unexpected validation: %+v", result)
+	}
+}
+
+func TestValidatorBogus(t *testing.T) {
+	signed, ksk, _ := signTestZone(t, nil)
+	for i := range signed {
+		if *signed[i].Name == "www.example.com." && *signed[i].Type == RRTypeA {
+			signed[i].Records[0].Content = String("192.0.2.99")
+		}
+	}
+
+	report, err := (&Validator{Zone: "example.com."}).Validate(signed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	bogus := report.Bogus()
+	if len(bogus) != 1 || bogus[0].Name != "www.example.com." || bogus[0].Signatures[0].Problem != "signature verification failed" {
+		t.Errorf("Invalid bogus RRsets: %+v", bogus)
+	}
+
+	// Only the KSK is trusted, so RRsets signed by the ZSK fail
+	report, err = (&Validator{Zone: "example.com.", DNSKEYs: []*DNSKEY{ksk.DNSKEY}}).Validate(signed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if result := findValidation(report, "ns1.example.com.", RRTypeA); result == nil || result.Status != ValidationBogus || !strings.HasPrefix(result.Signatures[0].Problem, "no DNSKEY with key tag") {
+		t.Errorf("Invalid validation of ns1.example.com. A: %+v", result)
+	}
+	if result := findValidation(report, "example.com.", RRTypeDNSKEY); result == nil || result.Status != ValidationSecure {
+		t.Errorf("Invalid validation of the DNSKEY RRset: %+v", result)
+	}
+}
+
+func TestValidatorValidityPeriod(t *testing.T) {
+	signed, _, _ := signTestZone(t, nil)
+
+	validator := &Validator{Zone: "example.com.", Clock: &fakeClock{now: time.Now().Add(DefaultSignatureValidity + time.Hour)}}
+	report, err := validator.Validate(signed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	result := findValidation(report, "example.com.", RRTypeSOA)
+	if result == nil || result.Status != ValidationBogus || result.Signatures[0].Problem != "signature has expired" {
+		t.Errorf("Invalid validation: %+v", result)
+	}
+
+	validator.Clock = &fakeClock{now: time.Now().Add(-2 * time.Hour)}
+	if report, err = validator.Validate(signed); err != nil {
+		t.Fatalf("%s", err)
+	}
+	result = findValidation(report, "example.com.", RRTypeSOA)
+	if result == nil || result.Status != ValidationBogus || result.Signatures[0].Problem != "signature is not yet valid" {
+		t.Errorf("Invalid validation: %+v", result)
+	}
+}
+
+func TestValidatorUnsupportedAlgorithm(t *testing.T) {
+	signed, _, _ := signTestZone(t, nil)
+	for i := range signed {
+		if *signed[i].Type == RRTypeRRSIG && *signed[i].Name == "www.example.com." {
+			for j := range signed[i].Records {
+				fields := strings.Fields(*signed[i].Records[j].Content)
+				fields[1] = "16"
+				signed[i].Records[j].Content = String(strings.Join(fields, " "))
+			}
+		}
+	}
+
+	report, err := (&Validator{Zone: "example.com."}).Validate(signed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	result := findValidation(report, "www.example.com.", RRTypeA)
+	if result == nil || result.Status != ValidationBogus || result.Signatures[0].Problem != "unsupported signing algorithm ED448" {
+		t.Errorf("Invalid validation: %+v", result)
+	}
+}
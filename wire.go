@@ -0,0 +1,424 @@
+package powerdns
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rrTypeCodes maps resource record types to their numeric values (IANA DNS parameters)
+var rrTypeCodes = map[RRType]uint16{
+	RRTypeA:          1,
+	RRTypeNS:         2,
+	RRTypeCNAME:      5,
+	RRTypeSOA:        6,
+	RRTypeMR:         9,
+	RRTypeWKS:        11,
+	RRTypePTR:        12,
+	RRTypeHINFO:      13,
+	RRTypeMINFO:      14,
+	RRTypeMX:         15,
+	RRTypeTXT:        16,
+	RRTypeRP:         17,
+	RRTypeAFSDB:      18,
+	RRTypeSIG:        24,
+	RRTypeKEY:        25,
+	RRTypeAAAA:       28,
+	RRTypeLOC:        29,
+	RRTypeSRV:        33,
+	RRTypeNAPTR:      35,
+	RRTypeKX:         36,
+	RRTypeCERT:       37,
+	RRTypeA6:         38,
+	RRTypeDNAME:      39,
+	RRTypeDS:         43,
+	RRTypeSSHFP:      44,
+	RRTypeIPSECKEY:   45,
+	RRTypeRRSIG:      46,
+	RRTypeNSEC:       47,
+	RRTypeDNSKEY:     48,
+	RRTypeDHCID:      49,
+	RRTypeNSEC3:      50,
+	RRTypeNSEC3PARAM: 51,
+	RRTypeTLSA:       52,
+	RRTypeSMIMEA:     53,
+	RRTypeRKEY:       57,
+	RRTypeCDS:        59,
+	RRTypeCDNSKEY:    60,
+	RRTypeOPENPGPKEY: 61,
+	RRTypeZONEMD:     63,
+	RRTypeSPF:        99,
+	RRTypeEUI48:      108,
+	RRTypeEUI64:      109,
+	RRTypeTKEY:       249,
+	RRTypeTSIG:       250,
+	RRTypeMAILB:      253,
+	RRTypeMAILA:      254,
+	RRTypeURI:        256,
+	RRTypeCAA:        257,
+	RRTypeDLV:        32769,
+	// ALIAS and LUA are PowerDNS specific and use private type numbers
+	RRTypeALIAS: 65401,
+	RRTypeLUA:   65402,
+}
+
+// rrTypeNames maps numeric values to resource record types
+var rrTypeNames = func() map[uint16]RRType {
+	names := make(map[uint16]RRType, len(rrTypeCodes))
+	for recordType, code := range rrTypeCodes {
+		names[code] = recordType
+	}
+	return names
+}()
+
+// Code returns the numeric value of a resource record type. Unknown types are accepted as "TYPE<n>" (RFC 3597, section 5).
+func (t RRType) Code() (uint16, error) {
+	s := strings.ToUpper(string(t))
+	if code, ok := rrTypeCodes[RRType(s)]; ok {
+		return code, nil
+	}
+	if strings.HasPrefix(s, "TYPE") {
+		if code, err := strconv.ParseUint(s[4:], 10, 16); err == nil {
+			return uint16(code), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown resource record type %q", t)
+}
+
+// RRTypeFromCode returns the resource record type of a numeric value, or "TYPE<n>" if it is unknown
+func RRTypeFromCode(code uint16) RRType {
+	if recordType, ok := rrTypeNames[code]; ok {
+		return recordType
+	}
+	return RRType("TYPE" + strconv.Itoa(int(code)))
+}
+
+// classIN is the numeric value of the Internet class, the only class served by PowerDNS
+const classIN = 1
+
+// PackRDATA converts the content of a record into uncompressed wire format.
+// Types without a known RDATA layout have to use the generic format "\# <length> <hex>" of RFC 3597.
+func PackRDATA(recordType RRType, content string) ([]byte, error) {
+	return packRDATA(recordType, content, false)
+}
+
+// PackCanonicalRDATA converts the content of a record into canonical wire format (RFC 4034, section 6.2)
+func PackCanonicalRDATA(recordType RRType, content string) ([]byte, error) {
+	return packRDATA(recordType, content, true)
+}
+
+// UnpackRDATA converts RDATA in wire format into the content of a record.
+// RDATA of types without a known layout is returned in the generic format of RFC 3597.
+func UnpackRDATA(recordType RRType, rdata []byte) (string, error) {
+	return unpackRDATA(recordType, rdata, 0, len(rdata))
+}
+
+// PackRRset returns the enabled records of an RRset in uncompressed wire format, class IN
+func PackRRset(rrset *RRset) ([]byte, error) {
+	return packRRset(rrset, false)
+}
+
+// PackCanonicalRRset returns the enabled records of an RRset in canonical form and order (RFC 4034, section 6.3).
+// Duplicate records are removed.
+func PackCanonicalRRset(rrset *RRset) ([]byte, error) {
+	return packRRset(rrset, true)
+}
+
+func packRRset(rrset *RRset, canonical bool) ([]byte, error) {
+	if rrset == nil || rrset.Name == nil || rrset.Type == nil {
+		return nil, errors.New("RRset requires a name and a type")
+	}
+	typeCode, err := rrset.Type.Code()
+	if err != nil {
+		return nil, err
+	}
+	wireName, err := packName(makeDomainCanonical(idnToASCIIOrRaw(*rrset.Name)), canonical)
+	if err != nil {
+		return nil, err
+	}
+
+	rdata := make([][]byte, 0, len(rrset.Records))
+	for _, record := range rrset.Records {
+		if BoolValue(record.Disabled) {
+			continue
+		}
+		r, err := packRDATA(*rrset.Type, StringValue(record.Content), canonical)
+		if err != nil {
+			return nil, err
+		}
+		rdata = append(rdata, r)
+	}
+
+	if canonical {
+		return appendCanonicalRRset(nil, wireName, typeCode, Uint32Value(rrset.TTL), rdata), nil
+	}
+	data := make([]byte, 0)
+	for _, r := range rdata {
+		data = appendRR(data, wireName, typeCode, Uint32Value(rrset.TTL), r)
+	}
+	return data, nil
+}
+
+// appendCanonicalRRset appends the records of an RRset in canonical form and order (RFC 4034, section 6.3)
+func appendCanonicalRRset(data []byte, wireName []byte, typeCode uint16, ttl uint32, rdata [][]byte) []byte {
+	sorted := append([][]byte(nil), rdata...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+
+	for i, r := range sorted {
+		if i > 0 && bytes.Equal(sorted[i-1], r) {
+			continue
+		}
+		data = appendRR(data, wireName, typeCode, ttl, r)
+	}
+	return data
+}
+
+// appendRR appends a resource record of class IN in wire format (RFC 1035, section 4.1.3)
+func appendRR(data []byte, wireName []byte, typeCode uint16, ttl uint32, rdata []byte) []byte {
+	data = append(data, wireName...)
+	data = appendUint16(data, typeCode)
+	data = appendUint16(data, classIN)
+	data = appendUint32(data, ttl)
+	data = appendUint16(data, uint16(len(rdata)))
+	return append(data, rdata...)
+}
+
+// UnpackRRsets converts resource records in wire format into RRsets.
+// Records are grouped by owner name and type in the order of their first occurrence, the lowest TTL of a group is used.
+func UnpackRRsets(data []byte) ([]RRset, error) {
+	rrs := make([]*wireRR, 0)
+	for offset := 0; offset < len(data); {
+		rr, next, err := unpackRR(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+		offset = next
+	}
+	return groupRRs(rrs), nil
+}
+
+// groupRRs groups resource records by owner name and type in the order of their first occurrence
+func groupRRs(rrs []*wireRR) []RRset {
+	rrsets := make([]RRset, 0)
+	index := make(map[string]int)
+	for _, rr := range rrs {
+		key := strings.ToLower(rr.name) + " " + string(rr.rrType)
+		i, ok := index[key]
+		if !ok {
+			i = len(rrsets)
+			index[key] = i
+			rrsets = append(rrsets, RRset{Name: String(rr.name), Type: RRTypePtr(rr.rrType), TTL: Uint32(rr.ttl), Records: []Record{}})
+		}
+		if rr.ttl < *rrsets[i].TTL {
+			rrsets[i].TTL = Uint32(rr.ttl)
+		}
+		rrsets[i].Records = append(rrsets[i].Records, Record{Content: String(rr.content), Disabled: Bool(false)})
+	}
+	return rrsets
+}
+
+// wireRR is a resource record read from wire format
+type wireRR struct {
+	name    string
+	rrType  RRType
+	class   uint16
+	ttl     uint32
+	content string
+}
+
+// unpackRR reads the resource record at offset of a DNS message and returns the offset of the next one
+func unpackRR(msg []byte, offset int) (*wireRR, int, error) {
+	name, offset, err := unpackName(msg, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset+10 > len(msg) {
+		return nil, 0, errors.New("truncated resource record")
+	}
+	rr := &wireRR{
+		name:   name,
+		rrType: RRTypeFromCode(readUint16(msg[offset:])),
+		class:  readUint16(msg[offset+2:]),
+		ttl:    readUint32(msg[offset+4:]),
+	}
+	length := int(readUint16(msg[offset+8:]))
+	offset += 10
+	if offset+length > len(msg) {
+		return nil, 0, errors.New("truncated RDATA")
+	}
+	if rr.content, err = unpackRDATA(rr.rrType, msg, offset, length); err != nil {
+		return nil, 0, err
+	}
+	return rr, offset + length, nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func readUint16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func readUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// PackName returns the uncompressed wire format of a domain name. Escapes like "\." and "\DDD" are supported.
+func PackName(name string) ([]byte, error) {
+	return packName(makeDomainCanonical(name), false)
+}
+
+// PackCanonicalName returns the canonical wire format of a fully qualified domain name (RFC 4034, section 6.2).
+// Unlike PackName, it rejects names without trailing dot.
+func PackCanonicalName(name string) ([]byte, error) {
+	return packName(name, true)
+}
+
+// UnpackName reads a possibly compressed domain name at offset of a DNS message.
+// It returns the fully qualified name with escapes and the offset following the name.
+func UnpackName(msg []byte, offset int) (string, int, error) {
+	return unpackName(msg, offset)
+}
+
+// packName returns the uncompressed wire format of a fully qualified domain name, optionally lowercased.
+// Escapes like "\." and "\DDD" are supported.
+func packName(name string, lower bool) ([]byte, error) {
+	if name == "." {
+		return []byte{0}, nil
+	}
+
+	wire := make([]byte, 0, len(name)+1)
+	label := make([]byte, 0, 63)
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '.':
+			if len(label) == 0 {
+				return nil, fmt.Errorf("domain name %q contains an empty label", name)
+			}
+			if len(label) > 63 {
+				return nil, fmt.Errorf("domain name %q contains a label longer than 63 octets", name)
+			}
+			wire = append(wire, byte(len(label)))
+			wire = append(wire, label...)
+			label = label[:0]
+			continue
+		case c == '\\' && i+3 < len(name) && isDigit(name[i+1]) && isDigit(name[i+2]) && isDigit(name[i+3]):
+			value := int(name[i+1]-'0')*100 + int(name[i+2]-'0')*10 + int(name[i+3]-'0')
+			if value > 255 {
+				return nil, fmt.Errorf("domain name %q contains an invalid escape", name)
+			}
+			c = byte(value)
+			i += 3
+		case c == '\\' && i+1 < len(name):
+			c = name[i+1]
+			i++
+		}
+		if lower && c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		label = append(label, c)
+	}
+	if len(label) > 0 {
+		return nil, fmt.Errorf("domain name %q is not fully qualified", name)
+	}
+
+	wire = append(wire, 0)
+	if len(wire) > 255 {
+		return nil, fmt.Errorf("domain name %q exceeds 255 octets", name)
+	}
+	return wire, nil
+}
+
+// nameLabels splits the wire format of a domain name into its labels, excluding the root label
+func nameLabels(wire []byte) [][]byte {
+	labels := make([][]byte, 0)
+	for i := 0; i < len(wire) && wire[i] != 0; i += int(wire[i]) + 1 {
+		labels = append(labels, wire[i+1:i+1+int(wire[i])])
+	}
+	return labels
+}
+
+// compareCanonicalNames orders the canonical wire format of domain names (RFC 4034, section 6.1)
+func compareCanonicalNames(a []byte, b []byte) int {
+	labelsA, labelsB := nameLabels(a), nameLabels(b)
+	for i, j := len(labelsA)-1, len(labelsB)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := bytes.Compare(labelsA[i], labelsB[j]); c != 0 {
+			return c
+		}
+	}
+	return len(labelsA) - len(labelsB)
+}
+
+// unpackName reads a domain name at offset of a DNS message, following compression pointers (RFC 1035, section 4.1.4).
+// Pointers have to refer to prior data, which prevents loops.
+func unpackName(msg []byte, offset int) (string, int, error) {
+	var name strings.Builder
+	end := -1
+	length := 1
+	for {
+		if offset >= len(msg) {
+			return "", 0, errors.New("truncated domain name")
+		}
+		c := int(msg[offset])
+		switch c & 0xc0 {
+		case 0x00:
+			if c == 0 {
+				if end < 0 {
+					end = offset + 1
+				}
+				if name.Len() == 0 {
+					return ".", end, nil
+				}
+				return name.String(), end, nil
+			}
+			if offset+1+c > len(msg) {
+				return "", 0, errors.New("truncated domain name")
+			}
+			if length += c + 1; length > 255 {
+				return "", 0, errors.New("domain name exceeds 255 octets")
+			}
+			writeEscapedLabel(&name, msg[offset+1:offset+1+c])
+			name.WriteByte('.')
+			offset += 1 + c
+		case 0xc0:
+			if offset+2 > len(msg) {
+				return "", 0, errors.New("truncated compression pointer")
+			}
+			pointer := int(readUint16(msg[offset:]) & 0x3fff)
+			if pointer >= offset {
+				return "", 0, errors.New("compression pointer does not point backwards")
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = pointer
+		default:
+			return "", 0, fmt.Errorf("unsupported label type 0x%02x", c&0xc0)
+		}
+	}
+}
+
+// writeEscapedLabel writes the presentation format of a label, escaping special and non-printable characters
+func writeEscapedLabel(name *strings.Builder, label []byte) {
+	for _, c := range label {
+		switch {
+		case c == '.' || c == '\\' || c == '"' || c == ';' || c == '(' || c == ')' || c == '@' || c == '$':
+			name.WriteByte('\\')
+			name.WriteByte(c)
+		case c <= ' ' || c >= 0x7f:
+			fmt.Fprintf(name, "\\%03d", c)
+		default:
+			name.WriteByte(c)
+		}
+	}
+}
@@ -0,0 +1,900 @@
+package powerdns
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rdataField describes how a field of the presentation format is encoded in wire format
+type rdataField int
+
+const (
+	// fieldName is a domain name, which is lowercased in canonical form (RFC 4034, section 6.2)
+	fieldName rdataField = iota
+	// fieldNameKeepCase is a domain name, which keeps its case in canonical form (RFC 6840, section 5.1)
+	fieldNameKeepCase
+	fieldUint8
+	fieldUint16
+	fieldUint32
+	fieldAlgorithm
+	fieldType
+	fieldTime
+	fieldIPv4
+	fieldIPv6
+	fieldCharString
+	fieldSalt
+	fieldBase32Hex
+	fieldCAATag
+	fieldEUI48
+	fieldEUI64
+	fieldWKSProtocol
+	fieldIPSECKEYGateway
+	// The following fields consume all remaining tokens
+	fieldCharStrings
+	fieldBase64
+	fieldHex
+	fieldTypeBitmap
+	fieldOpaqueString
+	fieldWKSPorts
+	fieldA6
+	fieldLOC
+)
+
+// rdataFields defines the RDATA layout of the resource record types.
+// TKEY, TSIG, MAILA and MAILB only occur in DNS messages, their RDATA uses the generic format of RFC 3597.
+var rdataFields = map[RRType][]rdataField{
+	RRTypeA:          {fieldIPv4},
+	RRTypeAAAA:       {fieldIPv6},
+	RRTypeA6:         {fieldA6},
+	RRTypeNS:         {fieldName},
+	RRTypeCNAME:      {fieldName},
+	RRTypeDNAME:      {fieldName},
+	RRTypePTR:        {fieldName},
+	RRTypeMR:         {fieldName},
+	RRTypeALIAS:      {fieldName},
+	RRTypeSOA:        {fieldName, fieldName, fieldUint32, fieldUint32, fieldUint32, fieldUint32, fieldUint32},
+	RRTypeMX:         {fieldUint16, fieldName},
+	RRTypeAFSDB:      {fieldUint16, fieldName},
+	RRTypeKX:         {fieldUint16, fieldName},
+	RRTypeMINFO:      {fieldName, fieldName},
+	RRTypeRP:         {fieldName, fieldName},
+	RRTypeHINFO:      {fieldCharString, fieldCharString},
+	RRTypeTXT:        {fieldCharStrings},
+	RRTypeSPF:        {fieldCharStrings},
+	RRTypeSRV:        {fieldUint16, fieldUint16, fieldUint16, fieldName},
+	RRTypeNAPTR:      {fieldUint16, fieldUint16, fieldCharString, fieldCharString, fieldCharString, fieldName},
+	RRTypeDS:         {fieldUint16, fieldAlgorithm, fieldUint8, fieldHex},
+	RRTypeCDS:        {fieldUint16, fieldAlgorithm, fieldUint8, fieldHex},
+	RRTypeDLV:        {fieldUint16, fieldAlgorithm, fieldUint8, fieldHex},
+	RRTypeDNSKEY:     {fieldUint16, fieldUint8, fieldAlgorithm, fieldBase64},
+	RRTypeCDNSKEY:    {fieldUint16, fieldUint8, fieldAlgorithm, fieldBase64},
+	RRTypeKEY:        {fieldUint16, fieldUint8, fieldAlgorithm, fieldBase64},
+	RRTypeRKEY:       {fieldUint16, fieldUint8, fieldAlgorithm, fieldBase64},
+	RRTypeRRSIG:      {fieldType, fieldAlgorithm, fieldUint8, fieldUint32, fieldTime, fieldTime, fieldUint16, fieldName, fieldBase64},
+	RRTypeSIG:        {fieldType, fieldAlgorithm, fieldUint8, fieldUint32, fieldTime, fieldTime, fieldUint16, fieldName, fieldBase64},
+	RRTypeNSEC:       {fieldNameKeepCase, fieldTypeBitmap},
+	RRTypeNSEC3:      {fieldUint8, fieldUint8, fieldUint16, fieldSalt, fieldBase32Hex, fieldTypeBitmap},
+	RRTypeNSEC3PARAM: {fieldUint8, fieldUint8, fieldUint16, fieldSalt},
+	RRTypeSSHFP:      {fieldUint8, fieldUint8, fieldHex},
+	RRTypeTLSA:       {fieldUint8, fieldUint8, fieldUint8, fieldHex},
+	RRTypeSMIMEA:     {fieldUint8, fieldUint8, fieldUint8, fieldHex},
+	RRTypeCERT:       {fieldUint16, fieldUint16, fieldAlgorithm, fieldBase64},
+	RRTypeOPENPGPKEY: {fieldBase64},
+	RRTypeDHCID:      {fieldBase64},
+	RRTypeURI:        {fieldUint16, fieldUint16, fieldOpaqueString},
+	RRTypeCAA:        {fieldUint8, fieldCAATag, fieldOpaqueString},
+	RRTypeEUI48:      {fieldEUI48},
+	RRTypeEUI64:      {fieldEUI64},
+	RRTypeWKS:        {fieldIPv4, fieldWKSProtocol, fieldWKSPorts},
+	RRTypeIPSECKEY:   {fieldUint8, fieldUint8, fieldUint8, fieldIPSECKEYGateway, fieldBase64},
+	RRTypeLOC:        {fieldLOC},
+	RRTypeZONEMD:     {fieldUint32, fieldUint8, fieldUint8, fieldHex},
+	// The Lua code of LUA records is preceded by the type of the generated records
+	RRTypeLUA: {fieldType, fieldOpaqueString},
+}
+
+// rdataToken is a field of the presentation format. Raw keeps escapes for domain names, Value is unescaped.
+type rdataToken struct {
+	Raw    string
+	Value  string
+	Quoted bool
+}
+
+// splitRDATA splits the presentation format of RDATA into tokens, honoring quotes and escapes
+func splitRDATA(content string) ([]rdataToken, error) {
+	tokens := make([]rdataToken, 0)
+	for i := 0; ; {
+		for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+			i++
+		}
+		if i >= len(content) {
+			return tokens, nil
+		}
+
+		token := rdataToken{Quoted: content[i] == '"'}
+		if token.Quoted {
+			i++
+		}
+
+		var raw, value strings.Builder
+		for {
+			if i >= len(content) {
+				if token.Quoted {
+					return nil, fmt.Errorf("unterminated quoted string in %q", content)
+				}
+				break
+			}
+			c := content[i]
+			if token.Quoted && c == '"' {
+				i++
+				break
+			}
+			if !token.Quoted && (c == ' ' || c == '\t') {
+				break
+			}
+			if c != '\\' {
+				raw.WriteByte(c)
+				value.WriteByte(c)
+				i++
+				continue
+			}
+
+			if i+1 >= len(content) {
+				return nil, fmt.Errorf("dangling escape in %q", content)
+			}
+			if isDigit(content[i+1]) {
+				if i+3 >= len(content) || !isDigit(content[i+2]) || !isDigit(content[i+3]) {
+					return nil, fmt.Errorf("invalid decimal escape in %q", content)
+				}
+				n := int(content[i+1]-'0')*100 + int(content[i+2]-'0')*10 + int(content[i+3]-'0')
+				if n > 255 {
+					return nil, fmt.Errorf("decimal escape out of range in %q", content)
+				}
+				raw.WriteString(content[i : i+4])
+				value.WriteByte(byte(n))
+				i += 4
+				continue
+			}
+			raw.WriteString(content[i : i+2])
+			value.WriteByte(content[i+1])
+			i += 2
+		}
+
+		token.Raw = raw.String()
+		token.Value = value.String()
+		tokens = append(tokens, token)
+	}
+}
+
+// packRDATA converts the presentation format of a record into wire format.
+// In canonical form, domain names are lowercased (RFC 4034, section 6.2).
+// The generic format "\# <length> <hex>" of RFC 3597 is accepted for every type.
+func packRDATA(recordType RRType, content string, canonical bool) ([]byte, error) {
+	tokens, err := splitRDATA(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) > 0 && tokens[0].Raw == `\#` && !tokens[0].Quoted {
+		return packGenericRDATA(tokens[1:], content)
+	}
+
+	fields, ok := rdataFields[RRType(strings.ToUpper(string(recordType)))]
+	if !ok {
+		return nil, fmt.Errorf("%s records have to use the generic format of RFC 3597", recordType)
+	}
+
+	rdata := make([]byte, 0, len(content))
+	for i, field := range fields {
+		if field >= fieldCharStrings {
+			remaining := tokens[:0]
+			if i < len(tokens) {
+				remaining = tokens[i:]
+			}
+			if rdata, err = packRemainingFields(rdata, field, remaining); err != nil {
+				return nil, fmt.Errorf("invalid %s content %q: %w", recordType, content, err)
+			}
+			break
+		}
+		if i >= len(tokens) {
+			return nil, fmt.Errorf("invalid %s content %q: too few fields", recordType, content)
+		}
+		if rdata, err = packField(rdata, field, tokens[i], canonical); err != nil {
+			return nil, fmt.Errorf("invalid %s content %q: %w", recordType, content, err)
+		}
+		if i == len(fields)-1 && len(tokens) > len(fields) {
+			return nil, fmt.Errorf("invalid %s content %q: too many fields", recordType, content)
+		}
+	}
+
+	if len(rdata) > 0xffff {
+		return nil, fmt.Errorf("%s content exceeds 65535 octets", recordType)
+	}
+	return rdata, nil
+}
+
+func packGenericRDATA(tokens []rdataToken, content string) ([]byte, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("invalid generic content %q", content)
+	}
+	length, err := strconv.ParseUint(tokens[0].Value, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generic content %q", content)
+	}
+
+	var hexData strings.Builder
+	for _, token := range tokens[1:] {
+		hexData.WriteString(token.Value)
+	}
+	rdata, err := hex.DecodeString(hexData.String())
+	if err != nil || len(rdata) != int(length) {
+		return nil, fmt.Errorf("invalid generic content %q", content)
+	}
+	return rdata, nil
+}
+
+// formatGenericRDATA returns the generic presentation format of RDATA (RFC 3597, section 5)
+func formatGenericRDATA(rdata []byte) string {
+	if len(rdata) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %s`, len(rdata), hex.EncodeToString(rdata))
+}
+
+func packField(rdata []byte, field rdataField, token rdataToken, canonical bool) ([]byte, error) {
+	switch field {
+	case fieldName, fieldNameKeepCase:
+		name, err := packName(makeDomainCanonical(token.Raw), canonical && field == fieldName)
+		if err != nil {
+			return nil, err
+		}
+		return append(rdata, name...), nil
+	case fieldUint8:
+		n, err := strconv.ParseUint(token.Value, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 8 bit value %q", token.Value)
+		}
+		return append(rdata, uint8(n)), nil
+	case fieldUint16:
+		n, err := strconv.ParseUint(token.Value, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 16 bit value %q", token.Value)
+		}
+		return appendUint16(rdata, uint16(n)), nil
+	case fieldUint32:
+		n, err := strconv.ParseUint(token.Value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 32 bit value %q", token.Value)
+		}
+		return appendUint32(rdata, uint32(n)), nil
+	case fieldAlgorithm:
+		algorithm, err := ParseDNSSECAlgorithm(token.Value)
+		if err != nil {
+			return nil, err
+		}
+		return append(rdata, uint8(algorithm)), nil
+	case fieldType:
+		code, err := RRType(token.Value).Code()
+		if err != nil {
+			return nil, err
+		}
+		return appendUint16(rdata, code), nil
+	case fieldTime:
+		t, err := parseSignatureTime(token.Value)
+		if err != nil {
+			return nil, err
+		}
+		return appendUint32(rdata, t), nil
+	case fieldIPv4:
+		ip := net.ParseIP(token.Value).To4()
+		if ip == nil || strings.Contains(token.Value, ":") {
+			return nil, fmt.Errorf("invalid IPv4 address %q", token.Value)
+		}
+		return append(rdata, ip...), nil
+	case fieldIPv6:
+		ip := net.ParseIP(token.Value)
+		if ip == nil || !strings.Contains(token.Value, ":") {
+			return nil, fmt.Errorf("invalid IPv6 address %q", token.Value)
+		}
+		return append(rdata, ip.To16()...), nil
+	case fieldCharString, fieldCAATag:
+		if len(token.Value) > txtMaxStringLength {
+			return nil, fmt.Errorf("character-string exceeds %d octets", txtMaxStringLength)
+		}
+		rdata = append(rdata, uint8(len(token.Value)))
+		return append(rdata, token.Value...), nil
+	case fieldSalt:
+		var salt []byte
+		if token.Value != "-" {
+			var err error
+			if salt, err = hex.DecodeString(token.Value); err != nil || len(salt) > 255 {
+				return nil, fmt.Errorf("invalid salt %q", token.Value)
+			}
+		}
+		rdata = append(rdata, uint8(len(salt)))
+		return append(rdata, salt...), nil
+	case fieldBase32Hex:
+		hash, err := nsec3Encoding.DecodeString(strings.ToUpper(token.Value))
+		if err != nil || len(hash) > 255 {
+			return nil, fmt.Errorf("invalid hashed owner name %q", token.Value)
+		}
+		rdata = append(rdata, uint8(len(hash)))
+		return append(rdata, hash...), nil
+	case fieldEUI48, fieldEUI64:
+		size := 6
+		if field == fieldEUI64 {
+			size = 8
+		}
+		parts := strings.Split(token.Value, "-")
+		if len(parts) != size {
+			return nil, fmt.Errorf("invalid EUI %q", token.Value)
+		}
+		for _, part := range parts {
+			b, err := hex.DecodeString(part)
+			if err != nil || len(b) != 1 {
+				return nil, fmt.Errorf("invalid EUI %q", token.Value)
+			}
+			rdata = append(rdata, b[0])
+		}
+		return rdata, nil
+	case fieldWKSProtocol:
+		switch strings.ToLower(token.Value) {
+		case "tcp":
+			return append(rdata, 6), nil
+		case "udp":
+			return append(rdata, 17), nil
+		}
+		return packField(rdata, fieldUint8, token, canonical)
+	case fieldIPSECKEYGateway:
+		// The gateway type is the second octet (RFC 4025, section 2.3)
+		switch rdata[len(rdata)-2] {
+		case 0:
+			if token.Value != "." {
+				return nil, fmt.Errorf("invalid gateway %q without gateway type", token.Value)
+			}
+			return rdata, nil
+		case 1:
+			return packField(rdata, fieldIPv4, token, canonical)
+		case 2:
+			return packField(rdata, fieldIPv6, token, canonical)
+		case 3:
+			return packField(rdata, fieldNameKeepCase, token, canonical)
+		}
+		return nil, fmt.Errorf("unknown gateway type %d", rdata[len(rdata)-2])
+	}
+	return nil, fmt.Errorf("unsupported field %d", field)
+}
+
+func packRemainingFields(rdata []byte, field rdataField, tokens []rdataToken) ([]byte, error) {
+	switch field {
+	case fieldCharStrings:
+		if len(tokens) == 0 {
+			return nil, errors.New("missing character-string")
+		}
+		for _, token := range tokens {
+			var err error
+			if rdata, err = packField(rdata, fieldCharString, token, false); err != nil {
+				return nil, err
+			}
+		}
+		return rdata, nil
+	case fieldBase64:
+		data, err := base64.StdEncoding.DecodeString(joinTokenValues(tokens, ""))
+		if err != nil {
+			return nil, errors.New("invalid base64 data")
+		}
+		return append(rdata, data...), nil
+	case fieldHex:
+		data, err := hex.DecodeString(joinTokenValues(tokens, ""))
+		if err != nil {
+			return nil, errors.New("invalid hex data")
+		}
+		return append(rdata, data...), nil
+	case fieldTypeBitmap:
+		types := make([]uint16, 0, len(tokens))
+		for _, token := range tokens {
+			code, err := RRType(token.Value).Code()
+			if err != nil {
+				return nil, err
+			}
+			types = append(types, code)
+		}
+		return append(rdata, packTypeBitmap(types)...), nil
+	case fieldOpaqueString:
+		if len(tokens) != 1 {
+			return nil, errors.New("expected a single string")
+		}
+		return append(rdata, tokens[0].Value...), nil
+	case fieldWKSPorts:
+		bitmap := make([]byte, 0)
+		for _, token := range tokens {
+			port, err := strconv.ParseUint(token.Value, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port %q", token.Value)
+			}
+			for len(bitmap) <= int(port/8) {
+				bitmap = append(bitmap, 0)
+			}
+			bitmap[port/8] |= 0x80 >> (port % 8)
+		}
+		return append(rdata, bitmap...), nil
+	case fieldA6:
+		return packA6(rdata, tokens)
+	case fieldLOC:
+		return packLOC(rdata, tokens)
+	}
+	return nil, fmt.Errorf("unsupported field %d", field)
+}
+
+// packA6 encodes the prefix length, the address suffix and the prefix name of A6 records (RFC 2874, section 3.1)
+func packA6(rdata []byte, tokens []rdataToken) ([]byte, error) {
+	if len(tokens) < 2 {
+		return nil, errors.New("too few fields")
+	}
+	prefixLength, err := strconv.ParseUint(tokens[0].Value, 10, 8)
+	if err != nil || prefixLength > 128 {
+		return nil, fmt.Errorf("invalid prefix length %q", tokens[0].Value)
+	}
+	ip := net.ParseIP(tokens[1].Value)
+	if ip == nil || !strings.Contains(tokens[1].Value, ":") {
+		return nil, fmt.Errorf("invalid address suffix %q", tokens[1].Value)
+	}
+
+	rdata = append(rdata, uint8(prefixLength))
+	rdata = append(rdata, ip.To16()[prefixLength/8:]...)
+	switch {
+	case prefixLength == 0 && len(tokens) == 2:
+		return rdata, nil
+	case prefixLength > 0 && len(tokens) == 3:
+		name, err := packName(makeDomainCanonical(tokens[2].Raw), false)
+		if err != nil {
+			return nil, err
+		}
+		return append(rdata, name...), nil
+	}
+	return nil, errors.New("prefix name has to be present if and only if the prefix length is not zero")
+}
+
+func unpackA6(rdata []byte) (string, error) {
+	if len(rdata) < 1 || rdata[0] > 128 || len(rdata) < 1+16-int(rdata[0])/8 {
+		return "", errors.New("invalid A6 RDATA")
+	}
+	prefixLength := int(rdata[0])
+	ip := make(net.IP, 16)
+	suffixEnd := 1 + 16 - prefixLength/8
+	copy(ip[prefixLength/8:], rdata[1:suffixEnd])
+
+	fields := []string{strconv.Itoa(prefixLength), ip.String()}
+	if prefixLength > 0 {
+		name, end, err := unpackName(rdata, suffixEnd)
+		if err != nil {
+			return "", err
+		}
+		if end != len(rdata) {
+			return "", errors.New("trailing data after A6 prefix name")
+		}
+		fields = append(fields, name)
+	} else if suffixEnd != len(rdata) {
+		return "", errors.New("trailing data after A6 address suffix")
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// LOC constants (RFC 1876, section 2 and 3)
+const (
+	locEquator   = 1 << 31
+	locAltitude0 = 10000000
+)
+
+// packLOC encodes the presentation format of LOC records (RFC 1876, section 3)
+func packLOC(rdata []byte, tokens []rdataToken) ([]byte, error) {
+	values := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		values = append(values, token.Value)
+	}
+
+	latitude, values, err := parseLOCCoordinate(values, "N", "S", 90)
+	if err != nil {
+		return nil, err
+	}
+	longitude, values, err := parseLOCCoordinate(values, "E", "W", 180)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("missing altitude")
+	}
+	altitude, err := parseLOCMeters(values[0], -100000, 42849672.95)
+	if err != nil {
+		return nil, err
+	}
+
+	// Size, horizontal and vertical precision default to 1m, 10km and 10m
+	precision := []int64{100, 1000000, 1000}
+	if len(values) > 4 {
+		return nil, errors.New("too many fields")
+	}
+	for i, value := range values[1:] {
+		cm, err := parseLOCMeters(value, 0, 90000000)
+		if err != nil {
+			return nil, err
+		}
+		precision[i] = cm
+	}
+
+	rdata = append(rdata, 0)
+	for _, cm := range precision {
+		rdata = append(rdata, packLOCPrecision(cm))
+	}
+	rdata = appendUint32(rdata, latitude)
+	rdata = appendUint32(rdata, longitude)
+	return appendUint32(rdata, uint32(altitude+locAltitude0)), nil
+}
+
+// parseLOCCoordinate parses "d [m [s]] hemisphere" into thousandths of arc seconds relative to the equator or the prime meridian
+func parseLOCCoordinate(values []string, positive string, negative string, maxDegrees uint64) (uint32, []string, error) {
+	parts := make([]string, 0, 3)
+	for len(values) > 0 && len(parts) < 4 {
+		value := strings.ToUpper(values[0])
+		values = values[1:]
+		if value == positive || value == negative {
+			if len(parts) == 0 {
+				return 0, nil, errors.New("missing degrees")
+			}
+			degrees, err := strconv.ParseUint(parts[0], 10, 8)
+			if err != nil || degrees > maxDegrees {
+				return 0, nil, fmt.Errorf("invalid degrees %q", parts[0])
+			}
+			var minutes uint64
+			if len(parts) > 1 {
+				if minutes, err = strconv.ParseUint(parts[1], 10, 8); err != nil || minutes >= 60 {
+					return 0, nil, fmt.Errorf("invalid minutes %q", parts[1])
+				}
+			}
+			var seconds float64
+			if len(parts) > 2 {
+				if seconds, err = strconv.ParseFloat(parts[2], 64); err != nil || seconds < 0 || seconds >= 60 {
+					return 0, nil, fmt.Errorf("invalid seconds %q", parts[2])
+				}
+			}
+
+			offset := int64(((degrees*60+minutes)*60)*1000) + int64(math.Round(seconds*1000))
+			if offset > int64(maxDegrees)*3600*1000 {
+				return 0, nil, errors.New("coordinate out of range")
+			}
+			if value == negative {
+				offset = -offset
+			}
+			return uint32(locEquator + offset), values, nil
+		}
+		parts = append(parts, value)
+	}
+	return 0, nil, fmt.Errorf("missing hemisphere %s or %s", positive, negative)
+}
+
+// parseLOCMeters parses a distance like "10m" or "-2.5" into centimeters
+func parseLOCMeters(value string, min float64, max float64) (int64, error) {
+	meters, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(value), "m"), 64)
+	if err != nil || meters < min || meters > max {
+		return 0, fmt.Errorf("invalid distance %q", value)
+	}
+	return int64(math.Round(meters * 100)), nil
+}
+
+// packLOCPrecision encodes centimeters as mantissa and power of ten, rounding up
+func packLOCPrecision(cm int64) uint8 {
+	exponent := 0
+	for cm > 9 && exponent < 9 {
+		cm = (cm + 9) / 10
+		exponent++
+	}
+	if cm > 9 {
+		cm = 9
+	}
+	return uint8(cm)<<4 | uint8(exponent)
+}
+
+func unpackLOCPrecision(value uint8) (float64, error) {
+	mantissa, exponent := value>>4, value&0x0f
+	if mantissa > 9 || exponent > 9 {
+		return 0, fmt.Errorf("invalid LOC precision 0x%02x", value)
+	}
+	return float64(mantissa) * math.Pow10(int(exponent)) / 100, nil
+}
+
+func unpackLOC(rdata []byte) (string, error) {
+	if len(rdata) != 16 || rdata[0] != 0 {
+		return "", errors.New("invalid LOC RDATA")
+	}
+
+	precision := make([]float64, 3)
+	for i := range precision {
+		value, err := unpackLOCPrecision(rdata[1+i])
+		if err != nil {
+			return "", err
+		}
+		precision[i] = value
+	}
+
+	coordinate := func(value uint32, positive string, negative string) string {
+		offset := int64(value) - locEquator
+		hemisphere := positive
+		if offset < 0 {
+			offset, hemisphere = -offset, negative
+		}
+		degrees := offset / 3600000
+		minutes := offset / 60000 % 60
+		seconds := float64(offset%60000) / 1000
+		return fmt.Sprintf("%d %d %.3f %s", degrees, minutes, seconds, hemisphere)
+	}
+
+	altitude := (float64(readUint32(rdata[12:])) - locAltitude0) / 100
+	return fmt.Sprintf("%s %s %.2fm %.2fm %.2fm %.2fm", coordinate(readUint32(rdata[4:]), "N", "S"), coordinate(readUint32(rdata[8:]), "E", "W"),
+		altitude, precision[0], precision[1], precision[2]), nil
+}
+
+// unpackRDATA converts RDATA within a DNS message into its presentation format. Compressed domain names are supported.
+func unpackRDATA(recordType RRType, msg []byte, offset int, length int) (string, error) {
+	end := offset + length
+	if end > len(msg) {
+		return "", errors.New("RDATA exceeds the message")
+	}
+	rdata := msg[offset:end]
+
+	fields, ok := rdataFields[RRType(strings.ToUpper(string(recordType)))]
+	if !ok {
+		return formatGenericRDATA(rdata), nil
+	}
+
+	if len(fields) == 1 {
+		switch fields[0] {
+		case fieldA6:
+			return unpackA6(rdata)
+		case fieldLOC:
+			return unpackLOC(rdata)
+		}
+	}
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		token, next, err := unpackField(field, msg, offset, end)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s RDATA: %w", recordType, err)
+		}
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+		offset = next
+	}
+	if offset != end {
+		return "", fmt.Errorf("invalid %s RDATA: %d trailing octets", recordType, end-offset)
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+func unpackField(field rdataField, msg []byte, offset int, end int) (string, int, error) {
+	need := func(n int) error {
+		if offset+n > end {
+			return errors.New("RDATA too short")
+		}
+		return nil
+	}
+
+	switch field {
+	case fieldName, fieldNameKeepCase:
+		name, next, err := unpackName(msg[:end], offset)
+		return name, next, err
+	case fieldUint8, fieldAlgorithm, fieldWKSProtocol:
+		if err := need(1); err != nil {
+			return "", 0, err
+		}
+		return strconv.Itoa(int(msg[offset])), offset + 1, nil
+	case fieldUint16:
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		return strconv.Itoa(int(readUint16(msg[offset:]))), offset + 2, nil
+	case fieldType:
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		return string(RRTypeFromCode(readUint16(msg[offset:]))), offset + 2, nil
+	case fieldUint32:
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatUint(uint64(readUint32(msg[offset:])), 10), offset + 4, nil
+	case fieldTime:
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		return formatSignatureTime(time.Unix(int64(readUint32(msg[offset:])), 0)), offset + 4, nil
+	case fieldIPv4:
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		return net.IP(msg[offset : offset+4]).String(), offset + 4, nil
+	case fieldIPv6:
+		if err := need(16); err != nil {
+			return "", 0, err
+		}
+		return net.IP(msg[offset : offset+16]).String(), offset + 16, nil
+	case fieldCharString, fieldCAATag, fieldSalt, fieldBase32Hex:
+		if err := need(1); err != nil {
+			return "", 0, err
+		}
+		length := int(msg[offset])
+		if err := need(1 + length); err != nil {
+			return "", 0, err
+		}
+		value := msg[offset+1 : offset+1+length]
+		switch field {
+		case fieldCAATag:
+			return string(value), offset + 1 + length, nil
+		case fieldSalt:
+			if length == 0 {
+				return "-", offset + 1, nil
+			}
+			return hex.EncodeToString(value), offset + 1 + length, nil
+		case fieldBase32Hex:
+			return strings.ToLower(nsec3Encoding.EncodeToString(value)), offset + 1 + length, nil
+		}
+		return quoteCharacterString(string(value)), offset + 1 + length, nil
+	case fieldEUI48, fieldEUI64:
+		size := 6
+		if field == fieldEUI64 {
+			size = 8
+		}
+		if err := need(size); err != nil {
+			return "", 0, err
+		}
+		parts := make([]string, 0, size)
+		for _, b := range msg[offset : offset+size] {
+			parts = append(parts, hex.EncodeToString([]byte{b}))
+		}
+		return strings.Join(parts, "-"), offset + size, nil
+	case fieldIPSECKEYGateway:
+		// The gateway type is the second octet (RFC 4025, section 2.3)
+		switch msg[offset-2] {
+		case 0:
+			return ".", offset, nil
+		case 1:
+			return unpackField(fieldIPv4, msg, offset, end)
+		case 2:
+			return unpackField(fieldIPv6, msg, offset, end)
+		case 3:
+			return unpackField(fieldNameKeepCase, msg, offset, end)
+		}
+		return "", 0, fmt.Errorf("unknown gateway type %d", msg[offset-2])
+	case fieldCharStrings:
+		values := make([]string, 0)
+		for offset < end {
+			value, next, err := unpackField(fieldCharString, msg, offset, end)
+			if err != nil {
+				return "", 0, err
+			}
+			values = append(values, value)
+			offset = next
+		}
+		if len(values) == 0 {
+			return "", 0, errors.New("missing character-string")
+		}
+		return strings.Join(values, " "), end, nil
+	case fieldBase64:
+		return base64.StdEncoding.EncodeToString(msg[offset:end]), end, nil
+	case fieldHex:
+		return hex.EncodeToString(msg[offset:end]), end, nil
+	case fieldTypeBitmap:
+		types, err := unpackTypeBitmap(msg[offset:end])
+		if err != nil {
+			return "", 0, err
+		}
+		mnemonics := make([]string, 0, len(types))
+		for _, typeCode := range types {
+			mnemonics = append(mnemonics, string(RRTypeFromCode(typeCode)))
+		}
+		return strings.Join(mnemonics, " "), end, nil
+	case fieldOpaqueString:
+		return quoteCharacterString(string(msg[offset:end])), end, nil
+	case fieldWKSPorts:
+		ports := make([]string, 0)
+		for i, b := range msg[offset:end] {
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>bit) != 0 {
+					ports = append(ports, strconv.Itoa(i*8+bit))
+				}
+			}
+		}
+		return strings.Join(ports, " "), end, nil
+	}
+	return "", 0, fmt.Errorf("unsupported field %d", field)
+}
+
+func joinTokenValues(tokens []rdataToken, sep string) string {
+	values := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		values = append(values, token.Value)
+	}
+	return strings.Join(values, sep)
+}
+
+// signatureTimeFormat is the presentation format of RRSIG timestamps (RFC 4034, section 3.2)
+const signatureTimeFormat = "20060102150405"
+
+// parseSignatureTime parses an RRSIG timestamp, either as YYYYMMDDHHmmSS or as number of seconds
+func parseSignatureTime(s string) (uint32, error) {
+	if len(s) == len(signatureTimeFormat) {
+		t, err := time.Parse(signatureTimeFormat, s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid signature time %q", s)
+		}
+		return uint32(t.Unix()), nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid signature time %q", s)
+	}
+	return uint32(n), nil
+}
+
+// formatSignatureTime returns the presentation format of an RRSIG timestamp
+func formatSignatureTime(t time.Time) string {
+	return t.UTC().Format(signatureTimeFormat)
+}
+
+// packTypeBitmap returns the type bit maps field of NSEC and NSEC3 records (RFC 4034, section 4.1.2)
+func packTypeBitmap(types []uint16) []byte {
+	sorted := append([]uint16(nil), types...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	bitmap := make([]byte, 0)
+	for i := 0; i < len(sorted); {
+		window := sorted[i] >> 8
+		bits := make([]byte, 32)
+		length := 0
+		for ; i < len(sorted) && sorted[i]>>8 == window; i++ {
+			offset := sorted[i] & 0xff
+			bits[offset/8] |= 0x80 >> (offset % 8)
+			length = int(offset/8) + 1
+		}
+		bitmap = append(bitmap, uint8(window), uint8(length))
+		bitmap = append(bitmap, bits[:length]...)
+	}
+	return bitmap
+}
+
+// unpackTypeBitmap returns the types of a type bit maps field in ascending order
+func unpackTypeBitmap(bitmap []byte) ([]uint16, error) {
+	types := make([]uint16, 0)
+	lastWindow := -1
+	for i := 0; i < len(bitmap); {
+		if i+2 > len(bitmap) {
+			return nil, errors.New("truncated type bit map")
+		}
+		window, length := int(bitmap[i]), int(bitmap[i+1])
+		if window <= lastWindow || length == 0 || length > 32 || i+2+length > len(bitmap) {
+			return nil, errors.New("invalid type bit map")
+		}
+		for j, b := range bitmap[i+2 : i+2+length] {
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>bit) != 0 {
+					types = append(types, uint16(window<<8|j*8+bit))
+				}
+			}
+		}
+		lastWindow = window
+		i += 2 + length
+	}
+	return types, nil
+}
+
+var nsec3Encoding = base32.HexEncoding.WithPadding(base32.NoPadding)
@@ -0,0 +1,226 @@
+package powerdns
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPackRDATA(t *testing.T) {
+	testCases := []struct {
+		recordType RRType
+		content    string
+		canonical  bool
+		wantHex    string
+	}{
+		{RRTypeA, "192.0.2.1", true, "c0000201"},
+		{RRTypeAAAA, "2001:db8::1", true, "20010db8000000000000000000000001"},
+		{RRTypeMX, "10 Mail.Example.COM.", true, "000a046d61696c076578616d706c6503636f6d00"},
+		{RRTypeMX, "10 Mail.Example.COM.", false, "000a044d61696c074578616d706c6503434f4d00"},
+		{RRTypeTXT, `"a b" c`, true, "03612062" + "0163"},
+		{RRTypeCAA, `0 issue "ca.example.net"`, true, "00056973737565" + hex.EncodeToString([]byte("ca.example.net"))},
+		{RRTypeNSEC3PARAM, "1 0 0 -", true, "0100000000"},
+		// RFC 4034, section 4.3
+		{RRTypeNSEC, "host.example.com. A MX RRSIG NSEC TYPE1234", true, "04686f7374076578616d706c6503636f6d00" +
+			"0006400100000003" + "041b" + strings.Repeat("00", 26) + "20"},
+		{RRTypeNSEC, "Host.Example.COM. A", true, "04486f7374074578616d706c6503434f4d00" + "000140"},
+		{RRType("TYPE65534"), `\# 3 abcdef`, true, "abcdef"},
+	}
+
+	for _, tc := range testCases {
+		rdata, err := packRDATA(tc.recordType, tc.content, tc.canonical)
+		if err != nil {
+			t.Errorf("%s %q: %s", tc.recordType, tc.content, err)
+			continue
+		}
+		if got := hex.EncodeToString(rdata); got != tc.wantHex {
+			t.Errorf("%s %q: got %s, want %s", tc.recordType, tc.content, got, tc.wantHex)
+		}
+	}
+}
+
+func TestPackRDATAInvalid(t *testing.T) {
+	testCases := []struct {
+		recordType RRType
+		content    string
+	}{
+		{RRTypeA, "2001:db8::1"},
+		{RRTypeAAAA, "192.0.2.1"},
+		{RRTypeMX, "10"},
+		{RRTypeMX, "10 mail.example.com. extra"},
+		{RRTypeTXT, `"unterminated`},
+		{RRTypeLOC, "52 22 23.000 X 4 53 32.000 E 0m"},
+		{RRTypeEUI48, "00-00-5e-00-53"},
+		{RRTypeA6, "0 2001:db8::1 prefix.example.com."},
+		{RRType("TYPE65534"), `\# 4 abcdef`},
+	}
+
+	for _, tc := range testCases {
+		if _, err := PackCanonicalRDATA(tc.recordType, tc.content); err == nil {
+			t.Errorf("%s %q: expected error", tc.recordType, tc.content)
+		}
+	}
+}
+
+func TestCompareCanonicalNames(t *testing.T) {
+	// RFC 4034, section 6.1
+	ordered := []string{"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.", "z.example.", "\\001.z.example.", "*.z.example.", "\\200.z.example."}
+	for i := 1; i < len(ordered); i++ {
+		a, err := PackCanonicalName(ordered[i-1])
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		b, err := PackCanonicalName(ordered[i])
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if compareCanonicalNames(a, b) >= 0 {
+			t.Errorf("%s is not ordered before %s", ordered[i-1], ordered[i])
+		}
+	}
+}
+
+func TestRRTypeCode(t *testing.T) {
+	if code, err := RRType("mx").Code(); err != nil || code != 15 {
+		t.Errorf("Invalid MX type code %d: %v", code, err)
+	}
+	if code, err := RRType("TYPE1234").Code(); err != nil || code != 1234 {
+		t.Errorf("Invalid generic type code %d: %v", code, err)
+	}
+	if _, err := RRType("BOGUS").Code(); err == nil {
+		t.Error("Expected error for unknown type")
+	}
+	if recordType := RRTypeFromCode(1234); recordType != "TYPE1234" {
+		t.Errorf("Invalid type %s", recordType)
+	}
+}
+
+func TestRDATARoundTrip(t *testing.T) {
+	testCases := []struct {
+		recordType RRType
+		content    string
+	}{
+		{RRTypeA, "192.0.2.1"},
+		{RRTypeAAAA, "2001:db8::1"},
+		{RRTypeA6, "0 2001:db8::1"},
+		{RRTypeA6, "64 ::1 prefix.example.com."},
+		{RRTypeNS, "ns1.example.com."},
+		{RRTypeCNAME, `a\.b.example.com.`},
+		{RRTypeALIAS, "target.example.net."},
+		{RRTypeSOA, "ns1.example.com. hostmaster.example.com. 2024010101 10800 3600 604800 3600"},
+		{RRTypeMX, "10 mail.example.com."},
+		{RRTypeHINFO, `"PC" "Linux"`},
+		{RRTypeTXT, `"a b" "c\"d" "\010"`},
+		{RRTypeSRV, "10 20 5060 sip.example.com."},
+		{RRTypeNAPTR, `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`},
+		{RRTypeDS, "60485 5 1 2bb183af5f22588179a53b0a98631fad1a292118"},
+		{RRTypeDNSKEY, testDNSKEYED25519},
+		{RRTypeRRSIG, "MX 15 2 3600 20150819220000 20150729220000 3613 example.com. oL9krJun7xfBOIWcGHi7mag5/hdZrKWw15jPGrHpjQeRAvTdszaPD+QLs3fx8A4M3e23mRZ9VrbpMngwcrqNAg=="},
+		{RRTypeNSEC, "host.example.com. A MX RRSIG NSEC TYPE1234"},
+		{RRTypeNSEC3, "1 1 12 aabbccdd 2vptu5timamqttgl4luu9kg21e0aor3s A RRSIG"},
+		{RRTypeNSEC3PARAM, "1 0 0 -"},
+		{RRTypeSSHFP, "4 2 123456789abcdef67890123456789abcdef67890123456789abcdef123456789"},
+		{RRTypeTLSA, "3 1 1 d2abde240d7cd3ee6b4b28c54df034b97983a1d16e8a410e4561cb106618e971"},
+		{RRTypeCAA, `0 issue "ca.example.net"`},
+		{RRTypeURI, `10 1 "ftp://ftp1.example.com/public"`},
+		{RRTypeEUI48, "00-00-5e-00-53-2a"},
+		{RRTypeEUI64, "00-00-5e-ef-10-00-00-2a"},
+		{RRTypeWKS, "192.0.2.1 6 21 25 53"},
+		{RRTypeIPSECKEY, "10 1 2 192.0.2.38 AQNRU3mG7TVTO2BkR47usntb102uFJtugbo6BSGvgqt4AQ=="},
+		{RRTypeIPSECKEY, "10 0 2 . AQNRU3mG7TVTO2BkR47usntb102uFJtugbo6BSGvgqt4AQ=="},
+		{RRTypeIPSECKEY, "10 3 2 gateway.example.com. AQNRU3mG7TVTO2BkR47usntb102uFJtugbo6BSGvgqt4AQ=="},
+		{RRTypeLOC, "52 22 23.000 N 4 53 32.000 E -2.00m 1.00m 10000.00m 10.00m"},
+		{RRTypeLOC, "42 21 54.500 S 71 6 18.000 W 24.00m 30.00m 10.00m 2.00m"},
+		{RRTypeLUA, `A "ifportup(443, {'192.0.2.1', '192.0.2.2'})"`},
+		{RRTypeTSIG, `\# 2 abcd`},
+		{RRType("TYPE65534"), `\# 0`},
+	}
+
+	for _, tc := range testCases {
+		rdata, err := PackRDATA(tc.recordType, tc.content)
+		if err != nil {
+			t.Errorf("%s %q: %s", tc.recordType, tc.content, err)
+			continue
+		}
+		content, err := UnpackRDATA(tc.recordType, rdata)
+		if err != nil {
+			t.Errorf("%s %q: %s", tc.recordType, tc.content, err)
+			continue
+		}
+		if content != tc.content {
+			t.Errorf("%s: got %q, want %q", tc.recordType, content, tc.content)
+		}
+	}
+}
+
+func TestPackRDATALOC(t *testing.T) {
+	// RFC 1876, section 3: the precision defaults to 1m, 10km and 10m
+	rdata, err := PackRDATA(RRTypeLOC, "52 22 23 N 4 53 32 E -2m")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if got := hex.EncodeToString(rdata); got != "00121613"+"8b3cf018"+"810cbce0"+"009895b8" {
+		t.Errorf("Invalid LOC RDATA %s", got)
+	}
+}
+
+func TestUnpackName(t *testing.T) {
+	// "example.com." at offset 0, "www" with a pointer to offset 0 at offset 13
+	msg := []byte("\x07example\x03com\x00\x03www\xc0\x00")
+	name, next, err := UnpackName(msg, 13)
+	if err != nil || name != "www.example.com." || next != len(msg) {
+		t.Errorf("Invalid compressed name %q at %d: %v", name, next, err)
+	}
+
+	if name, _, err := UnpackName([]byte("\x03a.b\x01 \x00"), 0); err != nil || name != `a\.b.\032.` {
+		t.Errorf("Invalid escaped name %q: %v", name, err)
+	}
+
+	for _, invalid := range [][]byte{[]byte("\xc0\x00"), []byte("\x07exam"), []byte("\x40"), nil} {
+		if _, _, err := UnpackName(invalid, 0); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestPackRRset(t *testing.T) {
+	rrset := &RRset{
+		Name: String("WWW.example.com"),
+		Type: RRTypePtr(RRTypeA),
+		TTL:  Uint32(300),
+		Records: []Record{
+			{Content: String("192.0.2.2")},
+			{Content: String("192.0.2.1")},
+			{Content: String("192.0.2.2")},
+			{Content: String("192.0.2.3"), Disabled: Bool(true)},
+		},
+	}
+
+	data, err := PackRRset(rrset)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	rrsets, err := UnpackRRsets(data)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(rrsets) != 1 || *rrsets[0].Name != "WWW.example.com." || *rrsets[0].TTL != 300 || len(rrsets[0].Records) != 3 {
+		t.Errorf("Invalid RRsets: %+v", rrsets)
+	}
+
+	data, err = PackCanonicalRRset(rrset)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	rr := "03777777076578616d706c6503636f6d00" + "0001" + "0001" + "0000012c" + "0004"
+	if got := hex.EncodeToString(data); got != rr+"c0000201"+rr+"c0000202" {
+		t.Errorf("Invalid canonical RRset %s", got)
+	}
+
+	if _, err := PackRRset(&RRset{Name: String("example.com."), Type: RRTypePtr(RRTypeA), Records: []Record{{Content: String("invalid")}}}); err == nil {
+		t.Error("Expected error for invalid content")
+	}
+	if _, err := UnpackRRsets(data[:len(data)-1]); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+}
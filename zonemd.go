@@ -0,0 +1,253 @@
+package powerdns
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ZONEMDScheme is the scheme of a ZONEMD record (RFC 8976, section 2.2.2)
+type ZONEMDScheme uint8
+
+// ZONEMDSchemeSimple digests the zone as a whole
+const ZONEMDSchemeSimple ZONEMDScheme = 1
+
+// ZONEMDHashAlgorithm is the hash algorithm of a ZONEMD record (RFC 8976, section 2.2.3)
+type ZONEMDHashAlgorithm uint8
+
+const (
+	// ZONEMDHashSHA384 is SHA-384, which is mandatory to implement
+	ZONEMDHashSHA384 ZONEMDHashAlgorithm = 1
+	// ZONEMDHashSHA512 is SHA-512
+	ZONEMDHashSHA512 ZONEMDHashAlgorithm = 2
+)
+
+// zonemdMinDigestLength is the minimum length of a ZONEMD digest (RFC 8976, section 2.2.4)
+const zonemdMinDigestLength = 12
+
+// ErrZONEMDMismatch is returned if no ZONEMD record matches the contents of a zone
+var ErrZONEMDMismatch = errors.New("ZONEMD does not match zone contents")
+
+// ZONEMD is the parsed content of a ZONEMD record
+type ZONEMD struct {
+	Serial        uint32
+	Scheme        ZONEMDScheme
+	HashAlgorithm ZONEMDHashAlgorithm
+	Digest        []byte
+}
+
+// ParseZONEMD parses the presentation format of ZONEMD content like "2018031900 1 1 c68090d9..."
+func ParseZONEMD(content string) (*ZONEMD, error) {
+	fields := strings.Fields(content)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid ZONEMD %q", content)
+	}
+
+	serial, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZONEMD serial %q", fields[0])
+	}
+	scheme, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZONEMD scheme %q", fields[1])
+	}
+	hashAlgorithm, err := strconv.ParseUint(fields[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZONEMD hash algorithm %q", fields[2])
+	}
+	digest, err := hex.DecodeString(strings.Join(fields[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZONEMD digest: %v", err)
+	}
+	if len(digest) < zonemdMinDigestLength {
+		return nil, fmt.Errorf("ZONEMD digest is shorter than %d octets", zonemdMinDigestLength)
+	}
+
+	return &ZONEMD{Serial: uint32(serial), Scheme: ZONEMDScheme(scheme), HashAlgorithm: ZONEMDHashAlgorithm(hashAlgorithm), Digest: digest}, nil
+}
+
+// String returns the presentation format of a ZONEMD, using lower-case hex
+func (m *ZONEMD) String() string {
+	return fmt.Sprintf("%d %d %d %s", m.Serial, m.Scheme, m.HashAlgorithm, hex.EncodeToString(m.Digest))
+}
+
+func zonemdHash(hashAlgorithm ZONEMDHashAlgorithm) (hash.Hash, error) {
+	switch hashAlgorithm {
+	case ZONEMDHashSHA384:
+		return sha512.New384(), nil
+	case ZONEMDHashSHA512:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported ZONEMD hash algorithm %d", hashAlgorithm)
+}
+
+// ComputeZONEMD calculates the SIMPLE digest of a zone (RFC 8976, section 3).
+// All enabled records are included, except for the apex ZONEMD RRset and the RRSIG records covering it.
+func ComputeZONEMD(zoneName string, rrsets []RRset, hashAlgorithm ZONEMDHashAlgorithm) (*ZONEMD, error) {
+	h, err := zonemdHash(hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := loadSignerZone(zoneName, rrsets, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Occluded names are part of the digest as well
+	names := make([]*signerName, 0, len(zone.names))
+	for _, owner := range zone.names {
+		names = append(names, owner)
+	}
+	sort.Slice(names, func(i, j int) bool { return compareCanonicalNames(names[i].wireName, names[j].wireName) < 0 })
+
+	zonemdCode := rrTypeCodes[RRTypeZONEMD]
+	data := make([]byte, 0)
+	for _, owner := range names {
+		typeCodes := make([]int, 0, len(owner.rrsets))
+		for typeCode := range owner.rrsets {
+			typeCodes = append(typeCodes, int(typeCode))
+		}
+		sort.Ints(typeCodes)
+
+		for _, typeCode := range typeCodes {
+			rrset := owner.rrsets[uint16(typeCode)]
+			rdata := rrset.rdata
+			if owner.name == zone.apex {
+				if rrset.typeCode == zonemdCode {
+					continue
+				}
+				if rrset.typeCode == rrTypeCodes[RRTypeRRSIG] {
+					rdata = make([][]byte, 0, len(rrset.rdata))
+					for _, r := range rrset.rdata {
+						if readUint16(r) != zonemdCode {
+							rdata = append(rdata, r)
+						}
+					}
+				}
+			}
+			data = appendCanonicalRRset(data, rrset.wireName, rrset.typeCode, rrset.ttl, rdata)
+		}
+	}
+	h.Write(data)
+
+	// The serial is the third field of the SOA RDATA, followed by four 32 bit fields
+	soaRDATA := zone.soa.rdata[0]
+	serial := readUint32(soaRDATA[len(soaRDATA)-20:])
+	return &ZONEMD{Serial: serial, Scheme: ZONEMDSchemeSimple, HashAlgorithm: hashAlgorithm, Digest: h.Sum(nil)}, nil
+}
+
+// VerifyZONEMD checks the apex ZONEMD records of a zone against its contents (RFC 8976, section 4).
+// The verification succeeds if a record with a supported scheme and hash algorithm matches the SOA serial and the digest.
+func VerifyZONEMD(zoneName string, rrsets []RRset) error {
+	apex := makeDomainCanonical(idnToASCIIOrRaw(zoneName))
+	zonemdRRset := findRRSet(rrsets, apex, RRTypeZONEMD)
+	if zonemdRRset == nil {
+		return fmt.Errorf("zone %s has no ZONEMD record", apex)
+	}
+
+	seen := make(map[[2]uint8]bool)
+	problems := make([]string, 0)
+	for _, record := range zonemdRRset.Records {
+		if BoolValue(record.Disabled) {
+			continue
+		}
+		zonemd, err := ParseZONEMD(StringValue(record.Content))
+		if err != nil {
+			return err
+		}
+
+		key := [2]uint8{uint8(zonemd.Scheme), uint8(zonemd.HashAlgorithm)}
+		if seen[key] {
+			return fmt.Errorf("zone %s has multiple ZONEMD records with scheme %d and hash algorithm %d", apex, zonemd.Scheme, zonemd.HashAlgorithm)
+		}
+		seen[key] = true
+
+		if zonemd.Scheme != ZONEMDSchemeSimple {
+			problems = append(problems, fmt.Sprintf("unsupported scheme %d", zonemd.Scheme))
+			continue
+		}
+		if _, err := zonemdHash(zonemd.HashAlgorithm); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		expected, err := ComputeZONEMD(apex, rrsets, zonemd.HashAlgorithm)
+		if err != nil {
+			return err
+		}
+		if zonemd.Serial != expected.Serial {
+			problems = append(problems, fmt.Sprintf("serial %d does not match SOA serial %d", zonemd.Serial, expected.Serial))
+			continue
+		}
+		if bytes.Equal(zonemd.Digest, expected.Digest) {
+			return nil
+		}
+		problems = append(problems, fmt.Sprintf("digest mismatch for hash algorithm %d", zonemd.HashAlgorithm))
+	}
+
+	return fmt.Errorf("%w: %s", ErrZONEMDMismatch, strings.Join(problems, ", "))
+}
+
+// ZONEMD calculates the SIMPLE digest of a zone as returned by the API.
+// For zones signed by PowerDNS, the API does not return the DNSSEC records which are added when serving the zone.
+func (z *ZonesService) ZONEMD(ctx context.Context, domain string, hashAlgorithm ZONEMDHashAlgorithm) (*ZONEMD, error) {
+	zone, err := z.get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeZONEMD(StringValue(zone.Name), zone.RRsets, hashAlgorithm)
+}
+
+// PublishZONEMD writes the SIMPLE/SHA-384 digest of a zone to its apex ZONEMD RRset.
+// SOA-EDIT-API increases the serial on every change made through the API, which makes the published digest stale.
+// Zones using it are refused, and the zone is read again after the change to verify the published record.
+// Zones signed by PowerDNS are refused as well, because the digest cannot cover the DNSSEC records added when serving them.
+func (z *ZonesService) PublishZONEMD(ctx context.Context, domain string, ttl uint32) (*ZONEMD, error) {
+	zone, err := z.get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	apex := StringValue(zone.Name)
+	if soaEditAPI := StringValue(zone.SOAEditAPI); soaEditAPI != "" {
+		return nil, fmt.Errorf("zone %s uses SOA-EDIT-API %s, which changes the serial covered by ZONEMD", apex, soaEditAPI)
+	}
+	if BoolValue(zone.DNSsec) && !BoolValue(zone.Presigned) {
+		return nil, fmt.Errorf("zone %s is signed by the server, its DNSSEC records are not covered by ZONEMD", apex)
+	}
+
+	zonemd, err := ComputeZONEMD(apex, zone.RRsets, ZONEMDHashSHA384)
+	if err != nil {
+		return nil, err
+	}
+
+	rrsets := &RRsets{Sets: []RRset{{
+		Name:       String(apex),
+		Type:       RRTypePtr(RRTypeZONEMD),
+		TTL:        Uint32(ttl),
+		ChangeType: ChangeTypePtr(ChangeTypeReplace),
+		Records:    []Record{{Content: String(zonemd.String()), Disabled: Bool(false), SetPTR: Bool(false)}},
+	}}}
+	if err := z.client.Records.Patch(ctx, domain, rrsets); err != nil {
+		return nil, err
+	}
+
+	if err := z.VerifyZONEMD(ctx, domain); err != nil {
+		return nil, err
+	}
+	return zonemd, nil
+}
+
+// VerifyZONEMD checks the apex ZONEMD records of a zone as returned by the API against its contents
+func (z *ZonesService) VerifyZONEMD(ctx context.Context, domain string) error {
+	zone, err := z.get(ctx, domain)
+	if err != nil {
+		return err
+	}
+	return VerifyZONEMD(StringValue(zone.Name), zone.RRsets)
+}
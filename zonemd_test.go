@@ -0,0 +1,222 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+// RFC 8976, appendix A.1
+const testZONEMDSimple = "2018031900 1 1 c68090d90a7aed716bc459f9340e3d7c1370d4d24b7e2fc3a1ddc0b9a87153b9a9713b3c9ae5cc27777f98b8e730044c"
+
+func generateTestZONEMDRRsets() []RRset {
+	return []RRset{
+		{Name: String("example."), Type: RRTypePtr(RRTypeSOA), TTL: Uint32(86400), Records: []Record{{Content: String("ns1.example. admin.example. 2018031900 1800 900 604800 86400")}}},
+		{Name: String("example."), Type: RRTypePtr(RRTypeNS), TTL: Uint32(86400), Records: []Record{{Content: String("ns2.example.")}, {Content: String("ns1.example.")}}},
+		{Name: String("example."), Type: RRTypePtr(RRTypeZONEMD), TTL: Uint32(86400), Records: []Record{{Content: String(testZONEMDSimple)}}},
+		{Name: String("ns1.example."), Type: RRTypePtr(RRTypeA), TTL: Uint32(3600), Records: []Record{{Content: String("203.0.113.63")}}},
+		{Name: String("ns2.example."), Type: RRTypePtr(RRTypeAAAA), TTL: Uint32(3600), Records: []Record{{Content: String("2001:db8::63")}, {Content: String("2001:db8::64"), Disabled: Bool(true)}}},
+	}
+}
+
+func TestComputeZONEMD(t *testing.T) {
+	zonemd, err := ComputeZONEMD("example", generateTestZONEMDRRsets(), ZONEMDHashSHA384)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if zonemd.String() != testZONEMDSimple {
+		t.Errorf("Invalid ZONEMD %s", zonemd)
+	}
+
+	if _, err := ComputeZONEMD("example", generateTestZONEMDRRsets(), 3); err == nil {
+		t.Error("Expected error for unsupported hash algorithm")
+	}
+	if _, err := ComputeZONEMD("example", generateTestZONEMDRRsets()[1:], ZONEMDHashSHA384); err == nil {
+		t.Error("Expected error for missing SOA")
+	}
+}
+
+func TestVerifyZONEMD(t *testing.T) {
+	rrsets := generateTestZONEMDRRsets()
+	if err := VerifyZONEMD("example.", rrsets); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	// An additional record with an unsupported hash algorithm does not break the verification
+	rrsets[2].Records = append(rrsets[2].Records, Record{Content: String("2018031900 1 240 e2d523f654b9422a96c5a8f44607bbee")})
+	if err := VerifyZONEMD("example.", rrsets); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	rrsets[3].Records[0].Content = String("203.0.113.64")
+	if err := VerifyZONEMD("example.", rrsets); !errors.Is(err, ErrZONEMDMismatch) {
+		t.Errorf("Expected digest mismatch, got %v", err)
+	}
+
+	rrsets = generateTestZONEMDRRsets()
+	rrsets[0].Records[0].Content = String("ns1.example. admin.example. 2018031901 1800 900 604800 86400")
+	if err := VerifyZONEMD("example.", rrsets); !errors.Is(err, ErrZONEMDMismatch) {
+		t.Errorf("Expected serial mismatch, got %v", err)
+	}
+
+	if err := VerifyZONEMD("example.", removeTestRRset(rrsets, "example.", RRTypeZONEMD)); err == nil {
+		t.Error("Expected error for missing ZONEMD")
+	}
+}
+
+func TestParseZONEMD(t *testing.T) {
+	zonemd, err := ParseZONEMD("2018031900 1 1 c68090d90a7aed71 6bc459f9340e3d7c")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if zonemd.Serial != 2018031900 || zonemd.Scheme != ZONEMDSchemeSimple || zonemd.HashAlgorithm != ZONEMDHashSHA384 || len(zonemd.Digest) != 16 {
+		t.Errorf("Invalid ZONEMD: %+v", zonemd)
+	}
+
+	for _, content := range []string{"", "2018031900 1 1", "2018031900 1 1 c680", "x 1 1 c68090d90a7aed716bc459f9", "2018031900 1 1 zz"} {
+		if _, err := ParseZONEMD(content); err == nil {
+			t.Errorf("Expected error for %q", content)
+		}
+	}
+}
+
+func TestPublishZONEMD(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	rrsets := removeTestRRset(generateTestZONEMDRRsets(), "example.", RRTypeZONEMD)
+	zones := map[string][]RRset{"example.": rrsets}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	zonemd, err := p.Zones.PublishZONEMD(ctx, "example", 86400)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if zonemd.String() != testZONEMDSimple {
+		t.Errorf("Invalid ZONEMD %s", zonemd)
+	}
+	if sets := patched["example."]; len(sets) != 1 || *sets[0].Type != RRTypeZONEMD {
+		t.Errorf("Invalid patch: %+v", sets)
+	}
+
+	if err := p.Zones.VerifyZONEMD(ctx, "example"); err != nil {
+		t.Errorf("%s", err)
+	}
+	computed, err := p.Zones.ZONEMD(ctx, "example", ZONEMDHashSHA512)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(computed.Digest) != 64 {
+		t.Errorf("Invalid SHA-512 digest %s", computed)
+	}
+}
+
+func TestPublishZONEMDSerialChange(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	rrsets := removeTestRRset(generateTestZONEMDRRsets(), "example.", RRTypeZONEMD)
+	zones := map[string][]RRset{"example.": rrsets}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	// The server increases the serial of the zone on every change, like SOA-EDIT-API does
+	zoneURL := generateTestAPIVHostURL() + "/zones/" + escapeZoneID("example.")
+	httpmock.RegisterResponder("PATCH", zoneURL,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+
+			var payload RRsets
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				return httpmock.NewBytesResponse(http.StatusBadRequest, []byte{}), nil
+			}
+			for _, set := range payload.Sets {
+				set.ChangeType = nil
+				zones["example."] = append(removeTestRRset(zones["example."], *set.Name, *set.Type), set)
+			}
+			zones["example."] = removeTestRRset(zones["example."], "example.", RRTypeSOA)
+			zones["example."] = append(zones["example."], RRset{Name: String("example."), Type: RRTypePtr(RRTypeSOA), TTL: Uint32(86400), Records: []Record{{Content: String("ns1.example. admin.example. 2018031901 1800 900 604800 86400")}}})
+			return httpmock.NewBytesResponse(http.StatusNoContent, []byte{}), nil
+		},
+	)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if _, err := p.Zones.PublishZONEMD(ctx, "example", 86400); !errors.Is(err, ErrZONEMDMismatch) {
+		t.Errorf("Expected serial mismatch, got %v", err)
+	}
+
+	// Zones with SOA-EDIT-API are refused before any change is made
+	httpmock.RegisterResponder("GET", zoneURL,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, Zone{ID: String("example."), Name: String("example."), SOAEditAPI: String("DEFAULT"), RRsets: zones["example."]})
+		},
+	)
+	patches := httpmock.GetCallCountInfo()["PATCH "+zoneURL]
+	if _, err := p.Zones.PublishZONEMD(ctx, "example", 86400); err == nil {
+		t.Errorf("Expected error for SOA-EDIT-API, got %v", err)
+	}
+	if calls := httpmock.GetCallCountInfo()["PATCH "+zoneURL]; calls != patches {
+		t.Errorf("Unexpected PATCH requests: %d", calls-patches)
+	}
+}
+
+func TestPublishZONEMDSignedZone(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	rrsets := removeTestRRset(generateTestZONEMDRRsets(), "example.", RRTypeZONEMD)
+	zoneURL := generateTestAPIVHostURL() + "/zones/" + escapeZoneID("example.")
+	presigned := false
+	httpmock.RegisterResponder("GET", zoneURL,
+		func(req *http.Request) (*http.Response, error) {
+			if res := verifyAPIKey(req); res != nil {
+				return res, nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, Zone{ID: String("example."), Name: String("example."), DNSsec: Bool(true), Presigned: Bool(presigned), RRsets: rrsets})
+		},
+	)
+	httpmock.RegisterResponder("PATCH", zoneURL,
+		func(req *http.Request) (*http.Response, error) {
+			var payload RRsets
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				return httpmock.NewBytesResponse(http.StatusBadRequest, []byte{}), nil
+			}
+			rrsets = append(rrsets, payload.Sets...)
+			return httpmock.NewBytesResponse(http.StatusNoContent, []byte{}), nil
+		},
+	)
+
+	p := initialisePowerDNSTestClient()
+	ctx := context.Background()
+
+	if _, err := p.Zones.PublishZONEMD(ctx, "example", 86400); err == nil {
+		t.Error("Expected error for a zone signed by the server")
+	}
+	if calls := httpmock.GetCallCountInfo()["PATCH "+zoneURL]; calls != 0 {
+		t.Errorf("Unexpected PATCH requests: %d", calls)
+	}
+
+	// Presigned zones contain their DNSSEC records already
+	presigned = true
+	if _, err := p.Zones.PublishZONEMD(ctx, "example", 86400); err != nil {
+		t.Errorf("%s", err)
+	}
+	if calls := httpmock.GetCallCountInfo()["PATCH "+zoneURL]; calls != 1 {
+		t.Errorf("Invalid amount of PATCH requests: %d", calls)
+	}
+}
@@ -4,8 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
 )
 
+// DefaultZoneIndexTTL defines how long the list of zones used by FindEnclosing is cached
+const DefaultZoneIndexTTL = time.Minute
+
 // ZonesService handles communication with the zones related methods of the Client API
 type ZonesService service
 
@@ -39,6 +45,11 @@ type NotifyResult struct {
 	Result *string `json:"result,omitempty"`
 }
 
+// RectifyResult structure with JSON API metadata
+type RectifyResult struct {
+	Result *string `json:"result,omitempty"`
+}
+
 // Export string type
 type Export string
 
@@ -72,6 +83,16 @@ const (
 
 // List retrieves a list of Zones
 func (z *ZonesService) List(ctx context.Context) ([]Zone, error) {
+	zones, err := z.list(ctx)
+	if err == nil && z.client.UnicodeResponses {
+		for i := range zones {
+			zoneToUnicode(&zones[i])
+		}
+	}
+	return zones, err
+}
+
+func (z *ZonesService) list(ctx context.Context) ([]Zone, error) {
 	req, err := z.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones", z.client.VHost), nil, nil)
 	if err != nil {
 		return nil, err
@@ -84,7 +105,15 @@ func (z *ZonesService) List(ctx context.Context) ([]Zone, error) {
 
 // Get returns a certain Zone for a given domain
 func (z *ZonesService) Get(ctx context.Context, domain string) (*Zone, error) {
-	req, err := z.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s", z.client.VHost, trimDomain(domain)), nil, nil)
+	zone, err := z.get(ctx, domain)
+	if err == nil && z.client.UnicodeResponses {
+		zoneToUnicode(zone)
+	}
+	return zone, err
+}
+
+func (z *ZonesService) get(ctx context.Context, domain string) (*Zone, error) {
+	req, err := z.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s", z.client.VHost, escapeZoneID(domain)), nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -140,22 +169,49 @@ func (z *ZonesService) AddSlave(ctx context.Context, domain string, masters []st
 	return z.postZone(ctx, &zone)
 }
 
-// Add pre-created zone
+// Add pre-created zone.
+// If the zone has been created, but the delegation could not be synced, the created zone is returned with a DelegationSyncError.
 func (z *ZonesService) Add(ctx context.Context, zone *Zone) (*Zone, error) {
 	return z.postZone(ctx, zone)
 }
 
 func (z *ZonesService) postZone(ctx context.Context, zone *Zone) (*Zone, error) {
-	zone.Name = String(makeDomainCanonical(*zone.Name))
+	name, err := IDNToASCII(makeDomainCanonical(*zone.Name))
+	if err != nil {
+		return nil, err
+	}
+	zone.Name = String(name)
 	zone.Type = ZoneTypePtr(ZoneZoneType)
 
+	for i := range zone.Nameservers {
+		if zone.Nameservers[i], err = IDNToASCII(zone.Nameservers[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i := range zone.RRsets {
+		if err := convertRRSetNames(&zone.RRsets[i], IDNToASCII); err != nil {
+			return nil, err
+		}
+	}
+
 	req, err := z.client.newRequest(ctx, "POST", fmt.Sprintf("servers/%s/zones", z.client.VHost), nil, zone)
 	if err != nil {
 		return nil, err
 	}
 
 	createdZone := new(Zone)
-	_, err = z.client.do(req, &createdZone)
+	if _, err = z.client.do(req, &createdZone); err != nil {
+		return createdZone, err
+	}
+
+	z.InvalidateIndex()
+	// Slave zones have no records until they are transferred
+	if zone.Kind == nil || *zone.Kind != SlaveZoneKind {
+		err = z.client.syncDelegation(ctx, name)
+	}
+	if z.client.UnicodeResponses {
+		zoneToUnicode(createdZone)
+	}
 	return createdZone, err
 }
 
@@ -165,33 +221,39 @@ func (z *ZonesService) Change(ctx context.Context, domain string, zone *Zone) er
 	zone.Name = nil
 	zone.Type = nil
 	zone.URL = nil
-	if zone.DNSsec != nil && !*zone.DNSsec {
-		zone.Nsec3Param = nil
-	}
 
-	req, err := z.client.newRequest(ctx, "PUT", fmt.Sprintf("servers/%s/zones/%s", z.client.VHost, trimDomain(domain)), nil, zone)
+	req, err := z.client.newRequest(ctx, "PUT", fmt.Sprintf("servers/%s/zones/%s", z.client.VHost, escapeZoneID(domain)), nil, zone)
 	if err != nil {
 		return err
 	}
 
-	_, err = z.client.do(req, nil)
-	return err
+	if _, err = z.client.do(req, nil); err != nil {
+		return err
+	}
+	if zone.DNSsec != nil {
+		return z.client.syncDelegation(ctx, domain)
+	}
+	return nil
 }
 
 // Delete removes a certain Zone for a given domain
 func (z *ZonesService) Delete(ctx context.Context, domain string) error {
-	req, err := z.client.newRequest(ctx, "DELETE", fmt.Sprintf("servers/%s/zones/%s", z.client.VHost, trimDomain(domain)), nil, nil)
+	req, err := z.client.newRequest(ctx, "DELETE", fmt.Sprintf("servers/%s/zones/%s", z.client.VHost, escapeZoneID(domain)), nil, nil)
 	if err != nil {
 		return err
 	}
 
-	_, err = z.client.do(req, nil)
-	return err
+	if _, err = z.client.do(req, nil); err != nil {
+		return err
+	}
+
+	z.InvalidateIndex()
+	return z.client.removeDelegation(ctx, domain)
 }
 
 // Notify sends a DNS notify packet to all slaves
 func (z *ZonesService) Notify(ctx context.Context, domain string) (*NotifyResult, error) {
-	req, err := z.client.newRequest(ctx, "PUT", fmt.Sprintf("servers/%s/zones/%s/notify", z.client.VHost, trimDomain(domain)), nil, nil)
+	req, err := z.client.newRequest(ctx, "PUT", fmt.Sprintf("servers/%s/zones/%s/notify", z.client.VHost, escapeZoneID(domain)), nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -201,9 +263,21 @@ func (z *ZonesService) Notify(ctx context.Context, domain string) (*NotifyResult
 	return notifyResult, err
 }
 
+// Rectify calculates the ordername and auth fields of a DNSSEC-signed Zone
+func (z *ZonesService) Rectify(ctx context.Context, domain string) (*RectifyResult, error) {
+	req, err := z.client.newRequest(ctx, "PUT", fmt.Sprintf("servers/%s/zones/%s/rectify", z.client.VHost, escapeZoneID(domain)), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rectifyResult := &RectifyResult{}
+	_, err = z.client.do(req, rectifyResult)
+	return rectifyResult, err
+}
+
 // Export returns a BIND-like Zone file
 func (z *ZonesService) Export(ctx context.Context, domain string) (Export, error) {
-	req, err := z.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s/export", z.client.VHost, trimDomain(domain)), nil, nil)
+	req, err := z.client.newRequest(ctx, "GET", fmt.Sprintf("servers/%s/zones/%s/export", z.client.VHost, escapeZoneID(domain)), nil, nil)
 	if err != nil {
 		return "", err
 	}
@@ -216,3 +290,105 @@ func (z *ZonesService) Export(ctx context.Context, domain string) (Export, error
 	bodyBytes, _ := ioutil.ReadAll(resp.Body)
 	return Export(bodyBytes), nil
 }
+
+// findEnclosingZone returns the zone with the longest name that encloses a given name
+func findEnclosingZone(zones []Zone, name string) *Zone {
+	name = strings.ToLower(makeDomainCanonical(name))
+
+	var enclosing *Zone
+	for i := range zones {
+		zoneName := strings.ToLower(makeDomainCanonical(StringValue(zones[i].Name)))
+		if name != zoneName && !strings.HasSuffix(name, "."+zoneName) {
+			continue
+		}
+		if enclosing == nil || len(zoneName) > len(StringValue(enclosing.Name)) {
+			enclosing = &zones[i]
+		}
+	}
+
+	return enclosing
+}
+
+// zoneIndex caches the list of zones hosted on the server
+type zoneIndex struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	zones     []Zone
+	fetchedAt time.Time
+}
+
+func newZoneIndex() *zoneIndex {
+	return &zoneIndex{ttl: DefaultZoneIndexTTL}
+}
+
+// SetIndexTTL defines how long the list of zones used by FindEnclosing is cached. A TTL of zero disables the cache.
+func (z *ZonesService) SetIndexTTL(ttl time.Duration) {
+	index := z.client.zoneIndex
+	if index == nil {
+		return
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.ttl = ttl
+	index.zones = nil
+}
+
+// InvalidateIndex drops the cached list of zones used by FindEnclosing
+func (z *ZonesService) InvalidateIndex() {
+	index := z.client.zoneIndex
+	if index == nil {
+		return
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.zones = nil
+}
+
+func (z *ZonesService) indexedZones(ctx context.Context) ([]Zone, error) {
+	index := z.client.zoneIndex
+	if index == nil {
+		return z.list(ctx)
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.zones != nil && timeNow().Sub(index.fetchedAt) < index.ttl {
+		return index.zones, nil
+	}
+
+	zones, err := z.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if index.ttl > 0 {
+		index.zones = zones
+		index.fetchedAt = timeNow()
+	}
+	return zones, nil
+}
+
+// FindEnclosing returns the zone hosted on the server with the longest name that encloses a given FQDN.
+// The list of zones is cached, see SetIndexTTL.
+func (z *ZonesService) FindEnclosing(ctx context.Context, fqdn string) (*Zone, error) {
+	zone, err := z.findEnclosing(ctx, fqdn)
+	if err == nil && z.client.UnicodeResponses {
+		zoneToUnicode(zone)
+	}
+	return zone, err
+}
+
+func (z *ZonesService) findEnclosing(ctx context.Context, fqdn string) (*Zone, error) {
+	zones, err := z.indexedZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if zone := findEnclosingZone(zones, idnToASCIIOrRaw(fqdn)); zone != nil {
+		enclosing := *zone
+		return &enclosing, nil
+	}
+
+	return nil, newNotFoundError(fmt.Sprintf("no zone enclosing %s found", makeDomainCanonical(fqdn)))
+}
@@ -610,3 +610,88 @@ func TestExportError(t *testing.T) {
 		t.Error("error is nil")
 	}
 }
+
+func TestFindEnclosingZone(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	zones := map[string][]RRset{"example.com.": {}, "sub.example.com.": {}, "com.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+
+	p := initialisePowerDNSTestClient()
+	testCases := []struct {
+		fqdn     string
+		wantZone string
+		wantErr  bool
+	}{
+		{"www.example.com", "example.com.", false},
+		{"WWW.SUB.example.com.", "sub.example.com.", false},
+		{"example.com.", "example.com.", false},
+		{"www.otherexample.com.", "com.", false},
+		{"www.example.org.", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.fqdn, func(t *testing.T) {
+			zone, err := p.Zones.FindEnclosing(context.Background(), tc.fqdn)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err == nil && *zone.Name != tc.wantZone {
+				t.Errorf("%s != %s", *zone.Name, tc.wantZone)
+			}
+		})
+	}
+
+	if calls := httpmock.GetCallCountInfo()["GET "+generateTestAPIVHostURL()+"/zones"]; calls != 1 {
+		t.Errorf("Zone index has been fetched %d times", calls)
+	}
+}
+
+func TestZoneIndexTTL(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	originalTimeNow := timeNow
+	defer func() {
+		timeNow = originalTimeNow
+	}()
+	now := time.Unix(0, 0)
+	timeNow = func() time.Time {
+		return now
+	}
+
+	zones := map[string][]RRset{"example.com.": {}}
+	patched := make(map[string][]RRset)
+	registerZonesStateMockResponder(zones, patched)
+	listURL := "GET " + generateTestAPIVHostURL() + "/zones"
+
+	p := initialisePowerDNSTestClient()
+	p.Zones.SetIndexTTL(time.Minute)
+	_, _ = p.Zones.FindEnclosing(context.Background(), "www.example.com")
+	now = now.Add(30 * time.Second)
+	_, _ = p.Zones.FindEnclosing(context.Background(), "www.example.com")
+	if calls := httpmock.GetCallCountInfo()[listURL]; calls != 1 {
+		t.Errorf("Zone index has been fetched %d times", calls)
+	}
+
+	now = now.Add(time.Minute)
+	_, _ = p.Zones.FindEnclosing(context.Background(), "www.example.com")
+	if calls := httpmock.GetCallCountInfo()[listURL]; calls != 2 {
+		t.Errorf("Zone index has been fetched %d times", calls)
+	}
+
+	p.Zones.InvalidateIndex()
+	_, _ = p.Zones.FindEnclosing(context.Background(), "www.example.com")
+	if calls := httpmock.GetCallCountInfo()[listURL]; calls != 3 {
+		t.Errorf("Zone index has been fetched %d times", calls)
+	}
+
+	p.Zones.SetIndexTTL(0)
+	_, _ = p.Zones.FindEnclosing(context.Background(), "www.example.com")
+	_, _ = p.Zones.FindEnclosing(context.Background(), "www.example.com")
+	if calls := httpmock.GetCallCountInfo()[listURL]; calls != 5 {
+		t.Errorf("Zone index has been fetched %d times", calls)
+	}
+}